@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"deepthinking.do/luengo/engine/config"
+	"deepthinking.do/luengo/engine/logging"
+)
+
+// configFlags are the settings flags shared by the subcommands. Flags left unset
+// keep the value from the config file.
+type configFlags struct {
+	file       string
+	pack       string
+	modPath    string
+	assetRoot  string
+	width      int
+	height     int
+	fullscreen bool
+	scene      string
+	logLevel   string
+	tps        int
+	debug      string
+	memoryMB   int
+	hotReload  bool
+}
+
+func addConfigFlags(flags *flag.FlagSet) *configFlags {
+	def := config.Default()
+	f := &configFlags{}
+	flags.StringVar(&f.file, "config", config.FileName, "config file; flags override its settings (default: luengo.json here or next to the executable)")
+	flags.StringVar(&f.pack, "pack", def.Pack, "archive to mount under the game folder, e.g. one built by luengo pack")
+	flags.StringVar(&f.modPath, "mods", def.ModPath, "mod folder")
+	flags.StringVar(&f.assetRoot, "assets", def.AssetRoot, "asset root shown in the asset browser")
+	flags.IntVar(&f.width, "width", def.Width, "window width")
+	flags.IntVar(&f.height, "height", def.Height, "window height")
+	flags.BoolVar(&f.fullscreen, "fullscreen", def.Fullscreen, "start in fullscreen")
+	flags.StringVar(&f.scene, "scene", def.Scene, "scene to load at startup and save with F5 (default <mods>/scenes/main.scene, not loaded)")
+	flags.StringVar(&f.logLevel, "loglevel", def.LogLevel, "drop log entries below debug, info, warn or error")
+	flags.IntVar(&f.tps, "tps", def.TPS, "game updates per second")
+	flags.StringVar(&f.debug, "debug", def.Debug, "start the Lua debugger (DAP) on an address, e.g. localhost:4711")
+	flags.IntVar(&f.memoryMB, "memory", def.MemoryMB, "megabytes unused assets may keep cached")
+	flags.BoolVar(&f.hotReload, "hotreload", def.HotReload, "reload images and sounds when their files change")
+	return f
+}
+
+// load reads the config file and applies the flags that were set on the command line
+func (f *configFlags) load(flags *flag.FlagSet) (config.Config, error) {
+	explicit := false
+	flags.Visit(func(fl *flag.Flag) {
+		if fl.Name == "config" {
+			explicit = true
+		}
+	})
+	file := f.file
+	if !explicit {
+		file = config.Locate()
+	}
+	cfg, err := config.Load(file, !explicit)
+	if err != nil {
+		return cfg, err
+	}
+	if addr := os.Getenv("LUENGO_DEBUG"); addr != "" {
+		cfg.Debug = addr
+	}
+
+	flags.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "pack":
+			cfg.Pack = f.pack
+		case "mods":
+			cfg.ModPath = f.modPath
+		case "assets":
+			cfg.AssetRoot = f.assetRoot
+		case "width":
+			cfg.Width = f.width
+		case "height":
+			cfg.Height = f.height
+		case "fullscreen":
+			cfg.Fullscreen = f.fullscreen
+		case "scene":
+			cfg.Scene = f.scene
+		case "loglevel":
+			cfg.LogLevel = f.logLevel
+		case "tps":
+			cfg.TPS = f.tps
+		case "debug":
+			cfg.Debug = f.debug
+		case "memory":
+			cfg.MemoryMB = f.memoryMB
+		case "hotreload":
+			cfg.HotReload = f.hotReload
+		}
+	})
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+
+	level, _ := logging.ParseLevel(cfg.LogLevel)
+	logging.Default().SetLevel(level)
+	return cfg, nil
+}
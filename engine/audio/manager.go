@@ -1,20 +1,25 @@
 package audio
 
 import (
-"fmt"
-"os"
 "time"
 
+"github.com/faiface/beep"
 "github.com/faiface/beep/speaker"
-"github.com/faiface/beep/wav"
+
+	"deepthinking.do/luengo/engine/logging"
+	"deepthinking.do/luengo/engine/resources"
 )
 
 type Manager struct {
+	resources   *resources.Manager
 	initialized bool
+	muted       bool
 }
 
-func NewManager() *Manager {
+// NewManager creates an audio manager that plays sounds cached by the resource manager
+func NewManager(rm *resources.Manager) *Manager {
 	return &Manager{
+		resources:   rm,
 		initialized: false,
 	}
 }
@@ -24,26 +29,32 @@ func (am *Manager) Initialize() error {
 	return nil
 }
 
+// SetMuted skips playback, e.g. in headless runs without an audio device
+func (am *Manager) SetMuted(muted bool) {
+	am.muted = muted
+}
+
 func (am *Manager) PlaySound(path string) error {
-	f, err := os.Open(path)
-	if err != nil {
-		return fmt.Errorf("failed to open audio file: %w", err)
+	if am.muted {
+		logging.Debugf("audio", "Muted, not playing: %s", path)
+		return nil
 	}
-	defer f.Close()
 
-	streamer, format, err := wav.Decode(f)
+	sound, err := am.resources.AcquireSound(path)
 	if err != nil {
-		return fmt.Errorf("failed to decode audio file: %w", err)
+		return err
 	}
-	defer streamer.Close()
+	format := sound.Get().Format
 
 	if !am.initialized {
 		speaker.Init(format.SampleRate, format.SampleRate.N(time.Second/10))
 		am.initialized = true
 	}
 
-	speaker.Play(streamer)
-	fmt.Printf("[Audio] Playing sound: %s\n", path)
+	// The sound stays referenced, and so cached, until it finishes playing
+	buffer := sound.Get().Buffer
+	speaker.Play(beep.Seq(buffer.Streamer(0, buffer.Len()), beep.Callback(sound.Release)))
+	logging.Infof("audio", "Playing sound: %s", path)
 	return nil
 }
 
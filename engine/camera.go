@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"deepthinking.do/luengo/engine/entity"
+)
+
+// updateViewport lays the cameras out in the screen area the editor panels leave
+func (g *Game) updateViewport() {
+	w, h := g.ui.ViewportSize(g.screenWidth, g.screenHeight)
+	g.cameras.Layout(0, 0, float64(w), float64(h))
+}
+
+// updateCamera follows each camera's target and applies the world bounds. A target
+// entity that was removed, e.g. by loading a scene, is replaced by the player on the
+// main camera; other cameras stop following.
+func (g *Game) updateCamera() {
+	for _, c := range g.cameras.Cameras() {
+		e, ok := c.Target().(*entity.Entity)
+		if !ok {
+			continue
+		}
+		if _, exists := g.entityManager.GetEntity(e.ID); exists {
+			continue
+		}
+		if c == g.camera {
+			g.followPlayer()
+		} else {
+			c.SetTarget(nil)
+		}
+	}
+	g.cameras.Update(1 / float64(g.config.TPS))
+}
+
+// followPlayer points the camera at the player, or stops it following if there is none
+func (g *Game) followPlayer() {
+	if g.player == nil {
+		g.camera.SetTarget(nil)
+		return
+	}
+	g.camera.SetTarget(g.player)
+}
+
+// drawCameras draws and lights the entities and particles through every enabled camera.
+// Textures are drawn first so cameras on screen show this frame's picture of any they
+// use as sprites.
+func (g *Game) drawCameras(screen *ebiten.Image, entities []*entity.Entity) {
+	cameras := g.cameras.Cameras()
+	for _, c := range cameras {
+		if !c.Enabled || c.Texture == nil {
+			continue
+		}
+		if c.Background != nil {
+			c.Texture.Fill(c.Background)
+		} else {
+			c.Texture.Clear()
+		}
+		g.drawEntities(c.Texture, c, entities)
+		g.particles.Draw(c.Texture, c, g.layers)
+		g.lighting.Apply(c.Texture, c, entities)
+	}
+	for _, c := range cameras {
+		if !c.Enabled || c.Texture != nil {
+			continue
+		}
+		v := c.Viewport
+		area := image.Rect(int(v.X), int(v.Y), int(v.X+v.W), int(v.Y+v.H))
+		if area.Empty() {
+			continue
+		}
+		dst := screen.SubImage(area).(*ebiten.Image)
+		if c.Background != nil {
+			dst.Fill(c.Background)
+		}
+		g.drawEntities(dst, c, entities)
+		g.particles.Draw(dst, c, g.layers)
+		g.lighting.Apply(dst, c, entities)
+	}
+	g.lighting.Prune()
+}
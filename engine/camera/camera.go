@@ -2,28 +2,90 @@ package camera
 
 import (
 	"fmt"
+	"image/color"
+	"math"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
+// Default zoom limits
+const (
+	DefaultMinZoom = 0.5
+	DefaultMaxZoom = 3.0
+)
+
+// DefaultSmoothing is how quickly a following camera catches up with its target
+const DefaultSmoothing = 6.0
+
+// Rect is an axis-aligned rectangle
+type Rect struct {
+	X, Y, W, H float64
+}
+
+// Target is something the camera can follow, e.g. an entity
+type Target interface {
+	WorldCenter() (float64, float64)
+}
+
+// Follow configures how the camera follows its target
+type Follow struct {
+	Smoothing float64 // How quickly the camera catches up, per second; 0 snaps to the target
+	DeadZoneW float64 // Screen pixels around the viewport centre the target moves in without the camera moving
+	DeadZoneH float64
+	LookAhead float64 // World units the camera leads the target in the direction it moves
+}
+
 type Camera struct {
-	X, Y float64  // Camera position
-	Zoom float64  // Zoom level (1.0 = normal)
+	Name    string
+	Enabled bool
+	Order   int      // Cameras with a higher order draw later, over the others
+	Area    Rect     // Fraction of the game view the camera draws into, e.g. {0, 0, 0.5, 1} for the left half
+	Layers  []string // Render layers drawn; empty draws every layer
+
+	// Texture, when set, is drawn into instead of the screen, so the view can be used as a sprite
+	Texture    *ebiten.Image
+	Background color.Color // Fills the viewport before drawing, nil leaves what is below
+
+	X, Y     float64 // World position shown at the viewport's top-left corner, before rotation
+	Zoom     float64 // Zoom level (1.0 = normal)
+	Rotation float64 // Radians the view is turned around its centre
+
+	MinZoom, MaxZoom float64
+	Viewport         Rect  // Screen area the camera draws into
+	Bounds           *Rect // World area the view is kept inside while playing, nil for none
+
+	Follow Follow
+	target Target
+
+	// Follow state
+	lastTargetX, lastTargetY float64
+	hasLastTarget            bool
+	lookX, lookY             float64
+
+	Shake     Shake
+	trauma    float64
+	shakeTime float64
+
+	// Transitions in progress, nil when idle
+	pan, zoom, rotate *tween
 }
 
 func New() *Camera {
-	return &Camera{
-		X:    0,
-		Y:    0,
-		Zoom: 1.0,
-	}
+	c := NewCamera()
+	return &c
 }
 
 func NewCamera() Camera {
 	return Camera{
-		X:    0,
-		Y:    0,
-		Zoom: 1.0,
+		Enabled: true,
+		Area:    Rect{W: 1, H: 1},
+		X:       0,
+		Y:       0,
+		Zoom:    1.0,
+		MinZoom: DefaultMinZoom,
+		MaxZoom: DefaultMaxZoom,
+		Follow:  Follow{Smoothing: DefaultSmoothing},
+		Shake:   DefaultShake,
 	}
 }
 
@@ -31,6 +93,47 @@ func (c *Camera) Reset() {
 	c.X = 0
 	c.Y = 0
 	c.Zoom = 1.0
+	c.Rotation = 0
+	c.lookX, c.lookY = 0, 0
+	c.hasLastTarget = false
+	c.trauma = 0
+	c.StopTransitions()
+}
+
+// DrawsLayer reports whether the camera draws entities on a render layer
+func (c *Camera) DrawsLayer(layer string) bool {
+	if len(c.Layers) == 0 {
+		return true
+	}
+	for _, l := range c.Layers {
+		if l == layer {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderToTexture makes the camera draw into an offscreen image of the given size
+// instead of the screen, and returns it. A texture of the same size is kept; one of
+// another size is disposed and replaced.
+func (c *Camera) RenderToTexture(width, height int) (*ebiten.Image, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid texture size %dx%d", width, height)
+	}
+	if c.Texture == nil || c.Texture.Bounds().Dx() != width || c.Texture.Bounds().Dy() != height {
+		c.RenderToScreen()
+		c.Texture = ebiten.NewImage(width, height)
+	}
+	c.SetViewport(0, 0, float64(width), float64(height))
+	return c.Texture, nil
+}
+
+// RenderToScreen makes the camera draw to the screen again, disposing its texture
+func (c *Camera) RenderToScreen() {
+	if c.Texture != nil {
+		c.Texture.Dispose()
+		c.Texture = nil
+	}
 }
 
 func (c *Camera) Move(dx, dy float64) {
@@ -38,53 +141,204 @@ func (c *Camera) Move(dx, dy float64) {
 	c.Y += dy
 }
 
-func (c *Camera) SetZoom(zoom float64) {
-	if zoom < 0.5 {
-		zoom = 0.5
-	}
-	if zoom > 3.0 {
-		zoom = 3.0
+// SetViewport sets the screen area the camera draws into, e.g. the space the editor
+// panels leave; the world position at its top-left corner stays the same
+func (c *Camera) SetViewport(x, y, w, h float64) {
+	c.Viewport = Rect{X: x, Y: y, W: math.Max(w, 0), H: math.Max(h, 0)}
+}
+
+// SetZoomLimits sets the zoom range, clamping the current zoom to it
+func (c *Camera) SetZoomLimits(min, max float64) error {
+	if min <= 0 || max < min {
+		return fmt.Errorf("invalid zoom limits %g-%g", min, max)
 	}
-	c.Zoom = zoom
+	c.MinZoom, c.MaxZoom = min, max
+	c.SetZoom(c.Zoom)
+	return nil
+}
+
+// SetZoom sets the zoom within the limits, keeping the viewport's top-left corner in place
+func (c *Camera) SetZoom(zoom float64) {
+	c.Zoom = math.Max(c.MinZoom, math.Min(c.MaxZoom, zoom))
 }
 
 func (c *Camera) ZoomBy(factor float64) {
 	c.SetZoom(c.Zoom * factor)
 }
 
-// ScreenToWorld converts screen coordinates to world coordinates
+// ZoomAt multiplies the zoom by factor, keeping the world point under a screen position
+// in place, e.g. to zoom towards the cursor
+func (c *Camera) ZoomAt(factor, screenX, screenY float64) {
+	worldX, worldY := c.ScreenToWorld(screenX, screenY)
+	c.SetZoom(c.Zoom * factor)
+	movedX, movedY := c.ScreenToWorld(screenX, screenY)
+	c.Move(worldX-movedX, worldY-movedY)
+}
+
+// ZoomCentered multiplies the zoom by factor, keeping the centre of the view in place
+func (c *Camera) ZoomCentered(factor float64) {
+	c.ZoomAt(factor, c.Viewport.X+c.Viewport.W/2, c.Viewport.Y+c.Viewport.H/2)
+}
+
+// Center returns the world position at the centre of the view
+func (c *Camera) Center() (float64, float64) {
+	return c.X + c.Viewport.W/(2*c.Zoom), c.Y + c.Viewport.H/(2*c.Zoom)
+}
+
+// SetCenter moves the camera so a world position is at the centre of the view
+func (c *Camera) SetCenter(x, y float64) {
+	c.X = x - c.Viewport.W/(2*c.Zoom)
+	c.Y = y - c.Viewport.H/(2*c.Zoom)
+}
+
+// View returns the world area the camera shows, ignoring rotation and shake
+func (c *Camera) View() Rect {
+	return Rect{X: c.X, Y: c.Y, W: c.Viewport.W / c.Zoom, H: c.Viewport.H / c.Zoom}
+}
+
+// SetBounds keeps the view inside a world area while playing
+func (c *Camera) SetBounds(x, y, w, h float64) {
+	c.Bounds = &Rect{X: x, Y: y, W: w, H: h}
+}
+
+func (c *Camera) ClearBounds() {
+	c.Bounds = nil
+}
+
+// ClampToBounds moves the view inside the bounds; a view larger than the bounds is centred on them
+func (c *Camera) ClampToBounds() {
+	if c.Bounds == nil {
+		return
+	}
+	b, view := *c.Bounds, c.View()
+	c.X = clampAxis(c.X, view.W, b.X, b.W)
+	c.Y = clampAxis(c.Y, view.H, b.Y, b.H)
+}
+
+func clampAxis(pos, size, min, extent float64) float64 {
+	if size >= extent {
+		return min + (extent-size)/2
+	}
+	return math.Max(min, math.Min(min+extent-size, pos))
+}
+
+// SetTarget makes Update follow a target; nil stops following
+func (c *Camera) SetTarget(target Target) {
+	c.target = target
+	c.hasLastTarget = false
+	c.lookX, c.lookY = 0, 0
+}
+
+func (c *Camera) Target() Target {
+	return c.target
+}
+
+// Update advances transitions and shake, follows the target unless a pan is in
+// progress, and keeps the view inside the bounds. dt is the time since the last
+// update in seconds.
+func (c *Camera) Update(dt float64) {
+	c.Animate(dt)
+	if c.target != nil && c.pan == nil {
+		x, y := c.target.WorldCenter()
+		c.FollowTarget(x, y, dt)
+	}
+	c.ClampToBounds()
+}
+
+// FollowTarget moves the camera towards a target position, leading it by the look-ahead
+// and leaving it alone while it stays inside the dead zone
+func (c *Camera) FollowTarget(targetX, targetY, dt float64) {
+	f := c.Follow
+
+	// Lead the target in the direction it is moving; hold the lead while it stands still
+	if c.hasLastTarget && f.LookAhead > 0 {
+		vx, vy := targetX-c.lastTargetX, targetY-c.lastTargetY
+		if length := math.Hypot(vx, vy); length > 0 {
+			aimX, aimY := vx/length*f.LookAhead, vy/length*f.LookAhead
+			t := smoothing(f.Smoothing, dt)
+			c.lookX += (aimX - c.lookX) * t
+			c.lookY += (aimY - c.lookY) * t
+		}
+	} else if f.LookAhead <= 0 {
+		c.lookX, c.lookY = 0, 0
+	}
+	c.lastTargetX, c.lastTargetY = targetX, targetY
+	c.hasLastTarget = true
+
+	focusX, focusY := targetX+c.lookX, targetY+c.lookY
+	centerX, centerY := c.Center()
+	desiredX := centerX + outsideDeadZone(focusX-centerX, f.DeadZoneW/(2*c.Zoom))
+	desiredY := centerY + outsideDeadZone(focusY-centerY, f.DeadZoneH/(2*c.Zoom))
+
+	t := smoothing(f.Smoothing, dt)
+	c.SetCenter(centerX+(desiredX-centerX)*t, centerY+(desiredY-centerY)*t)
+}
+
+// outsideDeadZone returns how far an offset reaches past a dead zone of the given half size
+func outsideDeadZone(offset, half float64) float64 {
+	switch {
+	case offset > half:
+		return offset - half
+	case offset < -half:
+		return offset + half
+	default:
+		return 0
+	}
+}
+
+// smoothing returns the fraction of the remaining distance to close this update,
+// independent of the update rate
+func smoothing(speed, dt float64) float64 {
+	if speed <= 0 || dt <= 0 {
+		return 1
+	}
+	return 1 - math.Exp(-speed*dt)
+}
+
+// ScreenToWorld converts screen coordinates to world coordinates, as drawn
 func (c *Camera) ScreenToWorld(screenX, screenY float64) (float64, float64) {
-	worldX := (screenX / c.Zoom) + c.X
-	worldY := (screenY / c.Zoom) + c.Y
-	return worldX, worldY
+	matrix := c.GetTransformMatrix()
+	if !matrix.IsInvertible() {
+		return c.Center()
+	}
+	matrix.Invert()
+	return matrix.Apply(screenX, screenY)
 }
 
-// WorldToScreen converts world coordinates to screen coordinates
+// WorldToScreen converts world coordinates to screen coordinates, as drawn
 func (c *Camera) WorldToScreen(worldX, worldY float64) (float64, float64) {
-	screenX := (worldX - c.X) * c.Zoom
-	screenY := (worldY - c.Y) * c.Zoom
-	return screenX, screenY
+	matrix := c.GetTransformMatrix()
+	return matrix.Apply(worldX, worldY)
+}
+
+// VisibleBounds returns the world area that covers the viewport, including rotation
+// and shake, e.g. to cull what is off screen
+func (c *Camera) VisibleBounds() Rect {
+	v := c.Viewport
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{{v.X, v.Y}, {v.X + v.W, v.Y}, {v.X, v.Y + v.H}, {v.X + v.W, v.Y + v.H}} {
+		x, y := c.ScreenToWorld(corner[0], corner[1])
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	return Rect{X: minX, Y: minY, W: maxX - minX, H: maxY - minY}
 }
 
-// GetTransformMatrix returns the camera transform matrix for rendering
+// GetTransformMatrix returns the camera transform matrix for rendering: the view
+// centre goes to the viewport centre, turned by the rotation and offset by shake
 func (c *Camera) GetTransformMatrix() ebiten.GeoM {
+	shakeX, shakeY, shakeAngle := c.shakeOffset()
+	centerX, centerY := c.Center()
+
 	var matrix ebiten.GeoM
+	matrix.Translate(-centerX, -centerY)
+	matrix.Rotate(-(c.Rotation + shakeAngle))
 	matrix.Scale(c.Zoom, c.Zoom)
-	matrix.Translate(-c.X*c.Zoom, -c.Y*c.Zoom)
+	matrix.Translate(c.Viewport.X+c.Viewport.W/2+shakeX, c.Viewport.Y+c.Viewport.H/2+shakeY)
 	return matrix
 }
 
-// FollowTarget smoothly moves camera to follow a target position
-func (c *Camera) FollowTarget(targetX, targetY float64, screenWidth, screenHeight int, lerpFactor float64) {
-	// Calculate desired camera position (center target on screen)
-	desiredX := targetX - float64(screenWidth)/(2*c.Zoom)
-	desiredY := targetY - float64(screenHeight)/(2*c.Zoom)
-	
-	// Smooth interpolation
-	c.X += (desiredX - c.X) * lerpFactor
-	c.Y += (desiredY - c.Y) * lerpFactor
-}
-
 func (c *Camera) String() string {
 	return fmt.Sprintf("Camera(%.1f, %.1f, %.2fx)", c.X, c.Y, c.Zoom)
 }
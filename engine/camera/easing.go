@@ -0,0 +1,67 @@
+package camera
+
+import (
+	"math"
+	"sort"
+)
+
+// Easing maps linear progress between 0 and 1 to eased progress
+type Easing func(t float64) float64
+
+var easings = map[string]Easing{
+	"linear":       func(t float64) float64 { return t },
+	"in_quad":      func(t float64) float64 { return t * t },
+	"out_quad":     func(t float64) float64 { return t * (2 - t) },
+	"in_out_quad":  inOut(func(t float64) float64 { return t * t }),
+	"in_cubic":     func(t float64) float64 { return t * t * t },
+	"out_cubic":    func(t float64) float64 { return 1 - math.Pow(1-t, 3) },
+	"in_out_cubic": inOut(func(t float64) float64 { return t * t * t }),
+	"in_sine":      func(t float64) float64 { return 1 - math.Cos(t*math.Pi/2) },
+	"out_sine":     func(t float64) float64 { return math.Sin(t * math.Pi / 2) },
+	"in_out_sine":  func(t float64) float64 { return (1 - math.Cos(t*math.Pi)) / 2 },
+	"out_back":     outBack,
+	"out_elastic":  outElastic,
+	"smoothstep":   func(t float64) float64 { return t * t * (3 - 2*t) },
+}
+
+// DefaultEasing is used when a transition names none
+const DefaultEasing = "in_out_cubic"
+
+// EasingByName returns a named easing curve, e.g. "out_cubic"
+func EasingByName(name string) (Easing, bool) {
+	e, ok := easings[name]
+	return e, ok
+}
+
+// EasingNames lists the named easing curves
+func EasingNames() []string {
+	names := make([]string, 0, len(easings))
+	for name := range easings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inOut builds a symmetric curve from an ease-in curve
+func inOut(in Easing) Easing {
+	return func(t float64) float64 {
+		if t < 0.5 {
+			return in(t*2) / 2
+		}
+		return 1 - in((1-t)*2)/2
+	}
+}
+
+func outBack(t float64) float64 {
+	const c1 = 1.70158
+	const c3 = c1 + 1
+	return 1 + c3*math.Pow(t-1, 3) + c1*math.Pow(t-1, 2)
+}
+
+func outElastic(t float64) float64 {
+	if t <= 0 || t >= 1 {
+		return t
+	}
+	return math.Pow(2, -10*t)*math.Sin((t*10-0.75)*2*math.Pi/3) + 1
+}
@@ -0,0 +1,161 @@
+package camera
+
+import (
+	"math"
+)
+
+// Shake configures trauma-based screen shake: trauma between 0 and 1 decays over
+// time, and the shake grows with its square so small hits stay subtle
+type Shake struct {
+	MaxOffset float64 // Screen pixels at full trauma
+	MaxAngle  float64 // Radians at full trauma
+	Frequency float64 // Noise samples per second; higher is more jittery
+	Decay     float64 // Trauma lost per second
+}
+
+// DefaultShake suits a hit or an explosion
+var DefaultShake = Shake{MaxOffset: 24, MaxAngle: 0.08, Frequency: 25, Decay: 1.2}
+
+// AddTrauma shakes the camera; trauma adds up to at most 1
+func (c *Camera) AddTrauma(amount float64) {
+	c.trauma = math.Max(0, math.Min(1, c.trauma+amount))
+}
+
+func (c *Camera) Trauma() float64 {
+	return c.trauma
+}
+
+// shakeOffset returns the current shake in screen pixels and radians
+func (c *Camera) shakeOffset() (dx, dy, angle float64) {
+	if c.trauma <= 0 {
+		return 0, 0, 0
+	}
+	amount := c.trauma * c.trauma
+	t := c.shakeTime * c.Shake.Frequency
+	dx = c.Shake.MaxOffset * amount * noise(1, t)
+	dy = c.Shake.MaxOffset * amount * noise(2, t)
+	angle = c.Shake.MaxAngle * amount * noise(3, t)
+	return dx, dy, angle
+}
+
+// noise is smooth 1D value noise between -1 and 1; each seed gives an unrelated curve
+func noise(seed int, t float64) float64 {
+	i := math.Floor(t)
+	f := t - i
+	a, b := hash(seed, int64(i)), hash(seed, int64(i)+1)
+	f = f * f * (3 - 2*f)
+	return a + (b-a)*f
+}
+
+func hash(seed int, n int64) float64 {
+	x := uint64(n)*0x9E3779B97F4A7C15 + uint64(seed)*0xBF58476D1CE4E5B9
+	x ^= x >> 31
+	x *= 0x94D049BB133111EB
+	x ^= x >> 29
+	return float64(x>>11)/float64(1<<53)*2 - 1
+}
+
+// tween moves one camera property from a start to an end value over a duration
+type tween struct {
+	from, to []float64
+	duration float64
+	elapsed  float64
+	ease     Easing
+}
+
+func newTween(from, to []float64, duration float64, ease Easing) *tween {
+	if ease == nil {
+		ease = easings[DefaultEasing]
+	}
+	return &tween{from: from, to: to, duration: duration, ease: ease}
+}
+
+// advance moves the tween on by dt and returns the current values and whether it finished
+func (t *tween) advance(dt float64) ([]float64, bool) {
+	t.elapsed += dt
+	progress := 1.0
+	if t.duration > 0 {
+		progress = math.Min(1, t.elapsed/t.duration)
+	}
+	eased := t.ease(progress)
+	values := make([]float64, len(t.from))
+	for i := range values {
+		values[i] = t.from[i] + (t.to[i]-t.from[i])*eased
+	}
+	return values, progress >= 1
+}
+
+// PanTo moves the centre of the view to a world position over duration seconds.
+// Following pauses until the pan ends.
+func (c *Camera) PanTo(x, y, duration float64, ease Easing) {
+	fromX, fromY := c.Center()
+	c.pan = newTween([]float64{fromX, fromY}, []float64{x, y}, duration, ease)
+}
+
+// ZoomTo changes the zoom around the centre of the view over duration seconds
+func (c *Camera) ZoomTo(zoom, duration float64, ease Easing) {
+	zoom = math.Max(c.MinZoom, math.Min(c.MaxZoom, zoom))
+	c.zoom = newTween([]float64{c.Zoom}, []float64{zoom}, duration, ease)
+}
+
+// RotateTo turns the view to an angle in radians over duration seconds
+func (c *Camera) RotateTo(angle, duration float64, ease Easing) {
+	c.rotate = newTween([]float64{c.Rotation}, []float64{angle}, duration, ease)
+}
+
+// FocusOn pans and zooms so a world area fills the view with a margin, e.g. to
+// frame an entity, over duration seconds
+func (c *Camera) FocusOn(area Rect, duration float64, ease Easing) {
+	zoom := c.Zoom
+	if area.W > 0 && area.H > 0 && c.Viewport.W > 0 && c.Viewport.H > 0 {
+		const margin = 1.5
+		zoom = math.Min(c.Viewport.W/(area.W*margin), c.Viewport.H/(area.H*margin))
+	}
+	c.PanTo(area.X+area.W/2, area.Y+area.H/2, duration, ease)
+	c.ZoomTo(zoom, duration, ease)
+}
+
+// IsTransitioning reports whether a pan, zoom or rotation is in progress
+func (c *Camera) IsTransitioning() bool {
+	return c.pan != nil || c.zoom != nil || c.rotate != nil
+}
+
+// StopTransitions ends pans, zooms and rotations where they are
+func (c *Camera) StopTransitions() {
+	c.pan, c.zoom, c.rotate = nil, nil, nil
+}
+
+// Animate advances transitions and shake by dt seconds. Update calls it; the editor
+// calls it alone so the camera can frame entities without following or bounds.
+func (c *Camera) Animate(dt float64) {
+	if c.zoom != nil {
+		values, done := c.zoom.advance(dt)
+		// Zoom around the centre, or around the pan's current position below. Easings
+		// that overshoot stop at the zoom limits.
+		centerX, centerY := c.Center()
+		c.SetZoom(values[0])
+		c.SetCenter(centerX, centerY)
+		if done {
+			c.zoom = nil
+		}
+	}
+	if c.pan != nil {
+		values, done := c.pan.advance(dt)
+		c.SetCenter(values[0], values[1])
+		if done {
+			c.pan = nil
+		}
+	}
+	if c.rotate != nil {
+		values, done := c.rotate.advance(dt)
+		c.Rotation = values[0]
+		if done {
+			c.rotate = nil
+		}
+	}
+
+	if c.trauma > 0 {
+		c.shakeTime += dt
+		c.trauma = math.Max(0, c.trauma-c.Shake.Decay*dt)
+	}
+}
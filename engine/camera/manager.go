@@ -0,0 +1,115 @@
+package camera
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MainCamera is the name of the camera the editor controls and Lua's camera table uses
+const MainCamera = "main"
+
+// Manager holds the named cameras and lays out their viewports. Cameras draw in
+// order, lowest first, so a minimap with a higher order draws over the main view.
+type Manager struct {
+	cameras []*Camera
+}
+
+func NewManager() *Manager {
+	m := &Manager{}
+	main := New()
+	main.Name = MainCamera
+	m.cameras = append(m.cameras, main)
+	return m
+}
+
+// Main returns the main camera, which cannot be removed
+func (m *Manager) Main() *Camera {
+	c, _ := m.Get(MainCamera)
+	return c
+}
+
+// Add creates a camera covering the whole game view; set its Area for split
+// screen or picture-in-picture
+func (m *Manager) Add(name string) (*Camera, error) {
+	if name == "" {
+		return nil, fmt.Errorf("camera name cannot be empty")
+	}
+	if _, exists := m.Get(name); exists {
+		return nil, fmt.Errorf("camera %s already exists", name)
+	}
+	c := New()
+	c.Name = name
+	c.Order = len(m.cameras)
+	m.cameras = append(m.cameras, c)
+	return c, nil
+}
+
+func (m *Manager) Get(name string) (*Camera, bool) {
+	for _, c := range m.cameras {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Remove deletes a camera; the main camera stays
+func (m *Manager) Remove(name string) bool {
+	if name == MainCamera {
+		return false
+	}
+	for i, c := range m.cameras {
+		if c.Name == name {
+			m.cameras = append(m.cameras[:i], m.cameras[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Cameras returns the cameras in draw order
+func (m *Manager) Cameras() []*Camera {
+	cameras := append([]*Camera(nil), m.cameras...)
+	sort.SliceStable(cameras, func(i, j int) bool { return cameras[i].Order < cameras[j].Order })
+	return cameras
+}
+
+// Names returns the camera names in draw order
+func (m *Manager) Names() []string {
+	cameras := m.Cameras()
+	names := make([]string, len(cameras))
+	for i, c := range cameras {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// Layout sets the viewport of each camera that draws to the screen from its Area,
+// a fraction of the game view at the given screen rectangle
+func (m *Manager) Layout(x, y, w, h float64) {
+	for _, c := range m.cameras {
+		if c.Texture != nil {
+			continue
+		}
+		a := c.Area
+		c.SetViewport(x+a.X*w, y+a.Y*h, a.W*w, a.H*h)
+	}
+}
+
+// Update updates every enabled camera, see Camera.Update
+func (m *Manager) Update(dt float64) {
+	for _, c := range m.cameras {
+		if c.Enabled {
+			c.Update(dt)
+		}
+	}
+}
+
+// Animate advances the transitions and shake of every enabled camera
+func (m *Manager) Animate(dt float64) {
+	for _, c := range m.cameras {
+		if c.Enabled {
+			c.Animate(dt)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"deepthinking.do/luengo/engine/console"
+	"deepthinking.do/luengo/engine/entity"
+	"deepthinking.do/luengo/engine/logging"
+)
+
+// registerConsoleCommands adds the engine's Go commands to the Lua console
+func (g *Game) registerConsoleCommands() {
+	g.console.Register(console.Command{
+		Name:  "spawn",
+		Usage: "spawn <prefab> [x y] - instantiate a prefab (default: at the player)",
+		Run: func(args []string) (string, error) {
+			if len(args) != 1 && len(args) != 3 {
+				return "", fmt.Errorf("expected a prefab name and an optional position")
+			}
+			x, y := g.camera.Center()
+			if g.player != nil {
+				x, y = g.player.WorldPosition()
+			}
+			if len(args) == 3 {
+				var err error
+				if x, y, err = parsePosition(args[1], args[2]); err != nil {
+					return "", err
+				}
+			}
+			e, err := g.prefabManager.Instantiate(args[0], x, y)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("spawned %s (id %d) at %.0f,%.0f", e.Name, e.ID, x, y), nil
+		},
+	})
+
+	g.console.Register(console.Command{
+		Name:  "tp",
+		Usage: "tp <x> <y> [entity name or id] - teleport an entity (default: the player)",
+		Run: func(args []string) (string, error) {
+			if len(args) != 2 && len(args) != 3 {
+				return "", fmt.Errorf("expected a position and an optional entity")
+			}
+			x, y, err := parsePosition(args[0], args[1])
+			if err != nil {
+				return "", err
+			}
+			target := g.player
+			if len(args) == 3 {
+				target = g.findEntity(args[2])
+			}
+			if target == nil {
+				return "", fmt.Errorf("entity not found")
+			}
+			// Move by a world delta so children of rotated or scaled parents land on the target
+			worldX, worldY := target.WorldPosition()
+			target.MoveWorld(x-worldX, y-worldY)
+			return fmt.Sprintf("teleported %s to %.0f,%.0f", target.Name, x, y), nil
+		},
+	})
+
+	g.console.Register(console.Command{
+		Name:  "timescale",
+		Usage: "timescale [scale] - show or set the script update speed (0 pauses, max 10)",
+		Run: func(args []string) (string, error) {
+			if len(args) == 0 {
+				return fmt.Sprintf("timescale: %.2f", g.timeScale), nil
+			}
+			scale, err := strconv.ParseFloat(args[0], 64)
+			if err != nil || scale < 0 || scale > 10 {
+				return "", fmt.Errorf("scale must be a number between 0 and 10")
+			}
+			g.timeScale = scale
+			return fmt.Sprintf("timescale set to %.2f", scale), nil
+		},
+	})
+
+	g.console.Register(console.Command{
+		Name:  "mod",
+		Usage: "mod [enable|disable <id>] - list mods or switch one on or off",
+		Run: func(args []string) (string, error) {
+			if len(args) == 0 {
+				lines := make([]string, 0, len(g.scriptManager.Mods()))
+				for _, m := range g.scriptManager.Mods() {
+					status := "running"
+					if m.Disabled {
+						status = "disabled"
+					}
+					line := fmt.Sprintf("%s: %s, %d file(s)", m.ID, status, len(m.Files))
+					if m.LastError != nil {
+						line += fmt.Sprintf(", last error: %v", m.LastError)
+					}
+					lines = append(lines, line)
+				}
+				return strings.Join(lines, "\n"), nil
+			}
+			if len(args) != 2 {
+				return "", fmt.Errorf("expected enable or disable and a mod id")
+			}
+			var err error
+			switch args[0] {
+			case "enable":
+				err = g.scriptManager.EnableMod(args[1])
+			case "disable":
+				err = g.scriptManager.DisableMod(args[1])
+			default:
+				return "", fmt.Errorf("unknown action: %s", args[0])
+			}
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("mod %s %sd", args[1], args[0]), nil
+		},
+	})
+
+	g.console.Register(console.Command{
+		Name:  "logfile",
+		Usage: "logfile <path|off> - append log entries to a file",
+		Run: func(args []string) (string, error) {
+			if len(args) != 1 {
+				return "", fmt.Errorf("expected a path or off")
+			}
+			path := args[0]
+			if path == "off" {
+				path = ""
+			}
+			if err := logging.Default().SetOutputFile(path); err != nil {
+				return "", err
+			}
+			if path == "" {
+				return "file logging stopped", nil
+			}
+			return fmt.Sprintf("logging to %s", path), nil
+		},
+	})
+
+	g.console.Register(console.Command{
+		Name:  "loglevel",
+		Usage: "loglevel <debug|info|warn|error> - drop log entries below a level",
+		Run: func(args []string) (string, error) {
+			if len(args) != 1 {
+				return "", fmt.Errorf("expected a level")
+			}
+			level, err := logging.ParseLevel(args[0])
+			if err != nil {
+				return "", err
+			}
+			logging.Default().SetLevel(level)
+			return fmt.Sprintf("log level set to %s", level), nil
+		},
+	})
+}
+
+// findEntity looks an entity up by ID or, failing that, by name
+func (g *Game) findEntity(nameOrID string) *entity.Entity {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		if e, ok := g.entityManager.GetEntity(entity.ID(id)); ok {
+			return e
+		}
+	}
+	for _, e := range g.entityManager.GetHierarchyOrder() {
+		if e.Name == nameOrID {
+			return e
+		}
+	}
+	return nil
+}
+
+func parsePosition(xs, ys string) (float64, float64, error) {
+	x, errX := strconv.ParseFloat(xs, 64)
+	y, errY := strconv.ParseFloat(ys, 64)
+	if errX != nil || errY != nil {
+		return 0, 0, fmt.Errorf("invalid position %s,%s", xs, ys)
+	}
+	return x, y, nil
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"deepthinking.do/luengo/engine/logging"
+)
+
+// FileName is the config file read from the working directory when no other is given
+const FileName = "luengo.json"
+
+// Config holds the settings shared by the luengo subcommands. Values come from
+// the defaults, then the config file, then command-line flags. Paths are relative
+// to Root and are looked up in the game's virtual file system.
+type Config struct {
+	Root       string `json:"-"`    // Folder holding the config file; mounted as the game's files
+	Pack       string `json:"pack"` // Archive mounted below Root, e.g. one built by luengo pack
+	Embedded   fs.FS  `json:"-"`    // Files mounted below everything else, e.g. an embed.FS in a custom build
+	ModPath    string `json:"mod_path"`
+	AssetRoot  string `json:"asset_root"`
+	Title      string `json:"title"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Fullscreen bool   `json:"fullscreen"`
+	Scene      string `json:"scene"` // Scene loaded at startup and saved with F5; defaults to <mod_path>/scenes/main.scene
+	LogLevel   string `json:"log_level"`
+	TPS        int    `json:"tps"`              // Game updates per second
+	Debug      string `json:"debug"`            // Address of the Lua debugger (DAP) server; empty disables it
+	MemoryMB   int    `json:"memory_budget_mb"` // Memory unused assets may keep cached before the least recently used are evicted
+	HotReload  bool   `json:"hot_reload"`       // Reload cached images and sounds when their files change
+}
+
+func Default() Config {
+	return Config{
+		Root:      ".",
+		ModPath:   "mod",
+		AssetRoot: "assets",
+		Title:     "Luengo Engine - Modular Editor",
+		Width:     1200,
+		Height:    800,
+		LogLevel:  "debug",
+		TPS:       60,
+		MemoryMB:  256,
+		HotReload: true,
+	}
+}
+
+// Locate returns the config file to read when none was named: the one in the working
+// directory, or else the one next to the executable, so a shipped game starts from anywhere
+func Locate() string {
+	if _, err := os.Stat(FileName); err == nil {
+		return FileName
+	}
+	if exe, err := os.Executable(); err == nil {
+		path := filepath.Join(filepath.Dir(exe), FileName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return FileName
+}
+
+// Load reads a config file over the defaults and sets Root to its folder. A missing
+// file is not an error when optional is true, so luengo.json can be left out.
+func Load(path string, optional bool) (Config, error) {
+	cfg := Default()
+	cfg.Root = filepath.Dir(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if optional && errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ScenePath returns the scene to load and save
+func (c Config) ScenePath() string {
+	if c.Scene != "" {
+		return c.Scene
+	}
+	return filepath.ToSlash(filepath.Join(c.ModPath, "scenes", "main.scene"))
+}
+
+// DiskPath returns where a path relative to Root is on disk, e.g. to save a scene
+func (c Config) DiskPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.Root, path)
+}
+
+// Validate reports settings that cannot work
+func (c Config) Validate() error {
+	if c.Width <= 0 || c.Height <= 0 {
+		return fmt.Errorf("invalid window size %dx%d", c.Width, c.Height)
+	}
+	if c.TPS <= 0 {
+		return fmt.Errorf("invalid tps %d", c.TPS)
+	}
+	if c.MemoryMB <= 0 {
+		return fmt.Errorf("invalid memory budget %d MB", c.MemoryMB)
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		return err
+	}
+	for _, path := range []string{c.ModPath, c.AssetRoot, c.Scene} {
+		if filepath.IsAbs(path) {
+			return fmt.Errorf("%s must be relative to the game folder %s", path, c.Root)
+		}
+	}
+	return nil
+}
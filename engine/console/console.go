@@ -0,0 +1,279 @@
+package console
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font/basicfont"
+)
+
+const (
+	maxLines   = 200
+	maxHistory = 100
+	lineHeight = 14
+)
+
+// Evaluator runs Lua code typed into the console
+type Evaluator interface {
+	Eval(code string) ([]string, error)
+	Complete(prefix string) []string
+}
+
+// Command is a Go function callable from the console by name
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(args []string) (string, error)
+}
+
+type line struct {
+	text  string
+	color color.Color
+}
+
+// Console is a toggleable REPL that runs registered commands or evaluates Lua
+type Console struct {
+	open      bool
+	evaluator Evaluator
+	commands  map[string]Command
+
+	input   []rune
+	lines   []line
+	scroll  int
+	history []string
+	histPos int // Index into history while browsing, len(history) when editing a new line
+}
+
+func New(evaluator Evaluator) *Console {
+	c := &Console{
+		evaluator: evaluator,
+		commands:  make(map[string]Command),
+		lines:     make([]line, 0),
+		history:   make([]string, 0),
+	}
+	c.Register(Command{Name: "help", Usage: "help - list console commands", Run: c.help})
+	c.Register(Command{Name: "clear", Usage: "clear - clear console output", Run: func([]string) (string, error) {
+		c.lines = c.lines[:0]
+		return "", nil
+	}})
+	c.Print("Lua console - type Lua or a command, 'help' lists commands")
+	return c
+}
+
+// Register adds a Go command, replacing any command with the same name
+func (c *Console) Register(cmd Command) {
+	c.commands[cmd.Name] = cmd
+}
+
+func (c *Console) Toggle() {
+	c.open = !c.open
+}
+
+func (c *Console) IsOpen() bool {
+	return c.open
+}
+
+// Print appends an output line
+func (c *Console) Print(msg string) {
+	c.addLine(msg, color.RGBA{200, 200, 200, 255})
+}
+
+// PrintError appends an error line
+func (c *Console) PrintError(msg string) {
+	c.addLine(msg, color.RGBA{255, 110, 110, 255})
+}
+
+func (c *Console) addLine(msg string, clr color.Color) {
+	for _, l := range strings.Split(msg, "\n") {
+		c.lines = append(c.lines, line{text: l, color: clr})
+	}
+	if len(c.lines) > maxLines {
+		c.lines = c.lines[len(c.lines)-maxLines:]
+	}
+	c.scroll = 0
+}
+
+// Execute runs a command line: a registered command if the first word names one, Lua otherwise
+func (c *Console) Execute(input string) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return
+	}
+	c.addLine("> "+input, color.RGBA{140, 200, 255, 255})
+	if len(c.history) == 0 || c.history[len(c.history)-1] != input {
+		c.history = append(c.history, input)
+		if len(c.history) > maxHistory {
+			c.history = c.history[1:]
+		}
+	}
+	c.histPos = len(c.history)
+
+	fields := strings.Fields(input)
+	if cmd, ok := c.commands[fields[0]]; ok {
+		out, err := cmd.Run(fields[1:])
+		if err != nil {
+			c.PrintError(fmt.Sprintf("%s: %v", cmd.Name, err))
+			c.Print("usage: " + cmd.Usage)
+		} else if out != "" {
+			c.Print(out)
+		}
+		return
+	}
+
+	if c.evaluator == nil {
+		c.PrintError("no Lua state attached")
+		return
+	}
+	results, err := c.evaluator.Eval(input)
+	if err != nil {
+		c.PrintError(err.Error())
+		return
+	}
+	if len(results) > 0 {
+		c.Print(strings.Join(results, "\t"))
+	}
+}
+
+func (c *Console) help([]string) (string, error) {
+	names := make([]string, 0, len(c.commands))
+	for name := range c.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	usages := make([]string, len(names))
+	for i, name := range names {
+		usages[i] = "  " + c.commands[name].Usage
+	}
+	return "Commands:\n" + strings.Join(usages, "\n") + "\nAnything else is evaluated as Lua", nil
+}
+
+// complete tab-completes the word before the cursor against commands and Lua globals
+func (c *Console) complete() {
+	current := string(c.input)
+	start := strings.LastIndexFunc(current, func(r rune) bool {
+		return !(r == '_' || r == '.' || r == ':' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	}) + 1
+	word := current[start:]
+
+	candidates := make([]string, 0)
+	if start == 0 {
+		for name := range c.commands {
+			if strings.HasPrefix(name, word) {
+				candidates = append(candidates, name)
+			}
+		}
+	}
+	if c.evaluator != nil {
+		candidates = append(candidates, c.evaluator.Complete(word)...)
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	sort.Strings(candidates)
+
+	completion := candidates[0]
+	for _, candidate := range candidates[1:] {
+		completion = commonPrefix(completion, candidate)
+	}
+	if len(candidates) > 1 && completion == word {
+		c.Print(strings.Join(candidates, "  "))
+	}
+	c.input = []rune(current[:start] + completion)
+}
+
+func commonPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
+
+// repeating reports whether a held key should fire this frame, with key repeat
+func repeating(key ebiten.Key) bool {
+	d := inpututil.KeyPressDuration(key)
+	return d == 1 || (d > 30 && d%3 == 0)
+}
+
+// Update handles keyboard input while the console is open
+func (c *Console) Update() {
+	if !c.open {
+		return
+	}
+
+	for _, r := range ebiten.AppendInputChars(nil) {
+		if r != '`' && r != '~' {
+			c.input = append(c.input, r)
+		}
+	}
+
+	switch {
+	case repeating(ebiten.KeyBackspace) && len(c.input) > 0:
+		c.input = c.input[:len(c.input)-1]
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadEnter):
+		c.Execute(string(c.input))
+		c.input = c.input[:0]
+	case inpututil.IsKeyJustPressed(ebiten.KeyTab):
+		c.complete()
+	case repeating(ebiten.KeyArrowUp) && c.histPos > 0:
+		c.histPos--
+		c.input = []rune(c.history[c.histPos])
+	case repeating(ebiten.KeyArrowDown) && c.histPos < len(c.history):
+		c.histPos++
+		if c.histPos == len(c.history) {
+			c.input = c.input[:0]
+		} else {
+			c.input = []rune(c.history[c.histPos])
+		}
+	case repeating(ebiten.KeyPageUp):
+		c.scroll += 5
+	case repeating(ebiten.KeyPageDown):
+		c.scroll -= 5
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		c.open = false
+	}
+
+	if c.scroll > len(c.lines)-1 {
+		c.scroll = len(c.lines) - 1
+	}
+	if c.scroll < 0 {
+		c.scroll = 0
+	}
+}
+
+// Draw draws the console over the top half of the screen
+func (c *Console) Draw(screen *ebiten.Image, screenWidth, screenHeight int) {
+	if !c.open {
+		return
+	}
+
+	height := screenHeight / 2
+	bg := ebiten.NewImage(screenWidth, height)
+	bg.Fill(color.RGBA{10, 10, 15, 235})
+	screen.DrawImage(bg, nil)
+
+	// Input line
+	prompt := "> " + string(c.input) + "_"
+	text.Draw(screen, prompt, basicfont.Face7x13, 10, height-10, color.White)
+
+	// Output, newest at the bottom
+	visible := (height - 30) / lineHeight
+	end := len(c.lines) - c.scroll
+	start := end - visible
+	if start < 0 {
+		start = 0
+	}
+	for i, l := range c.lines[start:end] {
+		y := height - 30 - (end-start-1-i)*lineHeight
+		text.Draw(screen, l.text, basicfont.Face7x13, 10, y, l.color)
+	}
+
+	if c.scroll > 0 {
+		text.Draw(screen, fmt.Sprintf("-- scrolled %d lines (PgDn) --", c.scroll), basicfont.Face7x13, screenWidth-260, height-10, color.RGBA{150, 150, 150, 255})
+	}
+}
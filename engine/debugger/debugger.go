@@ -0,0 +1,274 @@
+package debugger
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"deepthinking.do/luengo/engine/logging"
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+type stepMode int
+
+const (
+	stepNone stepMode = iota
+	stepIn
+	stepOver
+	stepOut
+)
+
+// Debugger is a Debug Adapter Protocol server for the engine's Lua state. Scripts
+// compiled with CompileFile report each line they run; when a breakpoint or step
+// hits, the game loop blocks inside the hook until the client resumes it.
+type Debugger struct {
+	addr     string
+	listener net.Listener
+	luaState *lua.LState
+	files    *vfs.FS // Maps script paths to the files clients open; nil means the working directory
+
+	// Shared with the connection goroutine
+	lock           sync.Mutex
+	client         *conn
+	breakpoints    map[string]map[int]bool // Absolute path -> lines
+	lines          map[string][]int        // Absolute path -> lines that hold a statement
+	step           stepMode
+	stepDepth      int
+	pauseRequested bool
+
+	// Work for the game goroutine, which owns the Lua state
+	commands chan func()
+
+	// Only touched on the game goroutine
+	paused   bool
+	depth    int // Stack depth of the frame we are stopped in
+	handles  []handle
+	absPaths map[string]string
+}
+
+func New(addr string) *Debugger {
+	return &Debugger{
+		addr:        addr,
+		breakpoints: make(map[string]map[int]bool),
+		lines:       make(map[string][]int),
+		commands:    make(chan func()),
+		absPaths:    make(map[string]string),
+	}
+}
+
+// Attach registers the line hook in a Lua state
+func (d *Debugger) Attach(L *lua.LState) {
+	d.luaState = L
+	L.SetGlobal(lineHook, L.NewFunction(d.hook))
+}
+
+// SetFiles sets the file system scripts are loaded from, so breakpoints set on the
+// files behind it match the scripts
+func (d *Debugger) SetFiles(files *vfs.FS) {
+	d.files = files
+}
+
+// Start listens for a debug client in the background
+func (d *Debugger) Start() error {
+	listener, err := net.Listen("tcp", d.addr)
+	if err != nil {
+		return fmt.Errorf("failed to start debugger on %s: %w", d.addr, err)
+	}
+	d.listener = listener
+	logging.Infof("debugger", "Listening for DAP clients on %s", listener.Addr())
+	go d.acceptLoop()
+	return nil
+}
+
+func (d *Debugger) Close() {
+	if d.listener != nil {
+		d.listener.Close()
+	}
+}
+
+// Update runs requests that need the Lua state while the game is not stopped.
+// Call it once per frame from the game loop.
+func (d *Debugger) Update() {
+	for {
+		select {
+		case cmd := <-d.commands:
+			cmd()
+		default:
+			return
+		}
+	}
+}
+
+// run executes fn on the game goroutine and waits for it to finish
+func (d *Debugger) run(fn func()) {
+	done := make(chan struct{})
+	d.commands <- func() {
+		fn()
+		close(done)
+	}
+	<-done
+}
+
+func (d *Debugger) setLines(path string, lines map[int]bool) {
+	abs := d.absPath(path)
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.lines[abs] = sortedLines(lines)
+}
+
+// absPath returns the absolute form of a chunk name, which is how clients name files
+func (d *Debugger) absPath(path string) string {
+	if abs, ok := d.absPaths[path]; ok {
+		return abs
+	}
+	file := path
+	if d.files != nil {
+		if origin, ok := d.files.Origin(path); ok {
+			file = origin
+		}
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		abs = path
+	}
+	abs = filepath.Clean(abs)
+	d.absPaths[path] = abs
+	return abs
+}
+
+// hook is called by instrumented scripts before each statement
+func (d *Debugger) hook(L *lua.LState) int {
+	line := L.CheckInt(1)
+	if d.paused {
+		// Code run by an evaluate request while stopped
+		return 0
+	}
+
+	d.lock.Lock()
+	if d.client == nil {
+		d.lock.Unlock()
+		return 0
+	}
+	step, stepDepth, pause := d.step, d.stepDepth, d.pauseRequested
+	var hit bool
+	if len(d.breakpoints) > 0 {
+		if dbg, ok := L.GetStack(1); ok {
+			if _, err := L.GetInfo("S", dbg, lua.LNil); err == nil {
+				hit = d.breakpoints[d.absPath(dbg.Source)][line]
+			}
+		}
+	}
+	d.lock.Unlock()
+
+	reason := ""
+	switch {
+	case hit:
+		reason = "breakpoint"
+	case pause:
+		reason = "pause"
+	case step == stepIn:
+		reason = "step"
+	case step == stepOver && stackDepth(L) <= stepDepth:
+		reason = "step"
+	case step == stepOut && stackDepth(L) < stepDepth:
+		reason = "step"
+	}
+	if reason != "" {
+		d.stop(L, reason)
+	}
+	return 0
+}
+
+// stackDepth counts the frames below the hook
+func stackDepth(L *lua.LState) int {
+	depth := 0
+	for {
+		if _, ok := L.GetStack(depth + 1); !ok {
+			return depth
+		}
+		depth++
+	}
+}
+
+// stop blocks the game loop, serving client requests until execution resumes
+func (d *Debugger) stop(L *lua.LState, reason string) {
+	d.lock.Lock()
+	d.step = stepNone
+	d.pauseRequested = false
+	client := d.client
+	d.lock.Unlock()
+	if client == nil {
+		return
+	}
+
+	d.paused = true
+	d.depth = stackDepth(L)
+	d.handles = d.handles[:0]
+	client.event("stopped", map[string]interface{}{
+		"reason":            reason,
+		"threadId":          1,
+		"allThreadsStopped": true,
+	})
+	logging.Debugf("debugger", "Paused (%s)", reason)
+
+	for d.paused {
+		cmd := <-d.commands
+		cmd()
+	}
+}
+
+// resume continues execution, optionally stepping; it runs on the game goroutine
+func (d *Debugger) resume(mode stepMode) {
+	if !d.paused {
+		return
+	}
+	d.lock.Lock()
+	d.step = mode
+	d.stepDepth = d.depth
+	d.lock.Unlock()
+	d.paused = false
+}
+
+// setBreakpoints replaces the breakpoints of a file, moving each to the next line with a statement
+func (d *Debugger) setBreakpoints(path string, requested []int) []breakpoint {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	abs := filepath.Clean(path)
+	if a, err := filepath.Abs(path); err == nil {
+		abs = filepath.Clean(a)
+	}
+	lines, loaded := d.lines[abs]
+
+	set := make(map[int]bool)
+	result := make([]breakpoint, 0, len(requested))
+	for _, line := range requested {
+		if !loaded {
+			set[line] = true
+			result = append(result, breakpoint{Verified: false, Line: line, Message: "file is not loaded with debugging enabled"})
+			continue
+		}
+		actual := nextLine(lines, line)
+		if actual == 0 {
+			result = append(result, breakpoint{Verified: false, Line: line, Message: "no statement at or after this line"})
+			continue
+		}
+		set[actual] = true
+		result = append(result, breakpoint{Verified: true, Line: actual})
+	}
+	d.breakpoints[abs] = set
+	return result
+}
+
+// nextLine returns the first statement line at or after line, or 0
+func nextLine(lines []int, line int) int {
+	for _, l := range lines {
+		if l >= line {
+			return l
+		}
+	}
+	return 0
+}
@@ -0,0 +1,254 @@
+package debugger
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+type handleKind int
+
+const (
+	handleLocals handleKind = iota
+	handleUpvalues
+	handleTable
+)
+
+// handle is what a variablesReference points at; handles are valid until execution resumes
+type handle struct {
+	kind  handleKind
+	level int
+	table *lua.LTable
+}
+
+func (d *Debugger) newHandle(h handle) int {
+	d.handles = append(d.handles, h)
+	return len(d.handles)
+}
+
+// stackTrace lists the Lua frames below the hook; frame ids are stack levels
+func (d *Debugger) stackTrace() []stackFrame {
+	L := d.luaState
+	frames := make([]stackFrame, 0)
+	for level := 1; ; level++ {
+		dbg, ok := L.GetStack(level)
+		if !ok {
+			break
+		}
+		if _, err := L.GetInfo("nSl", dbg, lua.LNil); err != nil || dbg.What == "G" {
+			continue
+		}
+		// gopher-lua names every frame called from Go "main chunk", hooks like on_update included
+		name := dbg.Name
+		if (name == "" || name == "?" || name == "main chunk") && dbg.LineDefined > 0 {
+			name = fmt.Sprintf("function <%s:%d>", filepath.Base(dbg.Source), dbg.LineDefined)
+		}
+		path := d.absPath(dbg.Source)
+		frames = append(frames, stackFrame{
+			ID:     level,
+			Name:   name,
+			Source: &source{Name: filepath.Base(path), Path: path},
+			Line:   dbg.CurrentLine,
+			Column: 1,
+		})
+	}
+	return frames
+}
+
+// frameFunction returns the function running at a stack level
+func (d *Debugger) frameFunction(level int) (*lua.Debug, *lua.LFunction) {
+	dbg, ok := d.luaState.GetStack(level)
+	if !ok {
+		return nil, nil
+	}
+	fn, err := d.luaState.GetInfo("f", dbg, lua.LNil)
+	if err != nil {
+		return dbg, nil
+	}
+	lfn, _ := fn.(*lua.LFunction)
+	return dbg, lfn
+}
+
+func (d *Debugger) scopes(level int) []scope {
+	_, fn := d.frameFunction(level)
+	scopes := []scope{
+		{Name: "Locals", VariablesReference: d.newHandle(handle{kind: handleLocals, level: level})},
+		{Name: "Upvalues", VariablesReference: d.newHandle(handle{kind: handleUpvalues, level: level})},
+	}
+	globals := d.luaState.G.Global
+	if fn != nil && fn.Env != globals {
+		scopes = append(scopes, scope{Name: "Mod globals", VariablesReference: d.newHandle(handle{kind: handleTable, table: fn.Env}), Expensive: true})
+	}
+	return append(scopes, scope{Name: "Globals", VariablesReference: d.newHandle(handle{kind: handleTable, table: globals}), Expensive: true})
+}
+
+// locals returns the named locals of the function at a stack level, innermost last
+func (d *Debugger) locals(level int) ([]string, []lua.LValue) {
+	dbg, _ := d.frameFunction(level)
+	if dbg == nil {
+		return nil, nil
+	}
+	var names []string
+	var values []lua.LValue
+	for i := 1; ; i++ {
+		name, value := d.luaState.GetLocal(dbg, i)
+		if name == "" {
+			break
+		}
+		if strings.HasPrefix(name, "(") {
+			continue
+		}
+		names = append(names, name)
+		values = append(values, value)
+	}
+	return names, values
+}
+
+func (d *Debugger) upvalues(level int) ([]string, []lua.LValue) {
+	_, fn := d.frameFunction(level)
+	if fn == nil {
+		return nil, nil
+	}
+	var names []string
+	var values []lua.LValue
+	for i := 1; ; i++ {
+		name, value := d.luaState.GetUpvalue(fn, i)
+		if name == "" {
+			break
+		}
+		names = append(names, name)
+		values = append(values, value)
+	}
+	return names, values
+}
+
+func (d *Debugger) variables(ref int) []variable {
+	vars := make([]variable, 0)
+	if ref < 1 || ref > len(d.handles) {
+		return vars
+	}
+
+	h := d.handles[ref-1]
+	var names []string
+	var values []lua.LValue
+	switch h.kind {
+	case handleLocals:
+		names, values = d.locals(h.level)
+	case handleUpvalues:
+		names, values = d.upvalues(h.level)
+	case handleTable:
+		names, values = tableFields(h.table)
+	}
+	for i, name := range names {
+		vars = append(vars, d.variable(name, values[i]))
+	}
+	return vars
+}
+
+// tableFields returns a table's fields sorted by key
+func tableFields(t *lua.LTable) ([]string, []lua.LValue) {
+	type field struct {
+		name  string
+		value lua.LValue
+	}
+	fields := make([]field, 0)
+	t.ForEach(func(key, value lua.LValue) {
+		name := key.String()
+		if key.Type() == lua.LTNumber {
+			name = "[" + name + "]"
+		}
+		fields = append(fields, field{name, value})
+	})
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+
+	names := make([]string, len(fields))
+	values := make([]lua.LValue, len(fields))
+	for i, f := range fields {
+		names[i], values[i] = f.name, f.value
+	}
+	return names, values
+}
+
+// variable describes a value; tables get a handle so the client can expand them
+func (d *Debugger) variable(name string, value lua.LValue) variable {
+	v := variable{Name: name, Value: formatValue(value), Type: value.Type().String()}
+	if t, ok := value.(*lua.LTable); ok && d.paused {
+		v.VariablesReference = d.newHandle(handle{kind: handleTable, table: t})
+	}
+	return v
+}
+
+func formatValue(value lua.LValue) string {
+	switch v := value.(type) {
+	case lua.LString:
+		return fmt.Sprintf("%q", string(v))
+	case *lua.LTable:
+		return fmt.Sprintf("table (%d array, %d total)", v.Len(), tableSize(v))
+	default:
+		return value.String()
+	}
+}
+
+func tableSize(t *lua.LTable) int {
+	n := 0
+	t.ForEach(func(lua.LValue, lua.LValue) { n++ })
+	return n
+}
+
+// evaluate runs an expression or statement. At a stack level, the frame's locals and
+// upvalues are visible; assignments to them are not written back.
+func (d *Debugger) evaluate(code string, level int) (variable, error) {
+	L := d.luaState
+
+	env := L.G.Global
+	if d.paused && level > 0 {
+		_, fn := d.frameFunction(level)
+		scope := L.NewTable()
+		meta := L.NewTable()
+		if fn != nil {
+			meta.RawSetString("__index", fn.Env)
+		} else {
+			meta.RawSetString("__index", env)
+		}
+		L.SetMetatable(scope, meta)
+		names, values := d.upvalues(level)
+		for i, name := range names {
+			scope.RawSetString(name, values[i])
+		}
+		names, values = d.locals(level)
+		for i, name := range names {
+			scope.RawSetString(name, values[i])
+		}
+		env = scope
+	}
+
+	fn, err := L.LoadString("return " + code)
+	if err != nil {
+		if fn, err = L.LoadString(code); err != nil {
+			return variable{}, err
+		}
+	}
+	fn.Env = env
+
+	top := L.GetTop()
+	L.Push(fn)
+	if err := L.PCall(0, lua.MultRet, nil); err != nil {
+		return variable{}, err
+	}
+	results := make([]string, 0)
+	var first lua.LValue = lua.LNil
+	for i := top + 1; i <= L.GetTop(); i++ {
+		if i == top+1 {
+			first = L.Get(i)
+		}
+		results = append(results, formatValue(L.Get(i)))
+	}
+	L.SetTop(top)
+
+	v := d.variable("", first)
+	v.Value = strings.Join(results, ", ")
+	return v, nil
+}
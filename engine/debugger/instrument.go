@@ -0,0 +1,149 @@
+package debugger
+
+import (
+	"io"
+	"sort"
+	"strconv"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// lineHook is the global called before every statement of an instrumented script
+const lineHook = "__luengo_line"
+
+// Compile loads a script with a line hook call inserted before each statement.
+// gopher-lua has no debug hooks, so this is how the debugger sees which line runs next.
+func (d *Debugger) Compile(L *lua.LState, source io.Reader, path string) (*lua.LFunction, error) {
+	chunk, err := parse.Parse(source, path)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make(map[int]bool)
+	chunk = instrumentBlock(chunk, lines)
+	proto, err := lua.Compile(chunk, path)
+	if err != nil {
+		return nil, err
+	}
+
+	d.setLines(path, lines)
+	return L.NewFunctionFromProto(proto), nil
+}
+
+// instrumentBlock returns the statements with a hook call before each one, recording their lines
+func instrumentBlock(stmts []ast.Stmt, lines map[int]bool) []ast.Stmt {
+	result := make([]ast.Stmt, 0, len(stmts)*2)
+	for _, stmt := range stmts {
+		instrumentStmt(stmt, lines)
+		lines[stmt.Line()] = true
+		result = append(result, hookCall(stmt.Line()), stmt)
+	}
+	return result
+}
+
+// hookCall builds the statement __luengo_line(line)
+func hookCall(line int) ast.Stmt {
+	fn := &ast.IdentExpr{Value: lineHook}
+	arg := &ast.NumberExpr{Value: strconv.Itoa(line)}
+	call := &ast.FuncCallExpr{Func: fn, Args: []ast.Expr{arg}}
+	stmt := &ast.FuncCallStmt{Expr: call}
+	for _, node := range []ast.PositionHolder{fn, arg, call, stmt} {
+		node.SetLine(line)
+		node.SetLastLine(line)
+	}
+	return stmt
+}
+
+func instrumentStmt(stmt ast.Stmt, lines map[int]bool) {
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		instrumentExprs(s.Lhs, lines)
+		instrumentExprs(s.Rhs, lines)
+	case *ast.LocalAssignStmt:
+		instrumentExprs(s.Exprs, lines)
+	case *ast.FuncCallStmt:
+		instrumentExpr(s.Expr, lines)
+	case *ast.DoBlockStmt:
+		s.Stmts = instrumentBlock(s.Stmts, lines)
+	case *ast.WhileStmt:
+		instrumentExpr(s.Condition, lines)
+		s.Stmts = instrumentBlock(s.Stmts, lines)
+	case *ast.RepeatStmt:
+		instrumentExpr(s.Condition, lines)
+		s.Stmts = instrumentBlock(s.Stmts, lines)
+	case *ast.IfStmt:
+		instrumentExpr(s.Condition, lines)
+		s.Then = instrumentBlock(s.Then, lines)
+		s.Else = instrumentElse(s.Else, lines)
+	case *ast.NumberForStmt:
+		instrumentExprs([]ast.Expr{s.Init, s.Limit, s.Step}, lines)
+		s.Stmts = instrumentBlock(s.Stmts, lines)
+	case *ast.GenericForStmt:
+		instrumentExprs(s.Exprs, lines)
+		s.Stmts = instrumentBlock(s.Stmts, lines)
+	case *ast.FuncDefStmt:
+		instrumentExpr(s.Func, lines)
+	case *ast.ReturnStmt:
+		instrumentExprs(s.Exprs, lines)
+	}
+}
+
+// instrumentElse leaves an elseif chain (a lone nested if) in place so it keeps its own condition line
+func instrumentElse(stmts []ast.Stmt, lines map[int]bool) []ast.Stmt {
+	if len(stmts) == 1 {
+		if elseif, ok := stmts[0].(*ast.IfStmt); ok {
+			instrumentStmt(elseif, lines)
+			return stmts
+		}
+	}
+	return instrumentBlock(stmts, lines)
+}
+
+func instrumentExprs(exprs []ast.Expr, lines map[int]bool) {
+	for _, expr := range exprs {
+		instrumentExpr(expr, lines)
+	}
+}
+
+// instrumentExpr finds function bodies nested inside an expression
+func instrumentExpr(expr ast.Expr, lines map[int]bool) {
+	switch e := expr.(type) {
+	case *ast.FunctionExpr:
+		e.Stmts = instrumentBlock(e.Stmts, lines)
+	case *ast.FuncCallExpr:
+		instrumentExprs([]ast.Expr{e.Func, e.Receiver}, lines)
+		instrumentExprs(e.Args, lines)
+	case *ast.AttrGetExpr:
+		instrumentExprs([]ast.Expr{e.Object, e.Key}, lines)
+	case *ast.TableExpr:
+		for _, field := range e.Fields {
+			instrumentExprs([]ast.Expr{field.Key, field.Value}, lines)
+		}
+	case *ast.LogicalOpExpr:
+		instrumentExprs([]ast.Expr{e.Lhs, e.Rhs}, lines)
+	case *ast.RelationalOpExpr:
+		instrumentExprs([]ast.Expr{e.Lhs, e.Rhs}, lines)
+	case *ast.StringConcatOpExpr:
+		instrumentExprs([]ast.Expr{e.Lhs, e.Rhs}, lines)
+	case *ast.ArithmeticOpExpr:
+		instrumentExprs([]ast.Expr{e.Lhs, e.Rhs}, lines)
+	case *ast.UnaryMinusOpExpr:
+		instrumentExpr(e.Expr, lines)
+	case *ast.UnaryNotOpExpr:
+		instrumentExpr(e.Expr, lines)
+	case *ast.UnaryLenOpExpr:
+		instrumentExpr(e.Expr, lines)
+	}
+}
+
+// sortedLines returns the statement lines of a file in ascending order
+func sortedLines(lines map[int]bool) []int {
+	result := make([]int, 0, len(lines))
+	for line := range lines {
+		result = append(result, line)
+	}
+	sort.Ints(result)
+	return result
+}
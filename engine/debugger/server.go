@@ -0,0 +1,174 @@
+package debugger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+
+	"deepthinking.do/luengo/engine/logging"
+)
+
+// acceptLoop serves one client at a time until the listener is closed
+func (d *Debugger) acceptLoop() {
+	for {
+		c, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		logging.Infof("debugger", "Client connected from %s", c.RemoteAddr())
+		d.serve(c)
+		logging.Infof("debugger", "Client disconnected")
+	}
+}
+
+func (d *Debugger) serve(nc net.Conn) {
+	defer nc.Close()
+	client := newConn(nc)
+
+	d.lock.Lock()
+	d.client = client
+	d.lock.Unlock()
+	defer d.disconnect()
+
+	for {
+		req, err := client.read()
+		if err != nil {
+			if err != io.EOF {
+				logging.Warnf("debugger", "Read failed: %v", err)
+			}
+			return
+		}
+		body, after, err := d.handle(req)
+		if err := client.respond(req, body, err); err != nil {
+			logging.Warnf("debugger", "Write failed: %v", err)
+			return
+		}
+		if after != nil {
+			// Resume only after responding so the client sees the reply before the next stop
+			d.run(after)
+		}
+		switch req.Command {
+		case "initialize":
+			client.event("initialized", nil)
+		case "disconnect":
+			return
+		}
+	}
+}
+
+// disconnect forgets the client and lets the game run freely again
+func (d *Debugger) disconnect() {
+	d.lock.Lock()
+	d.client = nil
+	d.breakpoints = make(map[string]map[int]bool)
+	d.step = stepNone
+	d.pauseRequested = false
+	d.lock.Unlock()
+	d.run(func() { d.resume(stepNone) })
+}
+
+// handle answers one request, returning the response body and, for requests that
+// resume execution, the work to run on the game goroutine once the response is sent
+func (d *Debugger) handle(req *request) (interface{}, func(), error) {
+	switch req.Command {
+	case "initialize":
+		return map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+		}, nil, nil
+
+	case "launch", "attach", "configurationDone", "setExceptionBreakpoints", "disconnect":
+		return nil, nil, nil
+
+	case "setBreakpoints":
+		var args struct {
+			Source      source `json:"source"`
+			Breakpoints []struct {
+				Line int `json:"line"`
+			} `json:"breakpoints"`
+		}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, nil, err
+		}
+		lines := make([]int, len(args.Breakpoints))
+		for i, bp := range args.Breakpoints {
+			lines[i] = bp.Line
+		}
+		return map[string]interface{}{"breakpoints": d.setBreakpoints(args.Source.Path, lines)}, nil, nil
+
+	case "threads":
+		return map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": 1, "name": "Lua"}},
+		}, nil, nil
+
+	case "stackTrace":
+		frames := []stackFrame{}
+		d.run(func() {
+			if d.paused {
+				frames = d.stackTrace()
+			}
+		})
+		return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil, nil
+
+	case "scopes":
+		var args struct {
+			FrameID int `json:"frameId"`
+		}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, nil, err
+		}
+		scopes := []scope{}
+		d.run(func() {
+			if d.paused {
+				scopes = d.scopes(args.FrameID)
+			}
+		})
+		return map[string]interface{}{"scopes": scopes}, nil, nil
+
+	case "variables":
+		var args struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, nil, err
+		}
+		var vars []variable
+		d.run(func() { vars = d.variables(args.VariablesReference) })
+		return map[string]interface{}{"variables": vars}, nil, nil
+
+	case "evaluate":
+		var args struct {
+			Expression string `json:"expression"`
+			FrameID    int    `json:"frameId"`
+		}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, nil, err
+		}
+		var result variable
+		var err error
+		d.run(func() { result, err = d.evaluate(args.Expression, args.FrameID) })
+		if err != nil {
+			return nil, nil, err
+		}
+		return map[string]interface{}{"result": result.Value, "type": result.Type, "variablesReference": result.VariablesReference}, nil, nil
+
+	case "continue":
+		return map[string]interface{}{"allThreadsContinued": true}, func() { d.resume(stepNone) }, nil
+	case "next":
+		return nil, func() { d.resume(stepOver) }, nil
+	case "stepIn":
+		return nil, func() { d.resume(stepIn) }, nil
+	case "stepOut":
+		return nil, func() { d.resume(stepOut) }, nil
+
+	case "pause":
+		d.lock.Lock()
+		d.pauseRequested = true
+		d.lock.Unlock()
+		return nil, nil, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported request: %s", req.Command)
+	}
+}
@@ -2,67 +2,140 @@ package engine
 
 import (
 	"fmt"
+	"image"
 	"image/color"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 
 	"deepthinking.do/luengo/engine/audio"
 	"deepthinking.do/luengo/engine/camera"
+	"deepthinking.do/luengo/engine/config"
+	"deepthinking.do/luengo/engine/console"
+	"deepthinking.do/luengo/engine/debugger"
 	"deepthinking.do/luengo/engine/entity"
 	"deepthinking.do/luengo/engine/input"
+	"deepthinking.do/luengo/engine/logging"
+	"deepthinking.do/luengo/engine/particles"
+	"deepthinking.do/luengo/engine/prefab"
+	"deepthinking.do/luengo/engine/render"
 	"deepthinking.do/luengo/engine/resources"
+	"deepthinking.do/luengo/engine/scene"
 	"deepthinking.do/luengo/engine/scripting"
 	"deepthinking.do/luengo/engine/ui"
+	"deepthinking.do/luengo/engine/vfs"
 )
 
 type Game struct {
+	config config.Config
+	files  *vfs.FS // Every loader reads through this
+
 	// Core systems
 	entityManager   *entity.Manager
-	camera          camera.Camera
+	cameras         *camera.Manager
+	camera          *camera.Camera // The main camera, which the editor controls
+	layers          *render.Layers
+	post            *render.PostChain
+	lighting        *render.Lighting
+	particles       *particles.Manager
 	inputManager    *input.Manager
 	audioManager    *audio.Manager
 	scriptManager   *scripting.Manager
+	scriptDraw      scripting.DrawFrame // What the draw hooks recorded for this frame
 	resourceManager *resources.Manager
+	prefabManager   *prefab.Manager
 	ui              *ui.EditorUI
+	console         *console.Console
+	debugger        *debugger.Debugger // Nil unless EnableDebugger was called
+	sceneChange     *sceneChange       // Scene waiting for its sprites, nil when none
 
 	// Game state
 	player     *entity.Entity
 	started    bool
 	frame      int
 	editorMode bool
+	headless   bool // No window: sizes are fixed and input is simulated
+
+	// Scales how many script updates run per frame (1 = normal speed, 0 = paused)
+	timeScale       float64
+	timeAccumulator float64
 
 	// Editor state
-	isDragging bool
-	dragStart  struct{ X, Y int }
-	dragEntity *entity.Entity
+	mouseOverLog bool
+	isDragging   bool
+	dragStart    struct{ X, Y int }
+	dragEntity   *entity.Entity
 
 	// Window state
 	screenWidth, screenHeight int
 }
 
-func NewGame() *Game {
+// NewGame creates a game in editor mode from the given settings
+func NewGame(cfg config.Config) *Game {
+	files := OpenFiles(cfg)
+
 	// Initialize managers
 	entityManager := entity.NewManager()
 	inputManager := input.NewManager()
-	audioManager := audio.NewManager()
-	resourceManager := resources.NewManager()
-	scriptManager := scripting.NewManager(audioManager)
+	resourceManager := resources.NewManager(files)
+	resourceManager.SetBudget(int64(cfg.MemoryMB) << 20)
+	audioManager := audio.NewManager(resourceManager)
+	prefabManager := prefab.NewManager(entityManager, resourceManager, files)
+	scriptManager := scripting.NewManager(audioManager, inputManager, files)
+	scriptManager.SetResources(resourceManager)
+	cameras := camera.NewManager()
+	layers := render.NewLayers()
 	ui := ui.NewEditorUI()
+	ui.SetLayers(layers)
+	ui.SetResources(resourceManager)
+	ui.SetFiles(files)
+	ui.SetAssetRoot(cfg.AssetRoot)
 
 	return &Game{
+		config:          cfg,
+		files:           files,
 		entityManager:   entityManager,
-		camera:          camera.NewCamera(),
+		cameras:         cameras,
+		camera:          cameras.Main(),
+		layers:          layers,
+		post:            render.NewPostChain(),
+		lighting:        render.NewLighting(),
+		particles:       particles.NewManager(entityManager, resourceManager, files),
 		inputManager:    inputManager,
 		audioManager:    audioManager,
 		scriptManager:   scriptManager,
 		resourceManager: resourceManager,
+		prefabManager:   prefabManager,
 		ui:              ui,
+		console:         console.New(scriptManager),
 		editorMode:      true,
-		screenWidth:     1200,
-		screenHeight:    800,
+		timeScale:       1.0,
+		screenWidth:     cfg.Width,
+		screenHeight:    cfg.Height,
 	}
 }
 
+// SetEditorMode chooses between the editor and play mode, e.g. before the first frame
+func (g *Game) SetEditorMode(editor bool) {
+	g.editorMode = editor
+}
+
+// EnableDebugger starts a Debug Adapter Protocol server on addr, e.g. "localhost:4711".
+// It must be called before Initialize so scripts are loaded with line hooks.
+func (g *Game) EnableDebugger(addr string) error {
+	d := debugger.New(addr)
+	d.SetFiles(g.files)
+	if err := d.Start(); err != nil {
+		return err
+	}
+	g.debugger = d
+	g.scriptManager.EnableDebugger(d)
+	return nil
+}
+
 func (g *Game) Initialize() error {
 	// Initialize all systems
 	g.inputManager.Initialize()
@@ -71,10 +144,15 @@ func (g *Game) Initialize() error {
 		return fmt.Errorf("failed to initialize audio: %w", err)
 	}
 
+	if g.config.HotReload && !g.headless {
+		g.resourceManager.Watch(g.config.AssetRoot)
+	}
+
 	// Load player sprite
-	playerSprite, err := g.resourceManager.LoadSprite("assets/sprites/player.png")
+	playerSpritePath := path.Join(vfs.Clean(g.config.AssetRoot), "sprites", "player.png")
+	playerSprite, err := g.resourceManager.LoadSprite(playerSpritePath)
 	if err != nil {
-		fmt.Printf("Warning: Could not load player sprite: %v\n", err)
+		logging.Warnf("engine", "Could not load player sprite: %v", err)
 		// Create a simple colored rectangle as fallback
 		playerSprite = ebiten.NewImage(32, 32)
 		playerSprite.Fill(color.RGBA{0, 255, 0, 255})
@@ -82,24 +160,66 @@ func (g *Game) Initialize() error {
 
 	// Create player entity
 	g.player = g.entityManager.CreateEntity("Player", playerSprite)
+	if err == nil {
+		g.player.SpritePath = playerSpritePath
+	}
 	g.player.Position.X = 100
 	g.player.Position.Y = 100
 
+	g.followPlayer()
+
 	// Create some test entities
 	g.createTestEntities(playerSprite)
 
+	// Load prefab definitions
+	if err := g.prefabManager.LoadFromFolder(g.config.ModPath); err != nil {
+		logging.Warnf("engine", "Could not load prefabs: %v", err)
+	}
+	g.ui.SetPrefabNames(g.prefabManager.Names())
+
+	// Load particle definitions
+	if err := g.particles.LoadFromFolder(g.config.AssetRoot); err != nil {
+		logging.Warnf("engine", "Could not load particle effects: %v", err)
+	}
+
 	// Register Lua functions and load scripts
 	g.scriptManager.RegisterGameFunctions(g.entityManager, g.player)
-	if err := g.scriptManager.LoadScriptsFromFolder("mod"); err != nil {
-		fmt.Printf("Warning: Could not load scripts: %v\n", err)
+	g.scriptManager.RegisterPrefabFunctions(g.prefabManager)
+	g.scriptManager.RegisterLoadingFunctions(g.resourceManager, g)
+	g.scriptManager.RegisterCameraFunctions(g.cameras, g.entityManager, g.resourceManager)
+	g.scriptManager.RegisterLayerFunctions(g.layers, g.entityManager)
+	g.scriptManager.RegisterSpriteFunctions(g.resourceManager, g.entityManager)
+	g.scriptManager.RegisterShaderFunctions(g.resourceManager, g.entityManager, g.post)
+	g.scriptManager.RegisterLightingFunctions(g.lighting, g.entityManager)
+	g.scriptManager.RegisterParticleFunctions(g.particles, g.entityManager)
+	g.scriptManager.RegisterDrawFunctions(g.resourceManager)
+	if err := g.scriptManager.LoadScriptsFromFolder(g.config.ModPath); err != nil {
+		logging.Warnf("engine", "Could not load scripts: %v", err)
+	}
+	g.registerConsoleCommands()
+
+	// A scene chosen in the config replaces the default entities
+	if g.config.Scene != "" {
+		if err := g.loadScene(g.config.Scene); err != nil {
+			logging.Warnf("engine", "Could not load scene: %v", err)
+		}
 	}
 
 	// Initial log messages
+	mode := "editor"
+	if !g.editorMode {
+		mode = "play"
+	}
 	g.ui.AddLogMessage("Luengo Engine initialized (Modular)", g.frame)
-	g.ui.AddLogMessage("Started in editor mode", g.frame)
+	g.ui.AddLogMessage(fmt.Sprintf("Started in %s mode", mode), g.frame)
 	g.ui.AddLogMessage("F1: Toggle Editor/Play mode", g.frame)
 	g.ui.AddLogMessage("F2: Toggle Inspector", g.frame)
 	g.ui.AddLogMessage("F3: Toggle Debug info", g.frame)
+	g.ui.AddLogMessage("F4: Toggle Hierarchy", g.frame)
+	g.ui.AddLogMessage("F5: Save scene  F9: Reload prefabs and scene", g.frame)
+	g.ui.AddLogMessage("F6: Toggle Asset browser", g.frame)
+	g.ui.AddLogMessage("F7: Toggle Memory view", g.frame)
+	g.ui.AddLogMessage("`: Toggle Lua console", g.frame)
 	g.ui.AddLogMessage("F11: Toggle Fullscreen", g.frame)
 
 	return nil
@@ -107,31 +227,70 @@ func (g *Game) Initialize() error {
 
 func (g *Game) createTestEntities(sprite *ebiten.Image) {
 	testEntity1 := g.entityManager.CreateEntity("TestBox1", sprite)
+	testEntity1.SpritePath = g.player.SpritePath
 	testEntity1.Position.X = 200
 	testEntity1.Position.Y = 150
 
 	testEntity2 := g.entityManager.CreateEntity("TestBox2", sprite)
+	testEntity2.SpritePath = g.player.SpritePath
 	testEntity2.Position.X = 300
 	testEntity2.Position.Y = 200
 
 	testEntity3 := g.entityManager.CreateEntity("TestBox3", sprite)
+	testEntity3.SpritePath = g.player.SpritePath
 	testEntity3.Position.X = 150
 	testEntity3.Position.Y = 300
 }
 
 func (g *Game) Close() {
+	if g.debugger != nil {
+		g.debugger.Close()
+	}
 	g.scriptManager.Close()
+	g.resourceManager.Close()
+	g.files.Close()
+	logging.Default().Close()
 }
 
 func (g *Game) Update() error {
 	g.frame++
+	logging.Default().SetFrame(g.frame)
 	g.inputManager.Update()
+	if g.debugger != nil {
+		g.debugger.Update()
+	}
+	g.resourceManager.Update()
+	g.applyReloads()
+	g.finishSceneChange()
 
 	// Update screen size
-	g.screenWidth, g.screenHeight = ebiten.WindowSize()
+	if !g.headless {
+		g.screenWidth, g.screenHeight = ebiten.WindowSize()
+	}
+	g.updateViewport()
 
-	// Handle input
-	g.handleInput()
+	// Toggle console with backquote; while open it owns the keyboard, but the game
+	// and the editor keep updating
+	if g.inputManager.IsKeyJustPressed(ebiten.KeyBackquote) {
+		g.console.Toggle()
+		g.ui.StopTyping()
+	}
+	g.scriptManager.SetInputBlocked(!g.keyboardFree())
+	if g.console.IsOpen() {
+		g.console.Update()
+	}
+
+	// The log panel is interactive in both modes
+	mouseX, mouseY := g.inputManager.GetMousePosition()
+	_, wheelY := g.inputManager.GetWheelDelta()
+	leftDown := g.inputManager.IsMouseButtonPressed(ebiten.MouseButtonLeft)
+	g.mouseOverLog = g.ui.UpdateLogPanel(mouseX, mouseY, leftDown, wheelY, g.screenWidth, g.screenHeight)
+
+	// Handle input (keyboard shortcuts are ignored while typing in the console or an
+	// editor field)
+	if g.keyboardFree() {
+		g.handleInput()
+	}
 
 	// Handle mode-specific logic
 	if g.editorMode {
@@ -143,6 +302,12 @@ func (g *Game) Update() error {
 	return nil
 }
 
+// keyboardFree reports whether shortcuts and movement may read the keyboard, which
+// the console and editor text fields take while in use
+func (g *Game) keyboardFree() bool {
+	return !g.console.IsOpen() && !g.ui.IsTyping()
+}
+
 func (g *Game) handleInput() {
 	// Toggle debug info with F3
 	if g.inputManager.IsKeyJustPressed(ebiten.KeyF3) {
@@ -175,26 +340,181 @@ func (g *Game) handleInput() {
 		}
 		g.ui.AddLogMessage(fmt.Sprintf("Inspector %s", status), g.frame)
 	}
+
+	// Toggle hierarchy with F4 (only in editor mode)
+	if g.editorMode && g.inputManager.IsKeyJustPressed(ebiten.KeyF4) {
+		g.ui.ToggleHierarchy()
+		status := "closed"
+		if g.ui.IsHierarchyOpen() {
+			status = "opened"
+		}
+		g.ui.AddLogMessage(fmt.Sprintf("Hierarchy %s", status), g.frame)
+	}
+
+	// Toggle asset browser with F6 (only in editor mode)
+	if g.editorMode && g.inputManager.IsKeyJustPressed(ebiten.KeyF6) {
+		g.ui.ToggleAssetBrowser()
+		status := "closed"
+		if g.ui.IsAssetBrowserOpen() {
+			status = "opened"
+		}
+		g.ui.AddLogMessage(fmt.Sprintf("Asset browser %s", status), g.frame)
+	}
+
+	// Toggle layers panel with F8 (only in editor mode)
+	if g.editorMode && g.inputManager.IsKeyJustPressed(ebiten.KeyF8) {
+		g.ui.ToggleLayersPanel()
+		status := "closed"
+		if g.ui.IsLayersPanelOpen() {
+			status = "opened"
+		}
+		g.ui.AddLogMessage(fmt.Sprintf("Layers panel %s", status), g.frame)
+	}
+
+	// Toggle memory view with F7 (only in editor mode)
+	if g.editorMode && g.inputManager.IsKeyJustPressed(ebiten.KeyF7) {
+		g.ui.ToggleMemoryPanel()
+		status := "closed"
+		if g.ui.IsMemoryPanelOpen() {
+			status = "opened"
+		}
+		g.ui.AddLogMessage(fmt.Sprintf("Memory view %s", status), g.frame)
+	}
 }
 
 func (g *Game) handleEditorMode() {
-	g.handleCameraControls()
+	if g.keyboardFree() {
+		g.handleCameraControls()
+	}
+	g.cameras.Animate(1 / float64(g.config.TPS))
+	g.particles.UpdatePreview(1 / float64(g.config.TPS))
 	g.handleMouseInteraction()
+	g.handleSceneControls()
+}
+
+// handleSceneControls handles prefab spawning and scene save/load in editor mode
+func (g *Game) handleSceneControls() {
+	if name, ok := g.ui.TakeSpawnRequest(); ok {
+		// Spawn at the centre of the visible viewport
+		centerX, centerY := g.camera.Center()
+		e, err := g.prefabManager.Instantiate(name, centerX, centerY)
+		if err != nil {
+			g.ui.AddLogError(fmt.Sprintf("Spawn failed: %v", err), g.frame)
+		} else {
+			g.ui.SetSelectedEntity(e)
+			g.ui.AddLogMessage(fmt.Sprintf("Spawned prefab %s as %s", name, e.Name), g.frame)
+		}
+	}
+
+	if path, ok := g.ui.TakeSoundPreview(); ok {
+		if err := g.audioManager.PlaySound(path); err != nil {
+			g.ui.AddLogError(err.Error(), g.frame)
+		}
+	}
+
+	if drop, ok := g.ui.TakeSpriteDrop(); ok {
+		g.createEntityFromSprite(drop)
+	}
+
+	if path, ok := g.ui.TakeParticlePreview(); ok {
+		g.toggleParticlePreview(path)
+	}
+
+	if !g.keyboardFree() {
+		return
+	}
+	scenePath := g.config.ScenePath()
+	if g.inputManager.IsKeyJustPressed(ebiten.KeyF5) {
+		if err := scene.Save(g.config.DiskPath(scenePath), g.entityManager, g.prefabManager); err != nil {
+			g.ui.AddLogError(err.Error(), g.frame)
+		} else {
+			g.ui.AddLogMessage(fmt.Sprintf("Scene saved: %s", scenePath), g.frame)
+		}
+	}
+
+	if g.inputManager.IsKeyJustPressed(ebiten.KeyF9) {
+		if err := g.prefabManager.ReloadAll(); err != nil {
+			g.ui.AddLogError(err.Error(), g.frame)
+		}
+		g.ui.SetPrefabNames(g.prefabManager.Names())
+		if err := g.loadScene(scenePath); err != nil {
+			g.ui.AddLogError(err.Error(), g.frame)
+		} else {
+			g.ui.AddLogMessage(fmt.Sprintf("Scene loaded: %s", scenePath), g.frame)
+		}
+	}
+}
+
+// createEntityFromSprite creates an entity for an image dropped from the asset browser
+func (g *Game) createEntityFromSprite(drop ui.SpriteDrop) {
+	sprite, err := g.resourceManager.LoadSprite(drop.Path)
+	if err != nil {
+		g.ui.AddLogError(err.Error(), g.frame)
+		return
+	}
+
+	name := strings.TrimSuffix(filepath.Base(drop.Path), filepath.Ext(drop.Path))
+	e := g.entityManager.CreateEntity(name, sprite)
+	e.SpritePath = drop.Path
+
+	// Centre the sprite on the drop point
+	worldX, worldY := g.camera.ScreenToWorld(float64(drop.ScreenX), float64(drop.ScreenY))
+	w, h := e.Size()
+	e.Position.X = worldX - w/2
+	e.Position.Y = worldY - h/2
+
+	g.ui.SetSelectedEntity(e)
+	g.ui.AddLogMessage(fmt.Sprintf("Created %s from %s", e.Name, drop.Path), g.frame)
+}
+
+// loadScene replaces the current entities with a saved scene and re-links the player
+func (g *Game) loadScene(path string) error {
+	// The old scene's sprites are released after the new scene takes its references,
+	// so sprites used by both stay loaded
+	previous := g.resourceManager.NewSceneScope()
+	if err := scene.Load(g.files, path, g.entityManager, g.prefabManager); err != nil {
+		g.resourceManager.SceneScope().Merge(previous)
+		return err
+	}
+	previous.Release()
+	g.particles.Clear()
+
+	g.ui.SetSelectedEntity(nil)
+	g.dragEntity = nil
+	g.player = nil
+	for _, e := range g.entityManager.GetHierarchyOrder() {
+		if e.Name == "Player" {
+			g.player = e
+			break
+		}
+	}
+	g.scriptManager.SetPlayer(g.player)
+	return nil
 }
 
 func (g *Game) handlePlayMode() {
-	g.handlePlayerMovement()
+	if g.keyboardFree() {
+		g.handlePlayerMovement()
+	}
+	g.runScripts()
+	g.entityManager.Animate(time.Duration(g.timeScale / float64(g.config.TPS) * float64(time.Second)))
+	g.particles.Update(g.timeScale / float64(g.config.TPS))
+	g.updateCamera()
+}
 
-	// Run Lua scripts
+// runScripts calls the Lua lifecycle hooks, running on_update timeScale times per frame on average
+func (g *Game) runScripts() {
 	if !g.started {
 		g.started = true
-		if err := g.scriptManager.CallFunction("on_start"); err != nil {
-			g.ui.AddLogMessage(err.Error(), g.frame)
-		}
+		g.scriptManager.CallFunction("on_start")
 	}
 
-	if err := g.scriptManager.CallFunction("on_update"); err != nil {
-		g.ui.AddLogMessage(err.Error(), g.frame)
+	// Errors are logged by the script manager, which also disables failing mods
+	g.timeAccumulator += g.timeScale
+	for g.timeAccumulator >= 1 {
+		g.timeAccumulator--
+		g.scriptManager.AdvanceTime(1 / float64(g.config.TPS))
+		g.scriptManager.CallFunction("on_update")
 	}
 }
 
@@ -214,14 +534,26 @@ func (g *Game) handleCameraControls() {
 		g.camera.Move(0, moveSpeed)
 	}
 
-	// Zoom controls
+	// Zoom controls (the wheel scrolls panels instead when the mouse is over them)
 	_, wheelY := g.inputManager.GetWheelDelta()
+	mouseX, mouseY := g.inputManager.GetMousePosition()
+	if g.ui.IsMouseOverUI(mouseX, mouseY, g.screenWidth, g.screenHeight) {
+		wheelY = 0
+	}
+	// The wheel zooms towards the cursor, the keys towards the centre of the view
+	zoom := 1.0
 	if wheelY > 0 || g.inputManager.IsKeyJustPressed(ebiten.KeyEqual) || g.inputManager.IsKeyJustPressed(ebiten.KeyKPAdd) {
-		g.camera.ZoomBy(1.1)
-		g.ui.AddLogMessage(fmt.Sprintf("Zoom: %.2fx", g.camera.Zoom), g.frame)
+		zoom = 1.1
 	}
 	if wheelY < 0 || g.inputManager.IsKeyJustPressed(ebiten.KeyMinus) || g.inputManager.IsKeyJustPressed(ebiten.KeyKPSubtract) {
-		g.camera.ZoomBy(1.0 / 1.1)
+		zoom = 1.0 / 1.1
+	}
+	if zoom != 1 {
+		if wheelY != 0 {
+			g.camera.ZoomAt(zoom, float64(mouseX), float64(mouseY))
+		} else {
+			g.camera.ZoomCentered(zoom)
+		}
 		g.ui.AddLogMessage(fmt.Sprintf("Zoom: %.2fx", g.camera.Zoom), g.frame)
 	}
 
@@ -230,6 +562,14 @@ func (g *Game) handleCameraControls() {
 		g.camera.Reset()
 		g.ui.AddLogMessage("Camera reset", g.frame)
 	}
+
+	// Frame the selected entity
+	if e := g.ui.GetSelectedEntity(); e != nil && g.inputManager.IsKeyJustPressed(ebiten.KeyF) {
+		minX, minY, maxX, maxY := e.WorldBounds()
+		ease, _ := camera.EasingByName("out_cubic")
+		g.camera.FocusOn(camera.Rect{X: minX, Y: minY, W: maxX - minX, H: maxY - minY}, 0.35, ease)
+		g.ui.AddLogMessage(fmt.Sprintf("Framed %s", e.Name), g.frame)
+	}
 }
 
 func (g *Game) handlePlayerMovement() {
@@ -237,29 +577,19 @@ func (g *Game) handlePlayerMovement() {
 		return
 	}
 
-	moveSpeed := 3.0
-	moved := false
+	moveSpeed := 3.0 * g.timeScale
 
 	if g.inputManager.IsKeyPressed(ebiten.KeyArrowLeft) || g.inputManager.IsKeyPressed(ebiten.KeyA) {
 		g.player.Position.X -= moveSpeed
-		moved = true
 	}
 	if g.inputManager.IsKeyPressed(ebiten.KeyArrowRight) || g.inputManager.IsKeyPressed(ebiten.KeyD) {
 		g.player.Position.X += moveSpeed
-		moved = true
 	}
 	if g.inputManager.IsKeyPressed(ebiten.KeyArrowUp) || g.inputManager.IsKeyPressed(ebiten.KeyW) {
 		g.player.Position.Y -= moveSpeed
-		moved = true
 	}
 	if g.inputManager.IsKeyPressed(ebiten.KeyArrowDown) || g.inputManager.IsKeyPressed(ebiten.KeyS) {
 		g.player.Position.Y += moveSpeed
-		moved = true
-	}
-
-	// Make camera follow player
-	if moved {
-		g.camera.FollowTarget(g.player.Position.X, g.player.Position.Y, g.screenWidth, g.screenHeight, 0.1)
 	}
 }
 
@@ -267,7 +597,18 @@ func (g *Game) handleMouseInteraction() {
 	mouseX, mouseY := g.inputManager.GetMousePosition()
 	worldX, worldY := g.camera.ScreenToWorld(float64(mouseX), float64(mouseY))
 
-	if g.inputManager.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+	// Let the editor panels handle clicks over them
+	leftDown := g.inputManager.IsMouseButtonPressed(ebiten.MouseButtonLeft)
+	_, wheelY := g.inputManager.GetWheelDelta()
+	overHierarchy := g.ui.UpdateHierarchy(g.entityManager, mouseX, mouseY, leftDown, wheelY, g.screenHeight, g.frame)
+	overAssets := g.ui.UpdateAssetBrowser(mouseX, mouseY, leftDown, wheelY, g.screenWidth, g.screenHeight)
+	overLayers := g.ui.UpdateLayersPanel(mouseX, mouseY, leftDown, g.screenWidth)
+	overInspector := g.ui.UpdateInspector(mouseX, mouseY, leftDown, g.screenWidth)
+	if (overHierarchy || overAssets || overLayers || overInspector || g.mouseOverLog) && !g.isDragging {
+		return
+	}
+
+	if leftDown {
 		if !g.isDragging {
 			g.isDragging = true
 			g.dragStart.X = mouseX
@@ -287,8 +628,7 @@ func (g *Game) handleMouseInteraction() {
 			// Continue dragging
 			if g.dragEntity != nil {
 				deltaX, deltaY := g.inputManager.GetMouseDelta()
-				g.dragEntity.Position.X += float64(deltaX) / g.camera.Zoom
-				g.dragEntity.Position.Y += float64(deltaY) / g.camera.Zoom
+				g.dragEntity.MoveWorld(float64(deltaX)/g.camera.Zoom, float64(deltaY)/g.camera.Zoom)
 			} else {
 				// Pan camera
 				deltaX, deltaY := g.inputManager.GetMouseDelta()
@@ -313,14 +653,13 @@ func (g *Game) handleMouseInteraction() {
 	}
 }
 
+// getEntityAt returns the topmost entity under a world position
 func (g *Game) getEntityAt(worldX, worldY float64) *entity.Entity {
-	for _, e := range g.entityManager.GetAllEntities() {
-		if e.Sprite != nil {
-			w, h := e.Sprite.Bounds().Dx(), e.Sprite.Bounds().Dy()
-			if worldX >= e.Position.X && worldX <= e.Position.X+float64(w) &&
-				worldY >= e.Position.Y && worldY <= e.Position.Y+float64(h) {
-				return e
-			}
+	// Pick the entity drawn on top, passing over fully transparent ones
+	entities := g.layers.Sort(g.entityManager.GetHierarchyOrder())
+	for i := len(entities) - 1; i >= 0; i-- {
+		if entities[i].WorldAlpha() > 0 && entities[i].ContainsWorldPoint(worldX, worldY) {
+			return entities[i]
 		}
 	}
 	return nil
@@ -328,78 +667,154 @@ func (g *Game) getEntityAt(worldX, worldY float64) *entity.Entity {
 
 func (g *Game) Draw(screen *ebiten.Image) {
 	// Clear screen
-	screen.Fill(color.RGBA{30, 30, 35, 255})
-
-	// Calculate viewport dimensions
-	viewportWidth := g.screenWidth
-	if g.ui.IsInspectorOpen() {
-		viewportWidth -= 200
+	background := color.RGBA{30, 30, 35, 255}
+	screen.Fill(background)
+
+	// Draw the game view offscreen when post-processing effects run over it
+	view := screen
+	viewWidth, viewHeight := g.ui.ViewportSize(g.screenWidth, g.screenHeight)
+	if offscreen := g.post.Begin(viewWidth, viewHeight); offscreen != nil {
+		offscreen.Fill(background)
+		view = offscreen
 	}
-	logPanelHeight := 120
-	viewportHeight := g.screenHeight - logPanelHeight
 
 	// Draw grid in editor mode
 	if g.editorMode {
-		g.ui.DrawGrid(screen, &g.camera, viewportWidth, viewportHeight)
+		g.ui.DrawGrid(view, g.camera)
+	}
+
+	// Scripts record what they draw once they have started, drawn over the scene below
+	scripted := g.started && !g.editorMode
+	if scripted {
+		v := g.camera.Viewport
+		g.scriptManager.RecordDraw(&g.scriptDraw, v.W, v.H, float64(g.screenWidth), float64(g.screenHeight))
+	}
+
+	// Draw entities through each camera, in layer order
+	g.drawCameras(view, g.layers.Sort(g.entityManager.GetHierarchyOrder()))
+
+	if scripted {
+		g.drawScripts(view)
+	}
+
+	if view != screen {
+		g.post.End(screen, 0, 0, g.shaderTime())
+	}
+
+	// Script HUDs are drawn over post-processing, under the editor's panels
+	if scripted {
+		g.scriptDraw.UI.Draw(render.NewCanvas(screen))
 	}
 
-	// Draw entities
-	g.drawEntities(screen, viewportWidth, viewportHeight)
+	if g.editorMode {
+		g.ui.DrawHierarchy(screen, g.entityManager, g.screenHeight)
+		g.ui.DrawAssetBrowser(screen, g.screenWidth, g.screenHeight)
+		g.ui.DrawMemoryPanel(screen, g.screenWidth, g.screenHeight)
+		g.ui.DrawLayersPanel(screen, g.screenWidth)
+	}
 
 	// Draw UI
 	g.ui.DrawModeIndicator(screen, g.editorMode)
-	g.ui.DrawCameraInfo(screen, &g.camera, g.editorMode)
+	g.ui.DrawCameraInfo(screen, g.camera, g.editorMode)
 	g.ui.DrawControls(screen, g.editorMode)
 	g.ui.DrawDebugInfo(screen, g.player, g.frame, g.entityManager.Count(), g.screenWidth, g.screenHeight)
 
 	if g.ui.IsInspectorOpen() {
-		g.ui.DrawInspector(screen, &g.camera, g.screenWidth, g.screenHeight)
+		g.ui.DrawInspector(screen, g.camera, g.screenWidth, g.screenHeight)
 	}
 
 	g.ui.DrawLogPanel(screen, g.screenWidth, g.screenHeight)
+	g.console.Draw(screen, g.screenWidth, g.screenHeight)
 }
 
-func (g *Game) drawEntities(screen *ebiten.Image, viewportWidth, viewportHeight int) {
-	cameraMatrix := g.camera.GetTransformMatrix()
+// drawScripts draws what on_draw recorded, clipped to the main camera's viewport
+func (g *Game) drawScripts(view *ebiten.Image) {
+	v := g.camera.Viewport
+	dst := view.SubImage(image.Rect(int(v.X), int(v.Y), int(v.X+v.W), int(v.Y+v.H))).(*ebiten.Image)
+	g.scriptDraw.World.Draw(render.NewWorldCanvas(dst, g.camera))
+	screen := render.NewCanvas(dst)
+	var origin ebiten.GeoM
+	origin.Translate(v.X, v.Y)
+	screen.SetView(origin)
+	g.scriptDraw.Screen.Draw(screen)
+}
 
-	for _, e := range g.entityManager.GetAllEntities() {
-		if e.Sprite != nil {
-			// Calculate screen position for culling
-			screenX, screenY := g.camera.WorldToScreen(e.Position.X, e.Position.Y)
+// drawEntities draws the entities a camera sees into dst, which is the screen or the
+// camera's texture, in the order given
+func (g *Game) drawEntities(dst *ebiten.Image, cam *camera.Camera, entities []*entity.Entity) {
+	cameraMatrix := cam.GetTransformMatrix()
+	visible := cam.VisibleBounds()
+	showSelection := cam == g.camera // Only the main camera marks the selected entity
 
-			// Cull entities outside viewport
-			w, h := e.Sprite.Bounds().Dx(), e.Sprite.Bounds().Dy()
-			scaledW, scaledH := float64(w)*g.camera.Zoom, float64(h)*g.camera.Zoom
+	for _, e := range entities {
+		// An image cannot be drawn into itself, so a camera skips its own texture
+		if e.Sprite != nil && e.Sprite != cam.Texture && cam.DrawsLayer(e.Layer) {
+			// World bounds for culling
+			minX, minY, maxX, maxY := e.WorldBounds()
 
-			if screenX+scaledW >= 0 && screenX <= float64(viewportWidth) &&
-				screenY+scaledH >= 0 && screenY <= float64(viewportHeight) {
+			// Cull entities outside viewport
+			if maxX >= visible.X && minX <= visible.X+visible.W &&
+				maxY >= visible.Y && minY <= visible.Y+visible.H {
 
+				worldMatrix := e.WorldMatrix()
 				opts := &ebiten.DrawImageOptions{}
+				opts.GeoM = e.SpriteMatrix()
+				opts.GeoM.Concat(worldMatrix)
 				opts.GeoM.Concat(cameraMatrix)
-				opts.GeoM.Translate(e.Position.X, e.Position.Y)
-
-				// Highlight selected entity
-				if g.ui.GetSelectedEntity() == e {
-					// Draw selection border
-					borderOpts := &ebiten.DrawImageOptions{}
-					borderOpts.GeoM.Concat(cameraMatrix)
-					borderOpts.GeoM.Translate(e.Position.X-2/g.camera.Zoom, e.Position.Y-2/g.camera.Zoom)
-
-					borderW := int(float64(w) + 4/g.camera.Zoom)
-					borderH := int(float64(h) + 4/g.camera.Zoom)
-					if borderW > 0 && borderH > 0 {
-						border := ebiten.NewImage(borderW, borderH)
-						border.Fill(color.RGBA{255, 255, 0, 255})
-						screen.DrawImage(border, borderOpts)
-					}
+				opts.ColorScale.ScaleWithColor(e.Tint)
+				opts.ColorScale.ScaleAlpha(float32(e.WorldAlpha()))
+				opts.Blend = render.Blend(e.Blend)
+
+				if e.WorldAlpha() > 0 {
+					g.drawSprite(dst, e, opts)
 				}
 
-				screen.DrawImage(e.Sprite, opts)
+				// Outline the selected entity over its sprite
+				if showSelection && g.ui.GetSelectedEntity() == e {
+					g.drawSelection(dst, cam, e)
+				}
 			}
 		}
 	}
 }
 
+// drawSelection outlines an entity's sprite with a line two pixels wide, turned and
+// scaled as the sprite is
+func (g *Game) drawSelection(dst *ebiten.Image, cam *camera.Camera, e *entity.Entity) {
+	canvas := render.NewWorldCanvas(dst, cam)
+	world := e.WorldMatrix()
+	w, h := e.Size()
+	corners := make([]float64, 0, 8)
+	for _, c := range [][2]float64{{0, 0}, {w, 0}, {w, h}, {0, h}} {
+		x, y := world.Apply(c[0], c[1])
+		corners = append(corners, x, y)
+	}
+	canvas.StrokePolygon(corners, 2/canvas.Scale(), color.RGBA{255, 255, 0, 255})
+}
+
+// drawSprite draws an entity's sprite, through its material's shader if it has one
+func (g *Game) drawSprite(dst *ebiten.Image, e *entity.Entity, opts *ebiten.DrawImageOptions) {
+	m := e.Material
+	if m == nil || m.Shader == nil {
+		dst.DrawImage(e.Sprite, opts)
+		return
+	}
+	w, h := e.Sprite.Bounds().Dx(), e.Sprite.Bounds().Dy()
+	shaderOpts := &ebiten.DrawRectShaderOptions{GeoM: opts.GeoM, ColorScale: opts.ColorScale, Blend: opts.Blend}
+	shaderOpts.Images[0] = e.Sprite
+	shaderOpts.Uniforms = render.Uniforms(m.Uniforms, g.shaderTime())
+	if err := render.DrawShader(dst, w, h, m.Shader, shaderOpts); err != nil {
+		// Drop the shader rather than fail every frame; setting it again restores it
+		g.ui.AddLogError(fmt.Sprintf("Shader %s on %s: %v", m.ShaderPath, e.Name, err), g.frame)
+		m.Shader = nil
+	}
+}
+
+// shaderTime returns the seconds since the game started, the Time uniform of shaders
+func (g *Game) shaderTime() float64 {
+	return float64(g.frame) / float64(g.config.TPS)
+}
+
 func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
 	g.screenWidth = outsideWidth
 	g.screenHeight = outsideHeight
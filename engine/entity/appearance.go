@@ -0,0 +1,118 @@
+package entity
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// White is the tint that leaves a sprite's colours unchanged
+var White = color.RGBA{255, 255, 255, 255}
+
+// ParseColor reads a hex colour, "#rrggbb" or "#rrggbbaa"
+func ParseColor(hex string) (color.RGBA, error) {
+	digits := strings.TrimPrefix(hex, "#")
+	c := color.RGBA{A: 255}
+	var err error
+	switch len(digits) {
+	case 6:
+		_, err = fmt.Sscanf(digits, "%02x%02x%02x", &c.R, &c.G, &c.B)
+	case 8:
+		_, err = fmt.Sscanf(digits, "%02x%02x%02x%02x", &c.R, &c.G, &c.B, &c.A)
+	default:
+		err = fmt.Errorf("expected #rrggbb or #rrggbbaa")
+	}
+	if err != nil {
+		return c, fmt.Errorf("invalid colour %q: %v", hex, err)
+	}
+	return c, nil
+}
+
+// BlendMode is how a sprite's colours combine with what is already drawn
+type BlendMode int
+
+const (
+	BlendNormal   BlendMode = iota // Draws over what is below
+	BlendAdd                       // Brightens, e.g. for glows and fire
+	BlendMultiply                  // Darkens, e.g. for shadows
+	BlendScreen                    // Brightens more softly than add
+	BlendSubtract                  // Removes the sprite's colours from what is below
+)
+
+var blendNames = []string{"normal", "add", "multiply", "screen", "subtract"}
+
+func (b BlendMode) String() string {
+	if b < 0 || int(b) >= len(blendNames) {
+		return "normal"
+	}
+	return blendNames[b]
+}
+
+// ParseBlendMode returns the blend mode with a name, e.g. "add"
+func ParseBlendMode(name string) (BlendMode, bool) {
+	for i, n := range blendNames {
+		if n == name {
+			return BlendMode(i), true
+		}
+	}
+	return BlendNormal, false
+}
+
+// BlendModeNames lists the blend mode names
+func BlendModeNames() []string {
+	return append([]string(nil), blendNames...)
+}
+
+// Material draws an entity's sprite through a Kage shader. The sprite is the
+// shader's first image; Uniforms hold float64 or []float64 values by uniform name.
+type Material struct {
+	Shader     *ebiten.Shader
+	ShaderPath string // Asset path the shader was loaded from
+	Uniforms   map[string]interface{}
+}
+
+// Frames is a sequence of images shown over time, such as an animated GIF
+type Frames interface {
+	Frame(t time.Duration) *ebiten.Image
+}
+
+// Animation plays frames on an entity; each step sets the sprite to the current one
+type Animation struct {
+	Frames  Frames
+	Path    string        // Asset path the frames were loaded from
+	Elapsed time.Duration // Time played so far
+}
+
+// NewMaterial creates a material with no uniforms set
+func NewMaterial(shader *ebiten.Shader, path string) *Material {
+	return &Material{Shader: shader, ShaderPath: path, Uniforms: make(map[string]interface{})}
+}
+
+// WorldAlpha returns the entity's opacity multiplied by its ancestors'
+func (e *Entity) WorldAlpha() float64 {
+	alpha := e.Alpha
+	for p := e.Parent; p != nil; p = p.Parent {
+		alpha *= p.Alpha
+	}
+	return math.Max(0, math.Min(1, alpha))
+}
+
+// SpriteMatrix returns the transform applied to the sprite before WorldMatrix, which
+// mirrors it within its bounds when flipped
+func (e *Entity) SpriteMatrix() ebiten.GeoM {
+	var m ebiten.GeoM
+	w, h := e.Size()
+	if e.FlipX {
+		m.Scale(-1, 1)
+		m.Translate(w, 0)
+	}
+	if e.FlipY {
+		m.Scale(1, -1)
+		m.Translate(0, h)
+	}
+	return m
+}
@@ -1,20 +1,66 @@
 package entity
 
 import (
+	"fmt"
+	"image/color"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
 type ID int
 
+// Vec2 is a 2D vector used for entity transforms
+type Vec2 struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Components holds free-form component data keyed by component name
+type Components map[string]map[string]interface{}
+
+// PrefabLink records which prefab node an entity was instantiated from
+type PrefabLink struct {
+	Name string // Prefab name
+	Path string // Node path inside the prefab ("" for the root, "0/1" for nested children)
+}
+
 type Entity struct {
-	ID       ID
-	Name     string
-	Position struct {
-		X, Y float64
-	}
-	Sprite *ebiten.Image
+	ID   ID
+	Name string
+
+	// Local transform, relative to the parent (or the world for root entities)
+	Position Vec2    // Top-left of the unrotated sprite
+	Rotation float64 // Radians, applied around Pivot
+	Scale    Vec2    // Applied around Pivot
+	Pivot    Vec2    // Sprite-local point that rotation and scale happen around
+
+	Sprite     *ebiten.Image
+	SpritePath string // Asset path the sprite was loaded from, if any
+	Layer      string // Render layer; cameras can draw a subset of layers ("" is the default layer)
+	Z          int    // Draw order within the layer, relative to the parent; higher draws on top
+
+	// Appearance of the sprite; these do not affect children
+	FlipX, FlipY bool       // Mirror the sprite within its bounds
+	Tint         color.RGBA // Multiplies the sprite's colours; white leaves them unchanged
+	Alpha        float64    // Opacity from 0 to 1, multiplied by the parent's
+	Blend        BlendMode
+	Material     *Material  // Draws the sprite through a shader, nil for none
+	Animation    *Animation // Frames played on the sprite, nil for none
+
+	Light    *Light // Light the entity gives off, nil for none
+	Occluder bool   // The sprite's rectangle casts shadows from lights
+
+	Particles string // Particle effect the entity emits, by definition name; "" for none
+
+	Components Components
+	Prefab     *PrefabLink // Set when the entity was instantiated from a prefab
+
+	// Hierarchy
+	Parent   *Entity
+	Children []*Entity
 }
 
 type Manager struct {
@@ -35,9 +81,13 @@ func (em *Manager) CreateEntity(name string, sprite *ebiten.Image) *Entity {
 	defer em.lock.Unlock()
 
 	e := &Entity{
-		ID:     em.nextID,
-		Name:   name,
-		Sprite: sprite,
+		ID:         em.nextID,
+		Name:       name,
+		Scale:      Vec2{X: 1, Y: 1},
+		Sprite:     sprite,
+		Tint:       White,
+		Alpha:      1,
+		Components: make(Components),
 	}
 	em.entities[e.ID] = e
 	em.nextID++
@@ -65,7 +115,7 @@ func (em *Manager) GetAllEntities() map[ID]*Entity {
 func (em *Manager) GetEntitiesSlice() []*Entity {
 	em.lock.Lock()
 	defer em.lock.Unlock()
-	
+
 	entities := make([]*Entity, 0, len(em.entities))
 	for _, e := range em.entities {
 		entities = append(entities, e)
@@ -73,15 +123,127 @@ func (em *Manager) GetEntitiesSlice() []*Entity {
 	return entities
 }
 
+// GetRoots returns the entities without a parent, ordered by ID
+func (em *Manager) GetRoots() []*Entity {
+	em.lock.Lock()
+	defer em.lock.Unlock()
+
+	roots := make([]*Entity, 0)
+	for _, e := range em.entities {
+		if e.Parent == nil {
+			roots = append(roots, e)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].ID < roots[j].ID })
+	return roots
+}
+
+// GetHierarchyOrder returns every entity depth-first, parents before their children
+func (em *Manager) GetHierarchyOrder() []*Entity {
+	ordered := make([]*Entity, 0, em.Count())
+	var walk func(e *Entity)
+	walk = func(e *Entity) {
+		ordered = append(ordered, e)
+		for _, child := range e.Children {
+			walk(child)
+		}
+	}
+	for _, root := range em.GetRoots() {
+		walk(root)
+	}
+	return ordered
+}
+
+// SetParent attaches child to parent, or detaches it when parent is nil.
+// When keepWorld is true the child's world transform is preserved.
+func (em *Manager) SetParent(child, parent *Entity, keepWorld bool) error {
+	if child == nil {
+		return fmt.Errorf("cannot reparent nil entity")
+	}
+	if parent == child || (parent != nil && child.IsAncestorOf(parent)) {
+		return fmt.Errorf("cannot parent %s to %s: would create a cycle", child.Name, parent.Name)
+	}
+	if child.Parent == parent {
+		return nil
+	}
+
+	em.lock.Lock()
+	defer em.lock.Unlock()
+
+	var pivotX, pivotY, rotation, scaleX, scaleY float64
+	if keepWorld {
+		pivotX, pivotY = child.WorldPivot()
+		rotation = child.WorldRotation()
+		scaleX, scaleY = child.WorldScale()
+	}
+
+	child.detach()
+	if parent != nil {
+		child.Parent = parent
+		parent.Children = append(parent.Children, child)
+	}
+
+	if keepWorld {
+		child.SetWorldTransform(pivotX, pivotY, rotation, scaleX, scaleY)
+	}
+	return nil
+}
+
 func (em *Manager) RemoveEntity(id ID) bool {
 	em.lock.Lock()
 	defer em.lock.Unlock()
-	
-	if _, exists := em.entities[id]; exists {
-		delete(em.entities, id)
-		return true
+
+	e, exists := em.entities[id]
+	if !exists {
+		return false
+	}
+	e.detach()
+	em.removeTree(e)
+	return true
+}
+
+// removeTree deletes an entity and all of its descendants from the manager
+func (em *Manager) removeTree(e *Entity) {
+	for _, child := range e.Children {
+		em.removeTree(child)
+	}
+	delete(em.entities, e.ID)
+}
+
+// Clear removes every entity
+func (em *Manager) Clear() {
+	em.lock.Lock()
+	defer em.lock.Unlock()
+	em.entities = make(map[ID]*Entity)
+}
+
+// Swap replaces every entity with the given ones, keyed by id, and returns those it
+// replaced, e.g. to put them back when building a scene fails
+func (em *Manager) Swap(entities map[ID]*Entity) map[ID]*Entity {
+	em.lock.Lock()
+	defer em.lock.Unlock()
+	previous := em.entities
+	em.entities = entities
+	return previous
+}
+
+// Animate advances every playing animation by dt and shows its current frame
+func (em *Manager) Animate(dt time.Duration) {
+	em.lock.Lock()
+	defer em.lock.Unlock()
+	for _, e := range em.entities {
+		if a := e.Animation; a != nil {
+			a.Elapsed += dt
+			if frame := a.Frames.Frame(a.Elapsed); frame != nil {
+				e.Sprite = frame
+			}
+		}
 	}
-	return false
+}
+
+// IsPrefabRoot reports whether the entity is the root of a prefab instance
+func (e *Entity) IsPrefabRoot() bool {
+	return e.Prefab != nil && e.Prefab.Path == ""
 }
 
 func (em *Manager) Count() int {
@@ -89,3 +251,55 @@ func (em *Manager) Count() int {
 	defer em.lock.Unlock()
 	return len(em.entities)
 }
+
+// IsAncestorOf reports whether e is a (direct or indirect) parent of other
+func (e *Entity) IsAncestorOf(other *Entity) bool {
+	for p := other.Parent; p != nil; p = p.Parent {
+		if p == e {
+			return true
+		}
+	}
+	return false
+}
+
+// WorldZ returns the entity's draw order within its layer, adding up its ancestors' Z
+func (e *Entity) WorldZ() int {
+	z := e.Z
+	for p := e.Parent; p != nil; p = p.Parent {
+		z += p.Z
+	}
+	return z
+}
+
+// Root returns the top ancestor of the entity, or the entity itself when it has no parent
+func (e *Entity) Root() *Entity {
+	root := e
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	return root
+}
+
+// Depth returns the number of ancestors of the entity
+func (e *Entity) Depth() int {
+	depth := 0
+	for p := e.Parent; p != nil; p = p.Parent {
+		depth++
+	}
+	return depth
+}
+
+// detach removes the entity from its parent's children list
+func (e *Entity) detach() {
+	if e.Parent == nil {
+		return
+	}
+	siblings := e.Parent.Children
+	for i, c := range siblings {
+		if c == e {
+			e.Parent.Children = append(siblings[:i:i], siblings[i+1:]...)
+			break
+		}
+	}
+	e.Parent = nil
+}
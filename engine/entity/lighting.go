@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"image/color"
+	"math"
+)
+
+// LightKind is the shape of the area a light shines on
+type LightKind int
+
+const (
+	LightPoint LightKind = iota // Shines in every direction
+	LightSpot                   // Shines in a cone, e.g. a torch or a flashlight
+)
+
+var lightKindNames = []string{"point", "spot"}
+
+func (k LightKind) String() string {
+	if k < 0 || int(k) >= len(lightKindNames) {
+		return "point"
+	}
+	return lightKindNames[k]
+}
+
+// ParseLightKind returns the light kind with a name, "point" or "spot"
+func ParseLightKind(name string) (LightKind, bool) {
+	for i, n := range lightKindNames {
+		if n == name {
+			return LightKind(i), true
+		}
+	}
+	return LightPoint, false
+}
+
+// Light is a light attached to an entity, shining from the centre of its sprite
+type Light struct {
+	Kind      LightKind
+	Color     color.RGBA
+	Intensity float64 // Multiplies the colour; above 1 saturates sooner
+	Radius    float64 // World units at which the light has faded out
+	Angle     float64 // Direction of a spot light in radians, turned with the entity
+	Spread    float64 // Half the width of a spot light's cone in radians
+	Offset    Vec2    // World units from the centre of the sprite
+	Shadows   bool    // Occluders block the light
+}
+
+// NewLight creates a white light with shadows
+func NewLight(kind LightKind) *Light {
+	return &Light{Kind: kind, Color: White, Intensity: 1, Radius: 200, Spread: math.Pi / 6, Shadows: true}
+}
+
+// LightPosition returns the world position the entity's light shines from
+func (e *Entity) LightPosition() (float64, float64) {
+	x, y := e.WorldCenter()
+	if e.Light != nil {
+		x += e.Light.Offset.X
+		y += e.Light.Offset.Y
+	}
+	return x, y
+}
+
+// LightAngle returns the world direction of the entity's spot light
+func (e *Entity) LightAngle() float64 {
+	if e.Light == nil {
+		return 0
+	}
+	return e.Light.Angle + e.WorldRotation()
+}
@@ -0,0 +1,152 @@
+package entity
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Size returns the unscaled sprite size of the entity
+func (e *Entity) Size() (float64, float64) {
+	if e.Sprite == nil {
+		return 0, 0
+	}
+	b := e.Sprite.Bounds()
+	return float64(b.Dx()), float64(b.Dy())
+}
+
+// LocalMatrix returns the transform from sprite space to the parent's space
+func (e *Entity) LocalMatrix() ebiten.GeoM {
+	var m ebiten.GeoM
+	m.Translate(-e.Pivot.X, -e.Pivot.Y)
+	m.Scale(e.Scale.X, e.Scale.Y)
+	m.Rotate(e.Rotation)
+	m.Translate(e.Pivot.X+e.Position.X, e.Pivot.Y+e.Position.Y)
+	return m
+}
+
+// WorldMatrix returns the transform from sprite space to world space
+func (e *Entity) WorldMatrix() ebiten.GeoM {
+	m := e.LocalMatrix()
+	if e.Parent != nil {
+		m.Concat(e.Parent.WorldMatrix())
+	}
+	return m
+}
+
+// WorldPosition returns the world position of the entity's local origin
+func (e *Entity) WorldPosition() (float64, float64) {
+	m := e.WorldMatrix()
+	return m.Apply(0, 0)
+}
+
+// WorldCenter returns the world position of the centre of the entity's sprite
+func (e *Entity) WorldCenter() (float64, float64) {
+	w, h := e.Size()
+	m := e.WorldMatrix()
+	return m.Apply(w/2, h/2)
+}
+
+// WorldPivot returns the world position of the entity's pivot point
+func (e *Entity) WorldPivot() (float64, float64) {
+	m := e.WorldMatrix()
+	return m.Apply(e.Pivot.X, e.Pivot.Y)
+}
+
+// WorldRotation returns the accumulated rotation of the entity and its ancestors
+func (e *Entity) WorldRotation() float64 {
+	rotation := e.Rotation
+	for p := e.Parent; p != nil; p = p.Parent {
+		rotation += p.Rotation
+	}
+	return rotation
+}
+
+// WorldScale returns the accumulated scale of the entity and its ancestors
+func (e *Entity) WorldScale() (float64, float64) {
+	sx, sy := e.Scale.X, e.Scale.Y
+	for p := e.Parent; p != nil; p = p.Parent {
+		sx *= p.Scale.X
+		sy *= p.Scale.Y
+	}
+	return sx, sy
+}
+
+// SetWorldTransform sets the local transform so that the pivot lands on the
+// given world position with the given world rotation and scale
+func (e *Entity) SetWorldTransform(pivotX, pivotY, rotation, scaleX, scaleY float64) {
+	if e.Parent != nil {
+		rotation -= e.Parent.WorldRotation()
+		psx, psy := e.Parent.WorldScale()
+		if psx != 0 {
+			scaleX /= psx
+		}
+		if psy != 0 {
+			scaleY /= psy
+		}
+		inv := e.Parent.WorldMatrix()
+		if inv.IsInvertible() {
+			inv.Invert()
+			pivotX, pivotY = inv.Apply(pivotX, pivotY)
+		}
+	}
+	e.Rotation = math.Remainder(rotation, 2*math.Pi)
+	e.Scale = Vec2{X: scaleX, Y: scaleY}
+	e.Position = Vec2{X: pivotX - e.Pivot.X, Y: pivotY - e.Pivot.Y}
+}
+
+// WorldToLocal converts a world point into the entity's sprite space
+func (e *Entity) WorldToLocal(worldX, worldY float64) (float64, float64, bool) {
+	m := e.WorldMatrix()
+	if !m.IsInvertible() {
+		return 0, 0, false
+	}
+	m.Invert()
+	x, y := m.Apply(worldX, worldY)
+	return x, y, true
+}
+
+// ContainsWorldPoint reports whether a world point lies on the entity's sprite
+func (e *Entity) ContainsWorldPoint(worldX, worldY float64) bool {
+	if e.Sprite == nil {
+		return false
+	}
+	x, y, ok := e.WorldToLocal(worldX, worldY)
+	if !ok {
+		return false
+	}
+	w, h := e.Size()
+	return x >= 0 && x <= w && y >= 0 && y <= h
+}
+
+// WorldBounds returns the axis-aligned world bounding box of the sprite
+func (e *Entity) WorldBounds() (minX, minY, maxX, maxY float64) {
+	w, h := e.Size()
+	m := e.WorldMatrix()
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, corner := range [4]Vec2{{0, 0}, {w, 0}, {0, h}, {w, h}} {
+		x, y := m.Apply(corner.X, corner.Y)
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	return minX, minY, maxX, maxY
+}
+
+// MoveWorld translates the entity by a world-space delta, regardless of its parent's transform
+func (e *Entity) MoveWorld(dx, dy float64) {
+	if e.Parent == nil {
+		e.Position.X += dx
+		e.Position.Y += dy
+		return
+	}
+	inv := e.Parent.WorldMatrix()
+	if !inv.IsInvertible() {
+		return
+	}
+	inv.Invert()
+	ox, oy := inv.Apply(0, 0)
+	lx, ly := inv.Apply(dx, dy)
+	e.Position.X += lx - ox
+	e.Position.Y += ly - oy
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"io/fs"
+	"os"
+	"path"
+
+	"deepthinking.do/luengo/engine/config"
+	"deepthinking.do/luengo/engine/logging"
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+// modAssetsFolder is the folder inside a mod whose files override the base assets
+const modAssetsFolder = "assets"
+
+// OpenFiles mounts the game's files, lowest priority first: embedded files, the
+// pack archive, the game folder, and then each mod's assets folder over the asset root
+func OpenFiles(cfg config.Config) *vfs.FS {
+	files := vfs.New()
+	if cfg.Embedded != nil {
+		files.Mount("", cfg.Embedded, "embedded files")
+	}
+	if cfg.Pack != "" {
+		if err := files.MountArchive("", cfg.DiskPath(cfg.Pack)); err != nil {
+			logging.Errorf("files", "%v", err)
+		}
+	}
+	if _, err := os.Stat(cfg.Root); err == nil {
+		if err := files.MountDir("", cfg.Root); err != nil {
+			logging.Errorf("files", "%v", err)
+		}
+	}
+
+	// Overlays read from the base mounts only, so a mod cannot override another mod's overrides
+	base := files.Snapshot()
+	modPath, assetRoot := vfs.Clean(cfg.ModPath), vfs.Clean(cfg.AssetRoot)
+	mods, err := base.ReadDir(modPath)
+	if err != nil {
+		logging.Warnf("files", "Could not list mods: %v", err)
+	}
+	for _, m := range mods {
+		if !m.IsDir() {
+			continue
+		}
+		dir := path.Join(modPath, m.Name(), modAssetsFolder)
+		if info, err := base.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		overlay, err := fs.Sub(base, dir)
+		if err != nil {
+			continue
+		}
+		files.Mount(assetRoot, overlay, dir)
+	}
+
+	for _, m := range files.Mounts() {
+		logging.Infof("files", "Mounted %s", m)
+	}
+	return files
+}
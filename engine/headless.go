@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"fmt"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"deepthinking.do/luengo/engine/config"
+	"deepthinking.do/luengo/engine/input"
+)
+
+// NewHeadlessGame creates a game that runs without a window, e.g. for tests and CI:
+// input only comes from PressKey, audio is muted and scripts run from the first tick.
+func NewHeadlessGame(cfg config.Config) *Game {
+	g := NewGame(cfg)
+	g.headless = true
+	g.editorMode = false
+	g.inputManager.SetHeadless(true)
+	g.audioManager.SetMuted(true)
+	return g
+}
+
+// Tick runs Game.Update the given number of times without drawing
+func (g *Game) Tick(frames int) error {
+	for i := 0; i < frames; i++ {
+		if err := g.Update(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TicksPerSecond returns how many ticks make one second of game time
+func (g *Game) TicksPerSecond() int {
+	return g.config.TPS
+}
+
+// LoadScript compiles a script from the game's files the way mods are loaded,
+// without running it
+func (g *Game) LoadScript(path string) (*lua.LFunction, error) {
+	return g.scriptManager.LoadScript(path)
+}
+
+// LuaState returns the Lua state the mods run in
+func (g *Game) LuaState() *lua.LState {
+	return g.scriptManager.GetLuaState()
+}
+
+// PressKey holds a key down, by name, until ReleaseKey
+func (g *Game) PressKey(name string) error {
+	key, ok := input.ParseKey(name)
+	if !ok {
+		return fmt.Errorf("unknown key: %s", name)
+	}
+	g.inputManager.Press(key)
+	return nil
+}
+
+func (g *Game) ReleaseKey(name string) error {
+	key, ok := input.ParseKey(name)
+	if !ok {
+		return fmt.Errorf("unknown key: %s", name)
+	}
+	g.inputManager.Release(key)
+	return nil
+}
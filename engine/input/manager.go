@@ -11,23 +11,53 @@ type Manager struct {
 	mouseY        int
 	lastMouseX    int
 	lastMouseY    int
+
+	// Headless managers never query ebiten; simulated keys are held down by tests
+	headless  bool
+	simulated map[ebiten.Key]bool
 }
 
 func NewManager() *Manager {
 	return &Manager{
 		keyStates:     make(map[ebiten.Key]bool),
 		lastKeyStates: make(map[ebiten.Key]bool),
+		simulated:     make(map[ebiten.Key]bool),
 	}
 }
 
+// SetHeadless stops reading the real keyboard and mouse, leaving only simulated input
+func (im *Manager) SetHeadless(headless bool) {
+	im.headless = headless
+}
+
+// Press holds a key down until Release is called
+func (im *Manager) Press(key ebiten.Key) {
+	im.simulated[key] = true
+	// Track the key so IsKeyJustPressed sees it even if Initialize didn't list it
+	if _, ok := im.keyStates[key]; !ok {
+		im.keyStates[key] = false
+		im.lastKeyStates[key] = false
+	}
+}
+
+// Release lets go of a simulated key
+func (im *Manager) Release(key ebiten.Key) {
+	delete(im.simulated, key)
+}
+
+// ReleaseAll lets go of every simulated key
+func (im *Manager) ReleaseAll() {
+	im.simulated = make(map[ebiten.Key]bool)
+}
+
 func (im *Manager) Initialize() {
 	keys := []ebiten.Key{
-		ebiten.KeyF1, ebiten.KeyF2, ebiten.KeyF3, ebiten.KeyF11,
+		ebiten.KeyF1, ebiten.KeyF2, ebiten.KeyF3, ebiten.KeyF4, ebiten.KeyF5, ebiten.KeyF6, ebiten.KeyF7, ebiten.KeyF8, ebiten.KeyF9, ebiten.KeyF11,
 		ebiten.KeyArrowUp, ebiten.KeyArrowDown, ebiten.KeyArrowLeft, ebiten.KeyArrowRight,
-		ebiten.KeyW, ebiten.KeyA, ebiten.KeyS, ebiten.KeyD,
+		ebiten.KeyW, ebiten.KeyA, ebiten.KeyS, ebiten.KeyD, ebiten.KeyF,
 		ebiten.KeyR, ebiten.KeyEqual, ebiten.KeyMinus,
 		ebiten.KeyKPAdd, ebiten.KeyKPSubtract,
-		ebiten.KeySpace,
+		ebiten.KeySpace, ebiten.KeyBackquote,
 	}
 	
 	for _, key := range keys {
@@ -39,12 +69,14 @@ func (im *Manager) Initialize() {
 func (im *Manager) Update() {
 	for key := range im.keyStates {
 		im.lastKeyStates[key] = im.keyStates[key]
-		im.keyStates[key] = ebiten.IsKeyPressed(key)
+		im.keyStates[key] = im.IsKeyPressed(key)
 	}
 	
 	im.lastMouseX = im.mouseX
 	im.lastMouseY = im.mouseY
-	im.mouseX, im.mouseY = ebiten.CursorPosition()
+	if !im.headless {
+		im.mouseX, im.mouseY = ebiten.CursorPosition()
+	}
 }
 
 func (im *Manager) IsKeyJustPressed(key ebiten.Key) bool {
@@ -52,7 +84,10 @@ func (im *Manager) IsKeyJustPressed(key ebiten.Key) bool {
 }
 
 func (im *Manager) IsKeyPressed(key ebiten.Key) bool {
-	return ebiten.IsKeyPressed(key)
+	if im.simulated[key] {
+		return true
+	}
+	return !im.headless && ebiten.IsKeyPressed(key)
 }
 
 func (im *Manager) GetMousePosition() (int, int) {
@@ -64,13 +99,25 @@ func (im *Manager) GetMouseDelta() (int, int) {
 }
 
 func (im *Manager) IsMouseButtonPressed(button ebiten.MouseButton) bool {
-	return ebiten.IsMouseButtonPressed(button)
+	return !im.headless && ebiten.IsMouseButtonPressed(button)
 }
 
 func (im *Manager) GetWheelDelta() (float64, float64) {
+	if im.headless {
+		return 0, 0
+	}
 	return ebiten.Wheel()
 }
 
+// ParseKey converts a key name such as "ArrowLeft", "Space" or "F1" to a key
+func ParseKey(name string) (ebiten.Key, bool) {
+	var key ebiten.Key
+	if err := key.UnmarshalText([]byte(name)); err != nil {
+		return 0, false
+	}
+	return key, true
+}
+
 func KeyFromString(k string) ebiten.Key {
 	switch k {
 	case "ArrowUp":
@@ -97,6 +144,8 @@ func KeyFromString(k string) ebiten.Key {
 		return ebiten.KeyF2
 	case "F3":
 		return ebiten.KeyF3
+	case "F4":
+		return ebiten.KeyF4
 	case "F11":
 		return ebiten.KeyF11
 	case "R":
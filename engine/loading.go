@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"fmt"
+
+	"deepthinking.do/luengo/engine/logging"
+	"deepthinking.do/luengo/engine/resources"
+	"deepthinking.do/luengo/engine/scene"
+)
+
+// sceneChange is a scene waiting for its sprites to finish loading
+type sceneChange struct {
+	path   string
+	assets *resources.Group
+}
+
+// ChangeScene preloads a scene's sprites in the background and switches to it once
+// they are ready, showing loadingScene, if given, in the meantime
+func (g *Game) ChangeScene(path, loadingScene string) error {
+	sprites, err := scene.Sprites(g.files, path, g.prefabManager)
+	if err != nil {
+		return err
+	}
+	if loadingScene != "" {
+		if err := g.loadScene(loadingScene); err != nil {
+			return fmt.Errorf("failed to show loading scene: %w", err)
+		}
+	}
+	g.sceneChange = &sceneChange{
+		path:   path,
+		assets: g.resourceManager.Preload("scene:"+path, sprites),
+	}
+	logging.Infof("engine", "Loading scene %s (%d sprites)", path, len(sprites))
+	return nil
+}
+
+// SceneLoadProgress reports the scene change in progress, if any
+func (g *Game) SceneLoadProgress() (float64, bool) {
+	if g.sceneChange == nil {
+		return 1, false
+	}
+	return g.sceneChange.assets.Fraction(), true
+}
+
+// finishSceneChange switches to the pending scene once its sprites are loaded
+func (g *Game) finishSceneChange() {
+	change := g.sceneChange
+	if change == nil || !change.assets.Done() {
+		return
+	}
+	g.sceneChange = nil
+	for _, err := range change.assets.Errors() {
+		logging.Warnf("engine", "Scene %s: %v", change.path, err)
+	}
+	err := g.loadScene(change.path)
+	g.resourceManager.ReleaseGroup(change.assets.Name)
+	if err != nil {
+		logging.Errorf("engine", "%v", err)
+		return
+	}
+	logging.Infof("engine", "Scene loaded: %s", change.path)
+}
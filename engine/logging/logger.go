@@ -0,0 +1,239 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+}
+
+// Short returns a one-letter tag for the level
+func (l Level) Short() string {
+	return l.String()[:1]
+}
+
+// ParseLevel converts a level name such as "info" or "warn" to a Level
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level: %s", name)
+	}
+}
+
+// Entry is a single log message
+type Entry struct {
+	Time    time.Time
+	Frame   int
+	Level   Level
+	Source  string // e.g. "engine", "audio", "lua:mod/player/init.lua"
+	Message string
+	File    string // Source file the entry points at, if any
+	Line    int
+	Detail  string // Extra lines such as a Lua traceback
+}
+
+// String formats the entry for console and file output
+func (e Entry) String() string {
+	s := fmt.Sprintf("%s [%d] %-5s %s: %s", e.Time.Format("15:04:05.000"), e.Frame, e.Level, e.Source, e.Message)
+	if e.Detail != "" {
+		s += "\n" + e.Detail
+	}
+	return s
+}
+
+// Location returns "file:line" for entries that point at a source file, or ""
+func (e Entry) Location() string {
+	if e.File == "" {
+		return ""
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d", e.File, e.Line)
+	}
+	return e.File
+}
+
+// Logger keeps recent entries in memory for the editor and mirrors them to stdout and an optional file
+type Logger struct {
+	lock       sync.Mutex
+	entries    []Entry
+	maxEntries int
+	frame      int
+	minLevel   Level // Entries below this level are dropped
+	stdout     bool
+	file       *os.File
+}
+
+func New(maxEntries int) *Logger {
+	return &Logger{
+		entries:    make([]Entry, 0, maxEntries),
+		maxEntries: maxEntries,
+		minLevel:   LevelDebug,
+		stdout:     true,
+	}
+}
+
+// SetFrame sets the frame number recorded on new entries
+func (l *Logger) SetFrame(frame int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.frame = frame
+}
+
+// SetLevel drops entries below the given level
+func (l *Logger) SetLevel(level Level) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.minLevel = level
+}
+
+// SetStdout enables or disables mirroring entries to stdout
+func (l *Logger) SetStdout(enabled bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.stdout = enabled
+}
+
+// SetOutputFile appends entries to a file; an empty path stops file output
+func (l *Logger) SetOutputFile(path string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file %s: %w", path, err)
+	}
+	l.file = f
+	return nil
+}
+
+// Close closes the output file, if any
+func (l *Logger) Close() {
+	l.SetOutputFile("")
+}
+
+// Add records an entry, filling in the time and, when zero, the current frame
+func (l *Logger) Add(e Entry) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if e.Level < l.minLevel {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Frame == 0 {
+		e.Frame = l.frame
+	}
+
+	l.entries = append(l.entries, e)
+	if len(l.entries) > l.maxEntries {
+		l.entries = l.entries[len(l.entries)-l.maxEntries:]
+	}
+
+	if l.stdout {
+		fmt.Println(e.String())
+	}
+	if l.file != nil {
+		fmt.Fprintln(l.file, e.String())
+	}
+}
+
+func (l *Logger) Log(level Level, source, msg string) {
+	l.Add(Entry{Level: level, Source: source, Message: msg})
+}
+
+// Entries returns a copy of the recorded entries, oldest first
+func (l *Logger) Entries() []Entry {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	result := make([]Entry, len(l.entries))
+	copy(result, l.entries)
+	return result
+}
+
+// Sources returns the distinct sources of the recorded entries, in order of first appearance
+func (l *Logger) Sources() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	seen := make(map[string]bool)
+	sources := make([]string, 0)
+	for _, e := range l.entries {
+		if !seen[e.Source] {
+			seen[e.Source] = true
+			sources = append(sources, e.Source)
+		}
+	}
+	return sources
+}
+
+// Clear removes every recorded entry
+func (l *Logger) Clear() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.entries = l.entries[:0]
+}
+
+var defaultLogger = New(1000)
+
+// Default returns the logger shared by the engine packages
+func Default() *Logger {
+	return defaultLogger
+}
+
+func Debugf(source, format string, args ...interface{}) {
+	defaultLogger.Log(LevelDebug, source, fmt.Sprintf(format, args...))
+}
+
+func Infof(source, format string, args ...interface{}) {
+	defaultLogger.Log(LevelInfo, source, fmt.Sprintf(format, args...))
+}
+
+func Warnf(source, format string, args ...interface{}) {
+	defaultLogger.Log(LevelWarn, source, fmt.Sprintf(format, args...))
+}
+
+func Errorf(source, format string, args ...interface{}) {
+	defaultLogger.Log(LevelError, source, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,152 @@
+-- Luengo test DSL: describe/it/expect. Loaded before each *_test.lua file;
+-- the Go runner reads __luatest.tests and runs them one by one.
+
+local root = { names = {}, before = {}, after = {} }
+local scope = root
+
+__luatest = { tests = {} }
+
+function describe(name, fn)
+  local parent = scope
+  local names = {}
+  for _, n in ipairs(parent.names) do table.insert(names, n) end
+  table.insert(names, name)
+  scope = { names = names, before = {}, after = {}, parent = parent }
+  fn()
+  scope = parent
+end
+
+function before_each(fn)
+  table.insert(scope.before, fn)
+end
+
+function after_each(fn)
+  table.insert(scope.after, fn)
+end
+
+function it(name, fn)
+  -- Hooks run outermost first before the test and innermost first after it
+  local before, after = {}, {}
+  local s = scope
+  while s do
+    for i = #s.before, 1, -1 do table.insert(before, 1, s.before[i]) end
+    for _, hook in ipairs(s.after) do table.insert(after, hook) end
+    s = s.parent
+  end
+  table.insert(__luatest.tests, {
+    suite = table.concat(scope.names, " "),
+    name = name,
+    fn = fn,
+    before = before,
+    after = after,
+  })
+end
+
+local function format(value, depth)
+  depth = depth or 0
+  if type(value) == "string" then
+    return string.format("%q", value)
+  end
+  if type(value) ~= "table" then
+    return tostring(value)
+  end
+  if depth > 1 then
+    return "{...}"
+  end
+  local parts = {}
+  for k, v in pairs(value) do
+    table.insert(parts, tostring(k) .. " = " .. format(v, depth + 1))
+    if #parts >= 8 then
+      table.insert(parts, "...")
+      break
+    end
+  end
+  return "{" .. table.concat(parts, ", ") .. "}"
+end
+
+local function deep_equal(a, b)
+  if a == b then return true end
+  if type(a) ~= "table" or type(b) ~= "table" then return false end
+  for k, v in pairs(a) do
+    if not deep_equal(v, b[k]) then return false end
+  end
+  for k in pairs(b) do
+    if a[k] == nil then return false end
+  end
+  return true
+end
+
+local Expectation = {}
+Expectation.__index = Expectation
+
+function expect(actual)
+  local e = setmetatable({ actual = actual, negate = false }, Expectation)
+  e.never = setmetatable({ actual = actual, negate = true }, Expectation)
+  return e
+end
+
+-- check raises a failure pointing at the line of the test that called the matcher
+-- (level 4: gopher-lua counts the error builtin's own frame)
+function Expectation:check(ok, description)
+  if self.negate then
+    ok = not ok
+    description = "not " .. description
+  end
+  if not ok then
+    error("expected " .. format(self.actual) .. " " .. description, 4)
+  end
+end
+
+function Expectation:to_be(expected)
+  self:check(self.actual == expected, "to be " .. format(expected))
+end
+
+function Expectation:to_equal(expected)
+  self:check(deep_equal(self.actual, expected), "to equal " .. format(expected))
+end
+
+function Expectation:to_be_truthy()
+  self:check(self.actual ~= nil and self.actual ~= false, "to be truthy")
+end
+
+function Expectation:to_be_falsy()
+  self:check(self.actual == nil or self.actual == false, "to be falsy")
+end
+
+function Expectation:to_be_nil()
+  self:check(self.actual == nil, "to be nil")
+end
+
+function Expectation:to_be_close_to(expected, epsilon)
+  epsilon = epsilon or 1e-6
+  local ok = type(self.actual) == "number" and math.abs(self.actual - expected) <= epsilon
+  self:check(ok, "to be within " .. epsilon .. " of " .. format(expected))
+end
+
+function Expectation:to_be_greater_than(expected)
+  self:check(type(self.actual) == "number" and self.actual > expected, "to be greater than " .. format(expected))
+end
+
+function Expectation:to_be_less_than(expected)
+  self:check(type(self.actual) == "number" and self.actual < expected, "to be less than " .. format(expected))
+end
+
+function Expectation:to_contain(expected)
+  local found = false
+  if type(self.actual) == "string" then
+    found = string.find(self.actual, expected, 1, true) ~= nil
+  elseif type(self.actual) == "table" then
+    for _, v in pairs(self.actual) do
+      if deep_equal(v, expected) then
+        found = true
+        break
+      end
+    end
+  end
+  self:check(found, "to contain " .. format(expected))
+end
+
+function Expectation:to_fail()
+  local ok = type(self.actual) == "function" and not pcall(self.actual)
+  self:check(ok, "to raise an error")
+end
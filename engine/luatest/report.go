@@ -0,0 +1,158 @@
+package luatest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Summary counts tests across files
+func Summary(results []FileResult) (total, failed int) {
+	for _, r := range results {
+		total += len(r.Tests)
+		if r.Error != "" {
+			total++
+		}
+		failed += r.Failures()
+	}
+	return total, failed
+}
+
+// WriteText writes a human readable report
+func WriteText(w io.Writer, results []FileResult, verbose bool) {
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Fprintf(w, "ERROR %s\n      %s\n", r.File, r.Error)
+			continue
+		}
+		for _, t := range r.Tests {
+			if t.Passed() {
+				if verbose {
+					fmt.Fprintf(w, "ok    %s (%s)\n", t.FullName(), t.Duration.Round(time.Millisecond))
+				}
+				continue
+			}
+			fmt.Fprintf(w, "FAIL  %s\n      %s:%d: %s\n", t.FullName(), t.File, t.Line, t.Failure)
+			if verbose && t.Traceback != "" {
+				fmt.Fprintf(w, "      %s\n", strings.ReplaceAll(t.Traceback, "\n", "\n      "))
+			}
+		}
+	}
+	total, failed := Summary(results)
+	fmt.Fprintf(w, "%d tests, %d passed, %d failed\n", total, total-failed, failed)
+}
+
+// WriteTAP writes a Test Anything Protocol (version 13) report
+func WriteTAP(w io.Writer, results []FileResult) {
+	total, _ := Summary(results)
+	fmt.Fprintln(w, "TAP version 13")
+	fmt.Fprintf(w, "1..%d\n", total)
+
+	n := 0
+	for _, r := range results {
+		if r.Error != "" {
+			n++
+			fmt.Fprintf(w, "not ok %d - %s\n", n, r.File)
+			writeTAPDiagnostic(w, r.Error, r.File, 0)
+		}
+		for _, t := range r.Tests {
+			n++
+			if t.Passed() {
+				fmt.Fprintf(w, "ok %d - %s\n", n, t.FullName())
+				continue
+			}
+			fmt.Fprintf(w, "not ok %d - %s\n", n, t.FullName())
+			writeTAPDiagnostic(w, t.Failure, t.File, t.Line)
+		}
+	}
+}
+
+func writeTAPDiagnostic(w io.Writer, message, file string, line int) {
+	fmt.Fprintln(w, "  ---")
+	fmt.Fprintf(w, "  message: %q\n", message)
+	if line > 0 {
+		fmt.Fprintf(w, "  at: %q\n", fmt.Sprintf("%s:%d", file, line))
+	} else {
+		fmt.Fprintf(w, "  at: %q\n", file)
+	}
+	fmt.Fprintln(w, "  ...")
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Time     float64      `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     float64     `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes a JUnit XML report with one testsuite per file
+func WriteJUnit(w io.Writer, results []FileResult) error {
+	report := junitSuites{}
+	for _, r := range results {
+		suite := junitSuite{Name: r.File, Time: r.Duration.Seconds()}
+		if r.Error != "" {
+			suite.Errors++
+			suite.Cases = append(suite.Cases, junitCase{
+				ClassName: r.File,
+				Name:      "load",
+				Error:     &junitFailure{Message: r.Error, Body: r.Error},
+			})
+		}
+		for _, t := range r.Tests {
+			c := junitCase{ClassName: t.Suite, Name: t.Name, Time: t.Duration.Seconds()}
+			if c.ClassName == "" {
+				c.ClassName = r.File
+			}
+			if !t.Passed() {
+				suite.Failures++
+				body := fmt.Sprintf("%s:%d: %s", t.File, t.Line, t.Failure)
+				if t.Traceback != "" {
+					body += "\n" + t.Traceback
+				}
+				c.Failure = &junitFailure{Message: t.Failure, Body: body}
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+		suite.Tests = len(suite.Cases)
+		report.Suites = append(report.Suites, suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures + suite.Errors
+		report.Time += suite.Time
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
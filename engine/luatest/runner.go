@@ -0,0 +1,253 @@
+package luatest
+
+import (
+	_ "embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// FileSuffix marks Lua test files
+const FileSuffix = "_test.lua"
+
+//go:embed luatest.lua
+var dsl string
+
+var locationPattern = regexp.MustCompile(`^(.+?\.lua):(\d+):\s*(.*)$`)
+
+// Host is a running game the tests drive, e.g. a headless engine.Game
+type Host interface {
+	LuaState() *lua.LState
+	Tick(frames int) error
+	TicksPerSecond() int // Converts advance(seconds) into ticks
+	// LoadScript compiles a file from the game's files as the mods are compiled
+	LoadScript(path string) (*lua.LFunction, error)
+	PressKey(name string) error
+	ReleaseKey(name string) error
+	Close()
+}
+
+// TestResult is the outcome of one it block
+type TestResult struct {
+	Suite     string // Names of the enclosing describe blocks
+	Name      string
+	Failure   string // Empty when the test passed
+	File      string
+	Line      int
+	Traceback string
+	Duration  time.Duration
+}
+
+func (r TestResult) Passed() bool {
+	return r.Failure == ""
+}
+
+// FullName joins the suite and test names
+func (r TestResult) FullName() string {
+	if r.Suite == "" {
+		return r.Name
+	}
+	return r.Suite + " " + r.Name
+}
+
+// FileResult holds the results of one test file
+type FileResult struct {
+	File     string
+	Tests    []TestResult
+	Error    string // Set when the file could not be loaded
+	Duration time.Duration
+}
+
+// Failures counts failed tests, counting a file that failed to load as one failure
+func (r FileResult) Failures() int {
+	n := 0
+	if r.Error != "" {
+		n++
+	}
+	for _, t := range r.Tests {
+		if !t.Passed() {
+			n++
+		}
+	}
+	return n
+}
+
+// Runner runs each test file against a fresh host
+type Runner struct {
+	NewHost func() (Host, error)
+}
+
+// FindTests returns the test files under root in the game's files, in a stable order
+func FindTests(files fs.FS, root string) ([]string, error) {
+	var tests []string
+	err := fs.WalkDir(files, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, FileSuffix) {
+			tests = append(tests, path)
+		}
+		return nil
+	})
+	sort.Strings(tests)
+	return tests, err
+}
+
+// Run runs every file and returns the results in the same order
+func (r *Runner) Run(files []string) []FileResult {
+	results := make([]FileResult, 0, len(files))
+	for _, file := range files {
+		results = append(results, r.RunFile(file))
+	}
+	return results
+}
+
+// RunFile loads the DSL and a test file into a new host and runs its tests
+func (r *Runner) RunFile(path string) FileResult {
+	start := time.Now()
+	result := FileResult{File: path}
+	defer func() { result.Duration = time.Since(start) }()
+
+	host, err := r.NewHost()
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to start game: %v", err)
+		return result
+	}
+	defer host.Close()
+
+	L := host.LuaState()
+	pressed := make(map[string]bool)
+	registerHostFunctions(L, host, pressed)
+
+	fn, err := L.Load(strings.NewReader(dsl), "luatest.lua")
+	if err == nil {
+		L.Push(fn)
+		err = L.PCall(0, 0, nil)
+	}
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to load test DSL: %v", err)
+		return result
+	}
+	// The test file is read and compiled like the mods, so it can come from a pack
+	// or an overlay and its require calls find the modules the game loaded
+	fn, err = host.LoadScript(path)
+	if err == nil {
+		L.Push(fn)
+		err = L.PCall(0, 0, nil)
+	}
+	if err != nil {
+		result.Error = errorMessage(err)
+		return result
+	}
+
+	tests, _ := L.GetGlobal("__luatest").(*lua.LTable)
+	if tests == nil {
+		return result
+	}
+	list, _ := tests.RawGetString("tests").(*lua.LTable)
+	if list == nil {
+		return result
+	}
+	for i := 1; i <= list.Len(); i++ {
+		test, ok := list.RawGetInt(i).(*lua.LTable)
+		if !ok {
+			continue
+		}
+		result.Tests = append(result.Tests, runTest(L, path, test))
+
+		// Keys held by one test must not leak into the next
+		for key := range pressed {
+			host.ReleaseKey(key)
+			delete(pressed, key)
+		}
+	}
+	return result
+}
+
+// runTest calls the before hooks, the test and the after hooks, stopping at the first error
+func runTest(L *lua.LState, path string, test *lua.LTable) TestResult {
+	result := TestResult{
+		Suite: lua.LVAsString(test.RawGetString("suite")),
+		Name:  lua.LVAsString(test.RawGetString("name")),
+		File:  path,
+	}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	var fns []lua.LValue
+	if before, ok := test.RawGetString("before").(*lua.LTable); ok {
+		before.ForEach(func(_, fn lua.LValue) { fns = append(fns, fn) })
+	}
+	fns = append(fns, test.RawGetString("fn"))
+	if after, ok := test.RawGetString("after").(*lua.LTable); ok {
+		after.ForEach(func(_, fn lua.LValue) { fns = append(fns, fn) })
+	}
+
+	for _, fn := range fns {
+		if err := L.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true}); err != nil {
+			result.Failure = errorMessage(err)
+			if apiErr, ok := err.(*lua.ApiError); ok {
+				result.Traceback = apiErr.StackTrace
+			}
+			if m := locationPattern.FindStringSubmatch(result.Failure); m != nil {
+				result.File = m[1]
+				result.Line, _ = strconv.Atoi(m[2])
+				result.Failure = m[3]
+			}
+			break
+		}
+	}
+	return result
+}
+
+// registerHostFunctions exposes simulated input and time to the tests
+func registerHostFunctions(L *lua.LState, host Host, pressed map[string]bool) {
+	tick := func(frames int) {
+		if err := host.Tick(frames); err != nil {
+			L.RaiseError("tick failed: %v", err)
+		}
+	}
+
+	L.SetGlobal("tick", L.NewFunction(func(L *lua.LState) int {
+		tick(L.OptInt(1, 1))
+		return 0
+	}))
+
+	L.SetGlobal("advance", L.NewFunction(func(L *lua.LState) int {
+		seconds := float64(L.CheckNumber(1))
+		tick(int(seconds*float64(host.TicksPerSecond()) + 0.5))
+		return 0
+	}))
+
+	L.SetGlobal("press", L.NewFunction(func(L *lua.LState) int {
+		key := L.CheckString(1)
+		if err := host.PressKey(key); err != nil {
+			L.RaiseError("%v", err)
+		}
+		pressed[key] = true
+		return 0
+	}))
+
+	L.SetGlobal("release", L.NewFunction(func(L *lua.LState) int {
+		key := L.CheckString(1)
+		if err := host.ReleaseKey(key); err != nil {
+			L.RaiseError("%v", err)
+		}
+		delete(pressed, key)
+		return 0
+	}))
+}
+
+// errorMessage returns a Lua error's message without its traceback
+func errorMessage(err error) string {
+	if apiErr, ok := err.(*lua.ApiError); ok {
+		return strings.TrimSpace(apiErr.Object.String())
+	}
+	return err.Error()
+}
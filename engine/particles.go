@@ -0,0 +1,23 @@
+package engine
+
+import "fmt"
+
+// toggleParticlePreview plays a particle definition clicked in the asset browser at
+// the centre of the view, or stops it when it is already playing
+func (g *Game) toggleParticlePreview(path string) {
+	if g.particles.PreviewPath() == path {
+		g.particles.StopPreview()
+		g.ui.SetParticlePreview("")
+		g.ui.AddLogMessage(fmt.Sprintf("Stopped previewing %s", path), g.frame)
+		return
+	}
+	centerX, centerY := g.camera.Center()
+	if err := g.particles.StartPreview(path, centerX, centerY); err != nil {
+		g.particles.StopPreview()
+		g.ui.SetParticlePreview("")
+		g.ui.AddLogError(err.Error(), g.frame)
+		return
+	}
+	g.ui.SetParticlePreview(path)
+	g.ui.AddLogMessage(fmt.Sprintf("Previewing %s", path), g.frame)
+}
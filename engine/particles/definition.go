@@ -0,0 +1,205 @@
+package particles
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+
+	"deepthinking.do/luengo/engine/entity"
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+// Extension is the file extension of particle definition files
+const Extension = ".particles"
+
+// MaxParticles caps the particles one emitter can have alive, so an emitter is drawn
+// in a single batch
+const MaxParticles = 10000
+
+// Definition describes a particle effect: where and how often particles are emitted,
+// how they move and how they look over their lifetime
+type Definition struct {
+	Name string `json:"-"` // The file name without extension
+	Path string `json:"-"` // File the definition was loaded from
+
+	Sprite       string  `json:"sprite,omitempty"` // Image of one particle; a soft dot when empty
+	MaxParticles int     `json:"max_particles,omitempty"`
+	Duration     float64 `json:"duration,omitempty"` // Seconds the emitter emits for; 0 emits until stopped
+	Loop         bool    `json:"loop,omitempty"`     // Start again after the duration
+	Rate         float64 `json:"rate,omitempty"`     // Particles per second
+	Bursts       []Burst `json:"bursts,omitempty"`
+	Shape        Shape   `json:"shape"`
+
+	Lifetime Range       `json:"lifetime"`          // Seconds
+	Speed    Range       `json:"speed"`             // World units per second
+	Angle    float64     `json:"angle"`             // Direction particles move in, in radians
+	Spread   float64     `json:"spread"`            // Radians either side of the angle
+	Gravity  entity.Vec2 `json:"gravity"`           // World units per second squared
+	Damping  float64     `json:"damping,omitempty"` // Fraction of speed lost per second
+	Rotation Range       `json:"rotation"`          // Starting rotation in radians
+	Spin     Range       `json:"spin"`              // Radians per second
+
+	Size  []SizeKey  `json:"size,omitempty"`  // Scale of the sprite over the lifetime, 1 when empty
+	Color []ColorKey `json:"color,omitempty"` // Tint over the lifetime, white when empty
+	Blend string     `json:"blend,omitempty"` // Blend mode name, normal when empty
+	Layer string     `json:"layer,omitempty"` // Render layer; an attached emitter uses its entity's when empty
+
+	blend entity.BlendMode
+}
+
+// Burst emits a number of particles at once, a time after the emitter starts
+type Burst struct {
+	Time  float64 `json:"time"`
+	Count int     `json:"count"`
+}
+
+// Shape is the area particles start in, around the emitter's position
+type Shape struct {
+	Kind   string  `json:"kind"`             // "point", "circle", "ring", "rect" or "line"
+	Radius float64 `json:"radius,omitempty"` // Of a circle or ring
+	Width  float64 `json:"width,omitempty"`  // Of a rect or line, which runs along x
+	Height float64 `json:"height,omitempty"` // Of a rect
+}
+
+// Range is a value picked at random between Min and Max for each particle. In a
+// definition file it is either a number or {"min": a, "max": b}.
+type Range struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+func (r *Range) UnmarshalJSON(data []byte) error {
+	var value float64
+	if err := json.Unmarshal(data, &value); err == nil {
+		r.Min, r.Max = value, value
+		return nil
+	}
+	type plain Range
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+// SizeKey is the sprite's scale at a point of a particle's life, from 0 at birth to 1 at death
+type SizeKey struct {
+	T     float64 `json:"t"`
+	Value float64 `json:"value"`
+}
+
+// ColorKey is the tint at a point of a particle's life. Colours are hex, "#rrggbb"
+// or "#rrggbbaa".
+type ColorKey struct {
+	T     float64 `json:"t"`
+	Color string  `json:"color"`
+
+	rgba color.RGBA
+}
+
+// LoadFile parses a particle definition file without registering it
+func LoadFile(files fs.FS, file string) (*Definition, error) {
+	data, err := fs.ReadFile(files, vfs.Clean(file))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read particle file %s: %w", file, err)
+	}
+	d := &Definition{}
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, fmt.Errorf("failed to parse particle file %s: %w", file, err)
+	}
+	if err := d.normalize(); err != nil {
+		return nil, fmt.Errorf("invalid particle file %s: %w", file, err)
+	}
+	d.Name = strings.TrimSuffix(path.Base(vfs.Clean(file)), Extension)
+	d.Path = file
+	return d, nil
+}
+
+// normalize checks the definition and fills in defaults that JSON leaves as zero values
+func (d *Definition) normalize() error {
+	if d.MaxParticles <= 0 {
+		d.MaxParticles = 256
+	}
+	if d.MaxParticles > MaxParticles {
+		d.MaxParticles = MaxParticles
+	}
+	if d.Lifetime.Max <= 0 {
+		d.Lifetime = Range{Min: 1, Max: 1}
+	}
+	if d.Lifetime.Min <= 0 || d.Lifetime.Min > d.Lifetime.Max {
+		d.Lifetime.Min = d.Lifetime.Max
+	}
+	switch d.Shape.Kind {
+	case "":
+		d.Shape.Kind = "point"
+	case "point", "circle", "ring", "rect", "line":
+	default:
+		return fmt.Errorf("unknown shape %q, expected point, circle, ring, rect or line", d.Shape.Kind)
+	}
+	blend, ok := entity.ParseBlendMode(d.Blend)
+	if d.Blend != "" && !ok {
+		return fmt.Errorf("unknown blend mode %q, expected one of %s", d.Blend, strings.Join(entity.BlendModeNames(), ", "))
+	}
+	d.blend = blend
+	for i := range d.Color {
+		c, err := entity.ParseColor(d.Color[i].Color)
+		if err != nil {
+			return err
+		}
+		d.Color[i].rgba = c
+	}
+	return nil
+}
+
+// BlendMode returns the blend mode particles are drawn with
+func (d *Definition) BlendMode() entity.BlendMode {
+	return d.blend
+}
+
+// sizeAt returns the sprite scale at a point of a particle's life
+func (d *Definition) sizeAt(t float64) float64 {
+	keys := d.Size
+	if len(keys) == 0 {
+		return 1
+	}
+	if t <= keys[0].T {
+		return keys[0].Value
+	}
+	for i := 1; i < len(keys); i++ {
+		if t <= keys[i].T {
+			from, to := keys[i-1], keys[i]
+			return from.Value + (to.Value-from.Value)*fraction(t, from.T, to.T)
+		}
+	}
+	return keys[len(keys)-1].Value
+}
+
+// colorAt returns the tint at a point of a particle's life
+func (d *Definition) colorAt(t float64) color.RGBA {
+	keys := d.Color
+	if len(keys) == 0 {
+		return entity.White
+	}
+	if t <= keys[0].T {
+		return keys[0].rgba
+	}
+	for i := 1; i < len(keys); i++ {
+		if t <= keys[i].T {
+			from, to := keys[i-1].rgba, keys[i].rgba
+			f := fraction(t, keys[i-1].T, keys[i].T)
+			lerp := func(a, b uint8) uint8 {
+				return uint8(math.Round(float64(a) + (float64(b)-float64(a))*f))
+			}
+			return color.RGBA{lerp(from.R, to.R), lerp(from.G, to.G), lerp(from.B, to.B), lerp(from.A, to.A)}
+		}
+	}
+	return keys[len(keys)-1].rgba
+}
+
+// fraction returns how far t is from a to b, from 0 to 1
+func fraction(t, a, b float64) float64 {
+	if b <= a {
+		return 1
+	}
+	return (t - a) / (b - a)
+}
@@ -0,0 +1,244 @@
+package particles
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"deepthinking.do/luengo/engine/entity"
+)
+
+// particle is one live particle; its position and velocity are in world units
+type particle struct {
+	x, y     float64
+	vx, vy   float64
+	rotation float64
+	spin     float64
+	age      float64
+	life     float64
+}
+
+// Emitter emits and simulates the particles of one definition. Its particles live
+// in a pool sized by the definition, so emitting allocates nothing.
+type Emitter struct {
+	ID         int
+	Definition *Definition
+	X, Y       float64 // World position, kept on the entity when attached
+	Angle      float64 // Radians added to the definition's direction
+	Rate       float64 // Particles per second, starting at the definition's rate
+	Playing    bool
+	OneShot    bool // Removed once it has played through and its particles are gone
+
+	Entity *entity.Entity // Attached entity, or nil
+	Offset entity.Vec2    // From the attached entity's centre
+
+	particles []particle // The first alive are live
+	alive     int
+	time      float64 // Seconds since the emitter started or looped
+	pending   float64 // Fraction of a particle owed by the rate
+	burst     int     // Next burst to emit
+
+	vertices []ebiten.Vertex
+	indices  []uint16
+}
+
+func newEmitter(id int, d *Definition) *Emitter {
+	e := &Emitter{ID: id, Rate: d.Rate, Playing: true}
+	e.setDefinition(d)
+	return e
+}
+
+// setDefinition switches the emitter to a definition, e.g. after its file was
+// reloaded, keeping as many live particles as the new pool holds
+func (e *Emitter) setDefinition(d *Definition) {
+	if e.Definition != nil && e.Rate == e.Definition.Rate {
+		e.Rate = d.Rate
+	}
+	e.Definition = d
+	if len(e.particles) != d.MaxParticles {
+		pool := make([]particle, d.MaxParticles)
+		e.alive = copy(pool, e.particles[:e.alive])
+		e.particles = pool
+		e.vertices = nil
+	}
+	e.burst = 0
+	for e.burst < len(d.Bursts) && d.Bursts[e.burst].Time < e.time {
+		e.burst++
+	}
+}
+
+// Alive returns the number of live particles
+func (e *Emitter) Alive() int {
+	return e.alive
+}
+
+// Finished reports whether the emitter has stopped and all its particles are gone
+func (e *Emitter) Finished() bool {
+	return !e.Playing && e.alive == 0
+}
+
+// Restart plays the emitter from the beginning, keeping the live particles
+func (e *Emitter) Restart() {
+	e.time, e.pending, e.burst = 0, 0, 0
+	e.Playing = true
+}
+
+// Emit spawns up to count particles at once, as many as the pool has room for
+func (e *Emitter) Emit(count int) {
+	d := e.Definition
+	for i := 0; i < count && e.alive < len(e.particles); i++ {
+		p := &e.particles[e.alive]
+		e.alive++
+
+		x, y := e.shapePoint()
+		angle := d.Angle + e.Angle + (rand.Float64()*2-1)*d.Spread
+		speed := pick(d.Speed)
+		*p = particle{
+			x: e.X + x, y: e.Y + y,
+			vx: math.Cos(angle) * speed, vy: math.Sin(angle) * speed,
+			rotation: pick(d.Rotation),
+			spin:     pick(d.Spin),
+			life:     pick(d.Lifetime),
+		}
+	}
+}
+
+// shapePoint returns a random start position relative to the emitter, turned with it
+func (e *Emitter) shapePoint() (float64, float64) {
+	s := e.Definition.Shape
+	var x, y float64
+	switch s.Kind {
+	case "circle":
+		// The square root spreads points evenly over the area
+		r := s.Radius * math.Sqrt(rand.Float64())
+		a := rand.Float64() * 2 * math.Pi
+		x, y = math.Cos(a)*r, math.Sin(a)*r
+	case "ring":
+		a := rand.Float64() * 2 * math.Pi
+		x, y = math.Cos(a)*s.Radius, math.Sin(a)*s.Radius
+	case "rect":
+		x, y = (rand.Float64()-0.5)*s.Width, (rand.Float64()-0.5)*s.Height
+	case "line":
+		x = (rand.Float64() - 0.5) * s.Width
+	default:
+		return 0, 0
+	}
+	if e.Angle == 0 {
+		return x, y
+	}
+	sin, cos := math.Sincos(e.Angle)
+	return x*cos - y*sin, x*sin + y*cos
+}
+
+// pick returns a random value in a range
+func pick(r Range) float64 {
+	return r.Min + rand.Float64()*(r.Max-r.Min)
+}
+
+// Update moves the emitter with its entity, ages and moves its particles and emits
+// new ones. dt is in seconds.
+func (e *Emitter) Update(dt float64) {
+	if e.Entity != nil {
+		x, y := e.Entity.WorldCenter()
+		e.X, e.Y = x+e.Offset.X, y+e.Offset.Y
+	}
+	e.simulate(dt)
+	if !e.Playing {
+		return
+	}
+
+	d := e.Definition
+	e.time += dt
+	for e.burst < len(d.Bursts) && d.Bursts[e.burst].Time <= e.time {
+		e.Emit(d.Bursts[e.burst].Count)
+		e.burst++
+	}
+	if e.Rate > 0 {
+		e.pending += e.Rate * dt
+		count := int(e.pending)
+		e.pending -= float64(count)
+		e.Emit(count)
+	}
+	if d.Duration <= 0 {
+		// Without a duration a one-shot emitter only plays its bursts at the start
+		if e.OneShot && e.burst >= len(d.Bursts) {
+			e.Playing = false
+		}
+		return
+	}
+	if e.time >= d.Duration {
+		if d.Loop && !e.OneShot {
+			e.time -= d.Duration
+			e.burst = 0
+		} else {
+			e.Playing = false
+		}
+	}
+}
+
+func (e *Emitter) simulate(dt float64) {
+	d := e.Definition
+	damping := math.Max(0, 1-d.Damping*dt)
+	for i := 0; i < e.alive; {
+		p := &e.particles[i]
+		p.age += dt
+		if p.age >= p.life {
+			// Swap the last live particle into the dead one's place
+			e.alive--
+			e.particles[i] = e.particles[e.alive]
+			continue
+		}
+		p.vx = p.vx*damping + d.Gravity.X*dt
+		p.vy = p.vy*damping + d.Gravity.Y*dt
+		p.x += p.vx * dt
+		p.y += p.vy * dt
+		p.rotation += p.spin * dt
+		i++
+	}
+}
+
+// Draw draws the live particles onto dst in one batch. img is the particle sprite and
+// view converts world positions to dst's pixels.
+func (e *Emitter) Draw(dst, img *ebiten.Image, view ebiten.GeoM, blend ebiten.Blend) {
+	if e.alive == 0 {
+		return
+	}
+	d := e.Definition
+	if e.vertices == nil {
+		e.vertices = make([]ebiten.Vertex, 0, len(e.particles)*4)
+		e.indices = make([]uint16, 0, len(e.particles)*6)
+	}
+	bounds := img.Bounds()
+	srcX0, srcY0 := float32(bounds.Min.X), float32(bounds.Min.Y)
+	srcX1, srcY1 := float32(bounds.Max.X), float32(bounds.Max.Y)
+	halfW, halfH := float64(bounds.Dx())/2, float64(bounds.Dy())/2
+
+	vertices, indices := e.vertices[:0], e.indices[:0]
+	for i := 0; i < e.alive; i++ {
+		p := &e.particles[i]
+		t := p.age / p.life
+		size := d.sizeAt(t)
+		c := d.colorAt(t)
+		if size <= 0 || c.A == 0 {
+			continue
+		}
+		sin, cos := math.Sincos(p.rotation)
+		r, g, b, a := float32(c.R)/255, float32(c.G)/255, float32(c.B)/255, float32(c.A)/255
+		base := uint16(len(vertices))
+		for _, corner := range [4][4]float32{{-1, -1, srcX0, srcY0}, {1, -1, srcX1, srcY0}, {1, 1, srcX1, srcY1}, {-1, 1, srcX0, srcY1}} {
+			cx, cy := float64(corner[0])*halfW*size, float64(corner[1])*halfH*size
+			x, y := view.Apply(p.x+cx*cos-cy*sin, p.y+cx*sin+cy*cos)
+			vertices = append(vertices, ebiten.Vertex{
+				DstX: float32(x), DstY: float32(y),
+				SrcX: corner[2], SrcY: corner[3],
+				ColorR: r, ColorG: g, ColorB: b, ColorA: a,
+			})
+		}
+		indices = append(indices, base, base+1, base+2, base, base+2, base+3)
+	}
+	e.vertices, e.indices = vertices, indices
+	if len(vertices) > 0 {
+		dst.DrawTriangles(vertices, indices, img, &ebiten.DrawTrianglesOptions{Blend: blend})
+	}
+}
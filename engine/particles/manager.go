@@ -0,0 +1,378 @@
+package particles
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
+	"math"
+	"path"
+	"sort"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"deepthinking.do/luengo/engine/camera"
+	"deepthinking.do/luengo/engine/entity"
+	"deepthinking.do/luengo/engine/logging"
+	"deepthinking.do/luengo/engine/render"
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+// SpriteLoader loads particle sprites by asset path
+type SpriteLoader interface {
+	LoadSprite(path string) (*ebiten.Image, error)
+}
+
+// Manager holds the particle definitions and runs their emitters. Entities whose
+// Particles field names a definition get an emitter that follows them.
+type Manager struct {
+	definitions map[string]*Definition
+	emitters    []*Emitter
+	attached    map[*entity.Entity]*Emitter
+	missing     map[string]bool // Definitions entities asked for that do not exist, logged once
+	nextID      int
+	preview     *Emitter // Runs in the editor, outside the game
+
+	entities *entity.Manager
+	sprites  SpriteLoader
+	files    fs.FS
+	images   map[string]*ebiten.Image // Sprites by path
+	dot      *ebiten.Image            // Drawn for definitions without a sprite
+}
+
+// NewManager creates a particle manager that reads definition files from files
+func NewManager(entities *entity.Manager, sprites SpriteLoader, files fs.FS) *Manager {
+	return &Manager{
+		definitions: make(map[string]*Definition),
+		attached:    make(map[*entity.Entity]*Emitter),
+		missing:     make(map[string]bool),
+		nextID:      1,
+		entities:    entities,
+		sprites:     sprites,
+		files:       files,
+		images:      make(map[string]*ebiten.Image),
+	}
+}
+
+// LoadFromFolder registers every particle definition found in a folder and its subfolders
+func (m *Manager) LoadFromFolder(folder string) error {
+	return fs.WalkDir(m.files, vfs.Clean(folder), func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			logging.Warnf("particles", "Walk error: %v", err)
+			return nil
+		}
+		if !d.IsDir() && path.Ext(file) == Extension {
+			def, err := LoadFile(m.files, file)
+			if err != nil {
+				logging.Errorf("particles", "%v", err)
+				return nil
+			}
+			m.Register(def)
+			logging.Infof("particles", "Loaded: %s (%s)", def.Name, file)
+		}
+		return nil
+	})
+}
+
+// Register adds or replaces a definition; emitters using the one it replaces switch to it
+func (m *Manager) Register(d *Definition) {
+	old := m.definitions[d.Name]
+	m.definitions[d.Name] = d
+	delete(m.missing, d.Name)
+	if old == nil {
+		return
+	}
+	for _, e := range m.emitters {
+		if e.Definition == old {
+			e.setDefinition(d)
+		}
+	}
+	if m.preview != nil && m.preview.Definition == old {
+		m.preview.setDefinition(d)
+	}
+}
+
+func (m *Manager) Get(name string) (*Definition, bool) {
+	d, ok := m.definitions[name]
+	return d, ok
+}
+
+// Names returns the registered definition names in alphabetical order
+func (m *Manager) Names() []string {
+	names := make([]string, 0, len(m.definitions))
+	for name := range m.definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reload re-reads a definition file, e.g. after it changed, and updates the emitters using it
+func (m *Manager) Reload(file string) error {
+	d, err := LoadFile(m.files, file)
+	if err != nil {
+		return err
+	}
+	m.Register(d)
+	logging.Infof("particles", "Reloaded: %s (%s)", d.Name, file)
+	return nil
+}
+
+// Spawn starts an emitter of a definition at a world position
+func (m *Manager) Spawn(name string, x, y float64) (*Emitter, error) {
+	d, ok := m.definitions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown particle effect: %s", name)
+	}
+	e := newEmitter(m.nextID, d)
+	m.nextID++
+	e.X, e.Y = x, y
+	m.emitters = append(m.emitters, e)
+	return e, nil
+}
+
+// Burst plays a definition once at a world position; the emitter is removed when its
+// particles are gone
+func (m *Manager) Burst(name string, x, y float64) (*Emitter, error) {
+	e, err := m.Spawn(name, x, y)
+	if err != nil {
+		return nil, err
+	}
+	e.OneShot = true
+	return e, nil
+}
+
+// Attach gives an entity an emitter of a definition that follows it, replacing any it has
+func (m *Manager) Attach(owner *entity.Entity, name string, offset entity.Vec2) (*Emitter, error) {
+	e, err := m.Spawn(name, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	m.Detach(owner)
+	owner.Particles = name
+	e.Entity, e.Offset = owner, offset
+	e.X, e.Y = owner.WorldCenter()
+	e.X += offset.X
+	e.Y += offset.Y
+	m.attached[owner] = e
+	return e, nil
+}
+
+// Detach stops an entity's emitter, letting its live particles finish
+func (m *Manager) Detach(owner *entity.Entity) {
+	owner.Particles = ""
+	if e, ok := m.attached[owner]; ok {
+		m.release(owner, e)
+	}
+}
+
+func (m *Manager) release(owner *entity.Entity, e *Emitter) {
+	delete(m.attached, owner)
+	e.Entity = nil
+	e.Playing = false
+	e.OneShot = true
+}
+
+// Emitter returns a running emitter by id
+func (m *Manager) Emitter(id int) (*Emitter, bool) {
+	for _, e := range m.emitters {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// Emitters returns the running emitters in the order they were started
+func (m *Manager) Emitters() []*Emitter {
+	return append([]*Emitter(nil), m.emitters...)
+}
+
+// Remove stops an emitter at once, its particles included
+func (m *Manager) Remove(id int) bool {
+	for i, e := range m.emitters {
+		if e.ID == id {
+			if e.Entity != nil {
+				e.Entity.Particles = ""
+				delete(m.attached, e.Entity)
+			}
+			m.emitters = append(m.emitters[:i], m.emitters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Clear removes every emitter, e.g. when the scene changes
+func (m *Manager) Clear() {
+	m.emitters = nil
+	m.attached = make(map[*entity.Entity]*Emitter)
+	// Sprites are referenced by the old scene, so they may be evicted; load them again
+	m.images = make(map[string]*ebiten.Image)
+}
+
+// Count returns the number of live particles across every emitter
+func (m *Manager) Count() int {
+	count := 0
+	for _, e := range m.emitters {
+		count += e.alive
+	}
+	return count
+}
+
+// Update runs every emitter for dt seconds, first matching the emitters attached to
+// entities to their Particles field
+func (m *Manager) Update(dt float64) {
+	m.syncAttached()
+	kept := m.emitters[:0]
+	for _, e := range m.emitters {
+		e.Update(dt)
+		if e.OneShot && e.Finished() {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	// Clear the tail so removed emitters can be collected
+	for i := len(kept); i < len(m.emitters); i++ {
+		m.emitters[i] = nil
+	}
+	m.emitters = kept
+}
+
+// syncAttached starts emitters for entities that name a definition and releases
+// those of entities that were removed or changed theirs
+func (m *Manager) syncAttached() {
+	for owner, e := range m.attached {
+		current, ok := m.entities.GetEntity(owner.ID)
+		if !ok || current != owner || owner.Particles != e.Definition.Name {
+			m.release(owner, e)
+		}
+	}
+	for _, owner := range m.entities.GetEntitiesSlice() {
+		if owner.Particles == "" {
+			continue
+		}
+		if _, ok := m.attached[owner]; ok {
+			continue
+		}
+		if _, ok := m.definitions[owner.Particles]; !ok {
+			if !m.missing[owner.Particles] {
+				m.missing[owner.Particles] = true
+				logging.Warnf("particles", "%s uses unknown particle effect %s", owner.Name, owner.Particles)
+			}
+			continue
+		}
+		m.Attach(owner, owner.Particles, entity.Vec2{})
+	}
+}
+
+// StartPreview loads a definition file afresh and plays it over and over at a world
+// position, for the editor. It replaces any preview already running.
+func (m *Manager) StartPreview(file string, x, y float64) error {
+	d, err := LoadFile(m.files, file)
+	if err != nil {
+		return err
+	}
+	m.Register(d)
+	m.preview = newEmitter(0, d)
+	m.preview.X, m.preview.Y = x, y
+	return nil
+}
+
+func (m *Manager) StopPreview() {
+	m.preview = nil
+}
+
+// PreviewPath returns the file of the definition being previewed, or "" for none
+func (m *Manager) PreviewPath() string {
+	if m.preview == nil {
+		return ""
+	}
+	return m.preview.Definition.Path
+}
+
+// UpdatePreview runs the editor preview for dt seconds, restarting it when it ends
+func (m *Manager) UpdatePreview(dt float64) {
+	if m.preview == nil {
+		return
+	}
+	m.preview.Update(dt)
+	if !m.preview.Playing && m.preview.alive == 0 {
+		m.preview.Restart()
+	}
+}
+
+// Draw draws the particles a camera sees into dst, which the camera has just drawn
+// its entities into
+func (m *Manager) Draw(dst *ebiten.Image, cam *camera.Camera, layers *render.Layers) {
+	view := cam.GetTransformMatrix()
+	emitters := m.emitters
+	if m.preview != nil {
+		emitters = append(emitters[:len(emitters):len(emitters)], m.preview)
+	}
+	for _, e := range emitters {
+		if e.alive == 0 {
+			continue
+		}
+		layer := e.Definition.Layer
+		if layer == "" && e.Entity != nil {
+			layer = e.Entity.Layer
+		}
+		if !cam.DrawsLayer(layer) {
+			continue
+		}
+		if l, ok := layers.Get(layer); ok && !l.Visible {
+			continue
+		}
+		e.Draw(dst, m.sprite(e.Definition), view, render.Blend(e.Definition.BlendMode()))
+	}
+}
+
+// ReplaceSprite switches definitions to a sprite that was reloaded as a new image
+func (m *Manager) ReplaceSprite(previous, sprite *ebiten.Image) {
+	for path, img := range m.images {
+		if img == previous {
+			m.images[path] = sprite
+		}
+	}
+}
+
+// sprite returns the image a definition's particles are drawn with
+func (m *Manager) sprite(d *Definition) *ebiten.Image {
+	if d.Sprite == "" || m.sprites == nil {
+		return m.softDot()
+	}
+	img, ok := m.images[d.Sprite]
+	if !ok {
+		var err error
+		img, err = m.sprites.LoadSprite(d.Sprite)
+		if err != nil {
+			// Remember the failure so the file is not read every frame
+			logging.Errorf("particles", "%v", err)
+		}
+		m.images[d.Sprite] = img
+	}
+	if img == nil {
+		return m.softDot()
+	}
+	return img
+}
+
+// softDot returns a white dot that fades towards its edge
+func (m *Manager) softDot() *ebiten.Image {
+	if m.dot != nil {
+		return m.dot
+	}
+	const size = 16
+	pixels := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x)+0.5-size/2, float64(y)+0.5-size/2
+			a := math.Max(0, 1-math.Hypot(dx, dy)/(size/2))
+			v := uint8(255 * a * a)
+			pixels.SetRGBA(x, y, color.RGBA{v, v, v, v})
+		}
+	}
+	m.dot = ebiten.NewImageFromImage(pixels)
+	return m.dot
+}
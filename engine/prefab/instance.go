@@ -0,0 +1,639 @@
+package prefab
+
+import (
+	"fmt"
+	"image/color"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"deepthinking.do/luengo/engine/entity"
+	"deepthinking.do/luengo/engine/logging"
+)
+
+// Overrides holds per-instance property overrides, keyed by node path and then property key
+// (e.g. "name", "scale.x", "components.health.max"). A nil value removes a component field
+// or material uniform the prefab has.
+type Overrides map[string]map[string]interface{}
+
+// Instantiate creates a new instance of a prefab with its root at the given position
+func (pm *Manager) Instantiate(name string, x, y float64) (*entity.Entity, error) {
+	return pm.InstantiateWithOverrides(name, x, y, nil)
+}
+
+// InstantiateWithOverrides creates a prefab instance and applies per-instance overrides
+func (pm *Manager) InstantiateWithOverrides(name string, x, y float64, overrides Overrides) (*entity.Entity, error) {
+	p, ok := pm.prefabs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown prefab: %s", name)
+	}
+
+	root := pm.build(p, p.Root, "", nil)
+	root.Position = entity.Vec2{X: x, Y: y}
+
+	nodes := instanceNodes(root)
+	for path, values := range overrides {
+		if e, ok := nodes[path]; ok {
+			pm.applyValues(e, values)
+		}
+	}
+	return root, nil
+}
+
+// CreateFromNode creates a plain entity (not linked to a prefab) from node data, without children
+func (pm *Manager) CreateFromNode(n *Node) *entity.Entity {
+	e := pm.entities.CreateEntity(n.Name, nil)
+	pm.applyValues(e, n.values(true))
+	return e
+}
+
+// NodeFromEntity captures an entity's properties as node data, without children
+func NodeFromEntity(e *entity.Entity) *Node {
+	components := make(entity.Components, len(e.Components))
+	for name, data := range e.Components {
+		components[name] = copyMap(data)
+	}
+	n := &Node{
+		Name:       e.Name,
+		Sprite:     e.SpritePath,
+		Layer:      e.Layer,
+		Z:          e.Z,
+		Position:   e.Position,
+		Rotation:   e.Rotation,
+		Scale:      e.Scale,
+		Pivot:      e.Pivot,
+		FlipX:      e.FlipX,
+		FlipY:      e.FlipY,
+		Components: components,
+	}
+	// Leave out the defaults so saved scenes stay short
+	if e.Tint != entity.White {
+		n.Tint = formatColor(e.Tint)
+	}
+	if e.Alpha != 1 {
+		alpha := e.Alpha
+		n.Alpha = &alpha
+	}
+	if e.Blend != entity.BlendNormal {
+		n.Blend = e.Blend.String()
+	}
+	if m := e.Material; m != nil {
+		n.Material = &MaterialNode{Shader: m.ShaderPath, Uniforms: copyMap(m.Uniforms)}
+	}
+	if l := e.Light; l != nil {
+		intensity, shadows := l.Intensity, l.Shadows
+		n.Light = &LightNode{
+			Kind:      l.Kind.String(),
+			Color:     formatColor(l.Color),
+			Intensity: &intensity,
+			Radius:    l.Radius,
+			Angle:     l.Angle,
+			Spread:    l.Spread,
+			Offset:    l.Offset,
+			Shadows:   &shadows,
+		}
+	}
+	n.Occluder = e.Occluder
+	n.Particles = e.Particles
+	return n
+}
+
+// InstanceOverrides returns the properties of a prefab instance that differ from its prefab
+func (pm *Manager) InstanceOverrides(root *entity.Entity) Overrides {
+	if !root.IsPrefabRoot() {
+		return nil
+	}
+	p, ok := pm.prefabs[root.Prefab.Name]
+	if !ok {
+		return nil
+	}
+	return diffInstance(p, root)
+}
+
+func (pm *Manager) build(p *Prefab, n *Node, path string, parent *entity.Entity) *entity.Entity {
+	e := pm.entities.CreateEntity(n.Name, nil)
+	e.Prefab = &entity.PrefabLink{Name: p.Name, Path: path}
+	pm.applyValues(e, n.values(path != ""))
+	if parent != nil {
+		pm.entities.SetParent(e, parent, false)
+	}
+	for i, child := range n.Children {
+		pm.build(p, child, childPath(path, i), e)
+	}
+	return e
+}
+
+// propagate applies changes between two versions of a prefab to every live instance,
+// keeping the properties each instance overrides
+func (pm *Manager) propagate(old, updated *Prefab) {
+	// Find the instances first; updating one removes entities, which may include
+	// instances nested in it
+	var instances []*entity.Entity
+	for _, e := range pm.entities.GetEntitiesSlice() {
+		if e.IsPrefabRoot() && e.Prefab.Name == old.Name {
+			instances = append(instances, e)
+		}
+	}
+
+	for _, e := range instances {
+		if _, alive := pm.entities.GetEntity(e.ID); !alive {
+			continue
+		}
+
+		overrides := diffInstance(old, e)
+		nodes := instanceNodes(e)
+		newNodes := flattenNodes(updated.Root, "")
+
+		// Drop entities whose node no longer exists, deciding which before removing any
+		var removed []string
+		for path := range nodes {
+			if _, ok := newNodes[path]; !ok {
+				removed = append(removed, path)
+			}
+		}
+		for _, path := range removed {
+			pm.entities.RemoveEntity(nodes[path].ID)
+			delete(nodes, path)
+		}
+
+		// Create entities for nodes added to the prefab
+		for _, path := range sortedPaths(newNodes) {
+			if _, ok := nodes[path]; ok {
+				continue
+			}
+			parent, ok := nodes[parentPath(path)]
+			if !ok {
+				continue
+			}
+			nodes[path] = pm.build(updated, newNodes[path], path, parent)
+		}
+
+		// Reapply the new prefab values, then the instance's overrides
+		for path, node := range newNodes {
+			target, ok := nodes[path]
+			if !ok {
+				continue
+			}
+			values := node.values(path != "")
+			for key, value := range overrides[path] {
+				values[key] = value
+			}
+			pm.applyValues(target, values)
+		}
+	}
+}
+
+// diffInstance compares each node of an instance against the prefab it came from
+func diffInstance(p *Prefab, root *entity.Entity) Overrides {
+	overrides := make(Overrides)
+	nodes := flattenNodes(p.Root, "")
+	for path, e := range instanceNodes(root) {
+		node, ok := nodes[path]
+		if !ok {
+			continue
+		}
+		expected := node.values(path != "")
+		actual := entityValues(e, path != "")
+		for key, value := range actual {
+			if !sameValue(expected[key], value) {
+				if overrides[path] == nil {
+					overrides[path] = make(map[string]interface{})
+				}
+				overrides[path][key] = value
+			}
+		}
+		// A component field or uniform the instance removed is recorded as nil, so
+		// applying the overrides removes it again
+		for key := range expected {
+			if _, ok := actual[key]; !ok && removable(key) {
+				if overrides[path] == nil {
+					overrides[path] = make(map[string]interface{})
+				}
+				overrides[path][key] = nil
+			}
+		}
+	}
+	return overrides
+}
+
+// removable reports whether an override key names a value an instance can remove
+func removable(key string) bool {
+	return strings.HasPrefix(key, "components.") || strings.HasPrefix(key, "material.uniforms.")
+}
+
+// removeValue deletes the component field or uniform a nil override stands for,
+// and a component left with no fields
+func removeValue(e *entity.Entity, key string) {
+	if name, ok := strings.CutPrefix(key, "material.uniforms."); ok {
+		if e.Material != nil {
+			delete(e.Material.Uniforms, name)
+		}
+		return
+	}
+	parts := strings.SplitN(key, ".", 3)
+	if len(parts) != 3 || parts[0] != "components" {
+		return
+	}
+	if data, ok := e.Components[parts[1]]; ok {
+		delete(data, parts[2])
+		if len(data) == 0 {
+			delete(e.Components, parts[1])
+		}
+	}
+}
+
+// values flattens a node's properties into override keys
+func (n *Node) values(includePosition bool) map[string]interface{} {
+	values := map[string]interface{}{
+		"name":     n.Name,
+		"sprite":   n.Sprite,
+		"layer":    n.Layer,
+		"z":        n.Z,
+		"rotation": n.Rotation,
+		"scale.x":  n.Scale.X,
+		"scale.y":  n.Scale.Y,
+		"pivot.x":  n.Pivot.X,
+		"pivot.y":  n.Pivot.Y,
+		"flip.x":   n.FlipX,
+		"flip.y":   n.FlipY,
+		"tint":     formatColor(parseColor(n.Tint)),
+		"alpha":    1.0,
+		"blend":    entity.BlendNormal.String(),
+	}
+	if n.Alpha != nil {
+		values["alpha"] = *n.Alpha
+	}
+	if blend, ok := entity.ParseBlendMode(n.Blend); ok {
+		values["blend"] = blend.String()
+	}
+	values["material.shader"] = ""
+	if n.Material != nil {
+		values["material.shader"] = n.Material.Shader
+		for name, value := range n.Material.Uniforms {
+			if uniform, ok := UniformValue(value); ok {
+				values["material.uniforms."+name] = uniform
+			}
+		}
+	}
+	values["light.kind"] = ""
+	if n.Light != nil {
+		for key, value := range n.Light.values() {
+			values["light."+key] = value
+		}
+	}
+	values["occluder"] = n.Occluder
+	values["particles"] = n.Particles
+	if includePosition {
+		values["position.x"] = n.Position.X
+		values["position.y"] = n.Position.Y
+	}
+	for component, data := range n.Components {
+		for key, value := range data {
+			values["components."+component+"."+key] = value
+		}
+	}
+	return values
+}
+
+// values flattens a light's properties, filling in the defaults
+func (n *LightNode) values() map[string]interface{} {
+	defaults := entity.NewLight(entity.LightPoint)
+	kind, _ := entity.ParseLightKind(n.Kind)
+	values := map[string]interface{}{
+		"kind":      kind.String(),
+		"color":     formatColor(parseColor(n.Color)),
+		"intensity": defaults.Intensity,
+		"radius":    defaults.Radius,
+		"angle":     n.Angle,
+		"spread":    defaults.Spread,
+		"offset.x":  n.Offset.X,
+		"offset.y":  n.Offset.Y,
+		"shadows":   defaults.Shadows,
+	}
+	if n.Intensity != nil {
+		values["intensity"] = *n.Intensity
+	}
+	if n.Radius > 0 {
+		values["radius"] = n.Radius
+	}
+	if n.Spread > 0 {
+		values["spread"] = n.Spread
+	}
+	if n.Shadows != nil {
+		values["shadows"] = *n.Shadows
+	}
+	return values
+}
+
+// entityValues flattens an entity's properties into override keys
+func entityValues(e *entity.Entity, includePosition bool) map[string]interface{} {
+	return NodeFromEntity(e).values(includePosition)
+}
+
+// applyValues sets entity properties from flattened override keys
+func (pm *Manager) applyValues(e *entity.Entity, values map[string]interface{}) {
+	applyLight(e, values)
+	for key, value := range values {
+		if value == nil {
+			removeValue(e, key)
+			continue
+		}
+		number, _ := toFloat(value)
+		switch key {
+		case "name":
+			e.Name = fmt.Sprint(value)
+		case "sprite":
+			pm.setSprite(e, fmt.Sprint(value))
+		case "layer":
+			e.Layer = fmt.Sprint(value)
+		case "z":
+			e.Z = int(number)
+		case "rotation":
+			e.Rotation = number
+		case "scale.x":
+			e.Scale.X = number
+		case "scale.y":
+			e.Scale.Y = number
+		case "pivot.x":
+			e.Pivot.X = number
+		case "pivot.y":
+			e.Pivot.Y = number
+		case "flip.x":
+			e.FlipX = value == true
+		case "flip.y":
+			e.FlipY = value == true
+		case "tint":
+			e.Tint = parseColor(fmt.Sprint(value))
+		case "alpha":
+			e.Alpha = number
+		case "blend":
+			e.Blend, _ = entity.ParseBlendMode(fmt.Sprint(value))
+		case "material.shader":
+			pm.setShader(e, fmt.Sprint(value))
+		case "occluder":
+			e.Occluder = value == true
+		case "particles":
+			e.Particles = fmt.Sprint(value)
+		case "position.x":
+			e.Position.X = number
+		case "position.y":
+			e.Position.Y = number
+		default:
+			if name, ok := strings.CutPrefix(key, "material.uniforms."); ok {
+				// Keys come in any order, so the shader may be set after its uniforms
+				if uniform, ok := UniformValue(value); ok {
+					if e.Material == nil {
+						e.Material = entity.NewMaterial(nil, "")
+					}
+					e.Material.Uniforms[name] = uniform
+				}
+				continue
+			}
+			parts := strings.SplitN(key, ".", 3)
+			if len(parts) != 3 || parts[0] != "components" {
+				// Light keys were applied by applyLight
+				continue
+			}
+			if e.Components == nil {
+				e.Components = make(entity.Components)
+			}
+			if e.Components[parts[1]] == nil {
+				e.Components[parts[1]] = make(map[string]interface{})
+			}
+			e.Components[parts[1]][parts[2]] = value
+		}
+	}
+}
+
+// applyLight sets the entity's light from the light.* override keys. An empty
+// light.kind removes the light, whatever the other keys say; any other light key
+// gives the entity a light if it has none.
+func applyLight(e *entity.Entity, values map[string]interface{}) {
+	if kind, ok := values["light.kind"]; ok {
+		if fmt.Sprint(kind) == "" {
+			e.Light = nil
+			return
+		}
+		if e.Light == nil {
+			e.Light = entity.NewLight(entity.LightPoint)
+		}
+		e.Light.Kind, _ = entity.ParseLightKind(fmt.Sprint(kind))
+	}
+	for key, value := range values {
+		name, ok := strings.CutPrefix(key, "light.")
+		if !ok || name == "kind" {
+			continue
+		}
+		if e.Light == nil {
+			e.Light = entity.NewLight(entity.LightPoint)
+		}
+		number, _ := toFloat(value)
+		switch name {
+		case "color":
+			e.Light.Color = parseColor(fmt.Sprint(value))
+		case "intensity":
+			e.Light.Intensity = number
+		case "radius":
+			e.Light.Radius = number
+		case "angle":
+			e.Light.Angle = number
+		case "spread":
+			e.Light.Spread = number
+		case "offset.x":
+			e.Light.Offset.X = number
+		case "offset.y":
+			e.Light.Offset.Y = number
+		case "shadows":
+			e.Light.Shadows = value == true
+		}
+	}
+}
+
+func (pm *Manager) setSprite(e *entity.Entity, path string) {
+	if path == e.SpritePath && e.Sprite != nil {
+		return
+	}
+	e.SpritePath = path
+	e.Sprite = nil
+	if path == "" || pm.assets == nil {
+		return
+	}
+	sprite, err := pm.assets.LoadSprite(path)
+	if err != nil {
+		logging.Errorf("prefab", "%v", err)
+		return
+	}
+	e.Sprite = sprite
+}
+
+// setShader gives the entity a material drawn with a shader, keeping its uniforms when
+// only the shader changes, or removes the material for an empty path
+func (pm *Manager) setShader(e *entity.Entity, path string) {
+	if path == "" {
+		e.Material = nil
+		return
+	}
+	if e.Material != nil && e.Material.ShaderPath == path && e.Material.Shader != nil {
+		return
+	}
+	if e.Material == nil {
+		e.Material = entity.NewMaterial(nil, path)
+	}
+	e.Material.ShaderPath = path
+	e.Material.Shader = nil
+	if pm.assets == nil {
+		return
+	}
+	shader, err := pm.assets.LoadShader(path)
+	if err != nil {
+		logging.Errorf("prefab", "%v", err)
+		return
+	}
+	e.Material.Shader = shader
+}
+
+// UniformValue converts a number or list of numbers, as decoded from JSON or Lua,
+// to the float64 or []float64 a material uniform holds
+func UniformValue(value interface{}) (interface{}, bool) {
+	if number, ok := toFloat(value); ok {
+		return number, true
+	}
+	var list []interface{}
+	switch v := value.(type) {
+	case []float64:
+		return append([]float64(nil), v...), true
+	case []interface{}:
+		list = v
+	default:
+		return nil, false
+	}
+	numbers := make([]float64, len(list))
+	for i, item := range list {
+		number, ok := toFloat(item)
+		if !ok {
+			return nil, false
+		}
+		numbers[i] = number
+	}
+	return numbers, true
+}
+
+// instanceNodes maps node paths to the entities of a prefab instance.
+// Children that belong to another prefab instance or to no prefab are skipped.
+func instanceNodes(root *entity.Entity) map[string]*entity.Entity {
+	nodes := make(map[string]*entity.Entity)
+	var walk func(e *entity.Entity)
+	walk = func(e *entity.Entity) {
+		nodes[e.Prefab.Path] = e
+		for _, child := range e.Children {
+			if IsInstanceChild(root, child) {
+				walk(child)
+			}
+		}
+	}
+	walk(root)
+	return nodes
+}
+
+// flattenNodes maps node paths to the nodes of a prefab
+func flattenNodes(n *Node, path string) map[string]*Node {
+	nodes := map[string]*Node{path: n}
+	for i, child := range n.Children {
+		for p, c := range flattenNodes(child, childPath(path, i)) {
+			nodes[p] = c
+		}
+	}
+	return nodes
+}
+
+// IsInstanceChild reports whether child was created as part of the prefab instance rooted at root
+func IsInstanceChild(root, child *entity.Entity) bool {
+	return root.IsPrefabRoot() && child.Prefab != nil && child.Prefab.Path != "" && child.Prefab.Name == root.Prefab.Name
+}
+
+func childPath(parent string, index int) string {
+	if parent == "" {
+		return strconv.Itoa(index)
+	}
+	return parent + "/" + strconv.Itoa(index)
+}
+
+func parentPath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return ""
+	}
+	return path[:i]
+}
+
+// sortedPaths returns node paths with parents ordered before their children
+func sortedPaths(nodes map[string]*Node) []string {
+	paths := make([]string, 0, len(nodes))
+	for path := range nodes {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		di, dj := strings.Count(paths[i], "/"), strings.Count(paths[j], "/")
+		if di != dj {
+			return di < dj
+		}
+		return paths[i] < paths[j]
+	})
+	return paths
+}
+
+func sameValue(a, b interface{}) bool {
+	fa, okA := toFloat(a)
+	fb, okB := toFloat(b)
+	if okA && okB {
+		return fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// parseColor reads a hex colour, white when empty. An invalid colour is logged and
+// read as white, so a typo in a prefab does not stop it loading.
+func parseColor(hex string) color.RGBA {
+	if hex == "" {
+		return entity.White
+	}
+	c, err := entity.ParseColor(hex)
+	if err != nil {
+		logging.Errorf("prefab", "%v", err)
+		return entity.White
+	}
+	return c
+}
+
+// formatColor writes a colour as "#rrggbb", or "#rrggbbaa" when it is not opaque
+func formatColor(c color.RGBA) string {
+	if c.A != 255 {
+		return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+	}
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,186 @@
+package prefab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"deepthinking.do/luengo/engine/entity"
+	"deepthinking.do/luengo/engine/logging"
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+// Extension is the file extension of prefab definition files
+const Extension = ".prefab"
+
+// Node describes one entity of a prefab and its children
+type Node struct {
+	Name       string            `json:"name"`
+	Sprite     string            `json:"sprite,omitempty"`
+	Layer      string            `json:"layer,omitempty"`
+	Z          int               `json:"z,omitempty"`
+	Position   entity.Vec2       `json:"position"`
+	Rotation   float64           `json:"rotation,omitempty"`
+	Scale      entity.Vec2       `json:"scale"`
+	Pivot      entity.Vec2       `json:"pivot"`
+	FlipX      bool              `json:"flip_x,omitempty"`
+	FlipY      bool              `json:"flip_y,omitempty"`
+	Tint       string            `json:"tint,omitempty"`  // Hex colour, e.g. "#ff8080"; white when empty
+	Alpha      *float64          `json:"alpha,omitempty"` // Opacity, 1 when missing
+	Blend      string            `json:"blend,omitempty"` // Blend mode name, normal when empty
+	Material   *MaterialNode     `json:"material,omitempty"`
+	Light      *LightNode        `json:"light,omitempty"`
+	Occluder   bool              `json:"occluder,omitempty"`  // The sprite casts shadows from lights
+	Particles  string            `json:"particles,omitempty"` // Particle effect name
+	Components entity.Components `json:"components,omitempty"`
+	Children   []*Node           `json:"children,omitempty"`
+}
+
+// MaterialNode describes the shader an entity's sprite is drawn through
+type MaterialNode struct {
+	Shader   string                 `json:"shader"`
+	Uniforms map[string]interface{} `json:"uniforms,omitempty"` // Numbers or lists of numbers
+}
+
+// LightNode describes the light an entity gives off. Missing values take the
+// defaults of entity.NewLight.
+type LightNode struct {
+	Kind      string      `json:"kind"`                // "point" or "spot"
+	Color     string      `json:"color,omitempty"`     // Hex colour, white when empty
+	Intensity *float64    `json:"intensity,omitempty"` // 1 when missing
+	Radius    float64     `json:"radius,omitempty"`
+	Angle     float64     `json:"angle,omitempty"`  // Direction of a spot light in radians
+	Spread    float64     `json:"spread,omitempty"` // Half the width of a spot light's cone in radians
+	Offset    entity.Vec2 `json:"offset"`
+	Shadows   *bool       `json:"shadows,omitempty"` // true when missing
+}
+
+// Prefab is a reusable entity template loaded from a prefab file
+type Prefab struct {
+	Name string // Prefab name, the file name without extension
+	Path string // File the prefab was loaded from
+	Root *Node
+}
+
+// AssetLoader loads sprites and shaders by asset path
+type AssetLoader interface {
+	LoadSprite(path string) (*ebiten.Image, error)
+	LoadShader(path string) (*ebiten.Shader, error)
+}
+
+type Manager struct {
+	prefabs  map[string]*Prefab
+	entities *entity.Manager
+	assets   AssetLoader
+	files    fs.FS
+}
+
+// NewManager creates a prefab manager that reads prefab files from files
+func NewManager(entities *entity.Manager, assets AssetLoader, files fs.FS) *Manager {
+	return &Manager{
+		prefabs:  make(map[string]*Prefab),
+		entities: entities,
+		assets:   assets,
+		files:    files,
+	}
+}
+
+// LoadFile parses a prefab file without registering it
+func LoadFile(files fs.FS, file string) (*Prefab, error) {
+	data, err := fs.ReadFile(files, vfs.Clean(file))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prefab file %s: %w", file, err)
+	}
+
+	root := &Node{}
+	if err := json.Unmarshal(data, root); err != nil {
+		return nil, fmt.Errorf("failed to parse prefab file %s: %w", file, err)
+	}
+	root.normalize()
+
+	name := strings.TrimSuffix(path.Base(vfs.Clean(file)), Extension)
+	return &Prefab{Name: name, Path: file, Root: root}, nil
+}
+
+// normalize fills in defaults that JSON leaves as zero values
+func (n *Node) normalize() {
+	if n.Scale.X == 0 && n.Scale.Y == 0 {
+		n.Scale = entity.Vec2{X: 1, Y: 1}
+	}
+	for _, child := range n.Children {
+		child.normalize()
+	}
+}
+
+// LoadFromFolder registers every prefab file found in a folder and its subfolders
+func (pm *Manager) LoadFromFolder(folder string) error {
+	return fs.WalkDir(pm.files, vfs.Clean(folder), func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			logging.Warnf("prefab", "Walk error: %v", err)
+			return nil
+		}
+		if !d.IsDir() && path.Ext(file) == Extension {
+			p, err := LoadFile(pm.files, file)
+			if err != nil {
+				logging.Errorf("prefab", "%v", err)
+				return nil
+			}
+			pm.prefabs[p.Name] = p
+			logging.Infof("prefab", "Loaded: %s (%s)", p.Name, file)
+		}
+		return nil
+	})
+}
+
+// Register adds or replaces a prefab definition
+func (pm *Manager) Register(p *Prefab) {
+	pm.prefabs[p.Name] = p
+}
+
+func (pm *Manager) Get(name string) (*Prefab, bool) {
+	p, ok := pm.prefabs[name]
+	return p, ok
+}
+
+// Names returns the registered prefab names in alphabetical order
+func (pm *Manager) Names() []string {
+	names := make([]string, 0, len(pm.prefabs))
+	for name := range pm.prefabs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reload re-reads a prefab from disk and propagates the changes to its live instances
+func (pm *Manager) Reload(name string) error {
+	old, ok := pm.prefabs[name]
+	if !ok {
+		return fmt.Errorf("unknown prefab: %s", name)
+	}
+	updated, err := LoadFile(pm.files, old.Path)
+	if err != nil {
+		return err
+	}
+	pm.prefabs[name] = updated
+	pm.propagate(old, updated)
+	return nil
+}
+
+// ReloadAll reloads every registered prefab. A prefab that fails to reload keeps its
+// old definition and the rest still reload; the errors are returned together.
+func (pm *Manager) ReloadAll() error {
+	var errs []error
+	for _, name := range pm.Names() {
+		if err := pm.Reload(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"deepthinking.do/luengo/engine/entity"
+	"deepthinking.do/luengo/engine/logging"
+	"deepthinking.do/luengo/engine/resources"
+)
+
+// applyReloads points entities at sprites that were reloaded with a new size, at
+// reloaded animations and at recompiled shaders, and rereads changed particle
+// definitions. Replaced images and shaders are disposed once nothing points at them.
+func (g *Game) applyReloads() {
+	for _, reload := range g.resourceManager.TakeReloads() {
+		if reload.Kind == resources.AssetShader {
+			g.applyShaderReload(reload)
+			continue
+		}
+		if reload.Kind == resources.AssetAnimation {
+			g.applyAnimationReload(reload)
+			continue
+		}
+		if reload.Kind == resources.AssetParticles {
+			if err := g.particles.Reload(reload.Path); err != nil {
+				g.ui.AddLogError(err.Error(), g.frame)
+			}
+			continue
+		}
+		if reload.Kind != resources.AssetImage || reload.Sprite == nil {
+			continue
+		}
+		g.particles.ReplaceSprite(reload.Previous, reload.Sprite)
+		updated := 0
+		for _, e := range g.entityManager.GetEntitiesSlice() {
+			if e.Sprite == reload.Previous {
+				e.Sprite = reload.Sprite
+				updated++
+			}
+		}
+		reload.Previous.Dispose()
+		logging.Debugf("engine", "Resized sprite %s on %d entities", reload.Path, updated)
+	}
+}
+
+// applyAnimationReload plays a reloaded animation's frames wherever the old ones were
+// playing, from the same time
+func (g *Game) applyAnimationReload(reload resources.Reload) {
+	if reload.PreviousAnimation == nil {
+		return
+	}
+	// Entities that stopped the animation still show one of its frames
+	previous := make(map[*ebiten.Image]int, len(reload.PreviousAnimation.Frames))
+	for i, frame := range reload.PreviousAnimation.Frames {
+		previous[frame] = i
+	}
+	frames := reload.Animation.Frames
+	updated := 0
+	for _, e := range g.entityManager.GetEntitiesSlice() {
+		if a := e.Animation; a != nil && a.Frames == entity.Frames(reload.PreviousAnimation) {
+			a.Frames = reload.Animation
+			if frame := a.Frames.Frame(a.Elapsed); frame != nil {
+				e.Sprite = frame
+			}
+			updated++
+		} else if i, ok := previous[e.Sprite]; ok {
+			e.Sprite = frames[min(i, len(frames)-1)]
+			updated++
+		}
+	}
+	for _, frame := range reload.PreviousAnimation.Frames {
+		frame.Dispose()
+	}
+	logging.Debugf("engine", "Reloaded animation %s on %d entities", reload.Path, updated)
+}
+
+// applyShaderReload swaps a recompiled shader into the materials and post effects using it
+func (g *Game) applyShaderReload(reload resources.Reload) {
+	updated := 0
+	for _, e := range g.entityManager.GetEntitiesSlice() {
+		// A material whose shader failed to draw has none left, so match it by path too
+		if m := e.Material; m != nil && (m.Shader == reload.PreviousShader || m.Shader == nil && m.ShaderPath == reload.Path) {
+			m.Shader = reload.Shader
+			updated++
+		}
+	}
+	for _, effect := range g.post.Effects() {
+		if effect.Shader == reload.PreviousShader {
+			effect.Shader = reload.Shader
+			updated++
+		}
+	}
+	reload.PreviousShader.Dispose()
+	logging.Debugf("engine", "Reloaded shader %s in %d places", reload.Path, updated)
+}
@@ -0,0 +1,47 @@
+package render
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"deepthinking.do/luengo/engine/entity"
+)
+
+// Blend returns the ebiten blend for a blend mode. Colours are premultiplied by alpha.
+func Blend(mode entity.BlendMode) ebiten.Blend {
+	switch mode {
+	case entity.BlendAdd:
+		return ebiten.BlendLighter
+	case entity.BlendMultiply:
+		// dst * src where the sprite is opaque, dst where it is transparent
+		return ebiten.Blend{
+			BlendFactorSourceRGB:        ebiten.BlendFactorDestinationColor,
+			BlendFactorSourceAlpha:      ebiten.BlendFactorOne,
+			BlendFactorDestinationRGB:   ebiten.BlendFactorOneMinusSourceAlpha,
+			BlendFactorDestinationAlpha: ebiten.BlendFactorOneMinusSourceAlpha,
+			BlendOperationRGB:           ebiten.BlendOperationAdd,
+			BlendOperationAlpha:         ebiten.BlendOperationAdd,
+		}
+	case entity.BlendScreen:
+		// src + dst * (1 - src)
+		return ebiten.Blend{
+			BlendFactorSourceRGB:        ebiten.BlendFactorOne,
+			BlendFactorSourceAlpha:      ebiten.BlendFactorOne,
+			BlendFactorDestinationRGB:   ebiten.BlendFactorOneMinusSourceColor,
+			BlendFactorDestinationAlpha: ebiten.BlendFactorOneMinusSourceAlpha,
+			BlendOperationRGB:           ebiten.BlendOperationAdd,
+			BlendOperationAlpha:         ebiten.BlendOperationAdd,
+		}
+	case entity.BlendSubtract:
+		// dst - src, keeping the destination's alpha
+		return ebiten.Blend{
+			BlendFactorSourceRGB:        ebiten.BlendFactorOne,
+			BlendFactorSourceAlpha:      ebiten.BlendFactorZero,
+			BlendFactorDestinationRGB:   ebiten.BlendFactorOne,
+			BlendFactorDestinationAlpha: ebiten.BlendFactorOne,
+			BlendOperationRGB:           ebiten.BlendOperationReverseSubtract,
+			BlendOperationAlpha:         ebiten.BlendOperationAdd,
+		}
+	default:
+		return ebiten.BlendSourceOver
+	}
+}
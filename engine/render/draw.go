@@ -0,0 +1,229 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+
+	"deepthinking.do/luengo/engine/camera"
+)
+
+// whitePixel is the source of every shape; the inside of a 3x3 image so filtering
+// never samples past its edge
+var whitePixel *ebiten.Image
+
+func shapeSource() *ebiten.Image {
+	if whitePixel == nil {
+		img := ebiten.NewImage(3, 3)
+		img.Fill(color.White)
+		whitePixel = img.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image)
+	}
+	return whitePixel
+}
+
+// Canvas draws lines, shapes, images and text onto an image without creating images.
+// Positions, lengths and stroke widths are in world units when the canvas draws
+// through a camera, and in screen pixels otherwise.
+type Canvas struct {
+	dst       *ebiten.Image
+	view      ebiten.GeoM
+	scale     float64 // How much the view scales lengths
+	AntiAlias bool
+
+	path     vector.Path
+	vertices []ebiten.Vertex
+	indices  []uint16
+}
+
+// NewCanvas creates a canvas that draws onto dst in screen pixels
+func NewCanvas(dst *ebiten.Image) *Canvas {
+	return &Canvas{dst: dst, scale: 1, AntiAlias: true}
+}
+
+// NewWorldCanvas creates a canvas that draws onto dst in world units, as the camera
+// sees them, rotation and zoom included
+func NewWorldCanvas(dst *ebiten.Image, cam *camera.Camera) *Canvas {
+	c := NewCanvas(dst)
+	c.SetView(cam.GetTransformMatrix())
+	return c
+}
+
+// SetView sets the transform from the canvas's coordinates to dst's pixels
+func (c *Canvas) SetView(view ebiten.GeoM) {
+	c.view = view
+	// The view is a rotation and a uniform zoom, so its determinant is the zoom squared
+	c.scale = math.Sqrt(math.Abs(view.Element(0, 0)*view.Element(1, 1) - view.Element(0, 1)*view.Element(1, 0)))
+}
+
+// Target returns the image the canvas draws onto
+func (c *Canvas) Target() *ebiten.Image {
+	return c.dst
+}
+
+// Scale returns how many pixels one unit of the canvas covers, e.g. to stroke
+// 1 / Scale() wide for lines one pixel wide whatever the zoom
+func (c *Canvas) Scale() float64 {
+	return c.scale
+}
+
+func (c *Canvas) moveTo(x, y float64) {
+	x, y = c.view.Apply(x, y)
+	c.path.MoveTo(float32(x), float32(y))
+}
+
+func (c *Canvas) lineTo(x, y float64) {
+	x, y = c.view.Apply(x, y)
+	c.path.LineTo(float32(x), float32(y))
+}
+
+// Line strokes a line between two points
+func (c *Canvas) Line(x0, y0, x1, y1, width float64, clr color.Color) {
+	c.path = vector.Path{}
+	c.moveTo(x0, y0)
+	c.lineTo(x1, y1)
+	c.stroke(width, clr, vector.LineCapButt)
+}
+
+// Polyline strokes connected lines through points given as x, y pairs
+func (c *Canvas) Polyline(points []float64, width float64, clr color.Color) {
+	if !c.addPoints(points, false) {
+		return
+	}
+	c.stroke(width, clr, vector.LineCapRound)
+}
+
+// FillRect fills a rectangle; through a rotated camera it is drawn rotated
+func (c *Canvas) FillRect(x, y, w, h float64, clr color.Color) {
+	c.addPoints([]float64{x, y, x + w, y, x + w, y + h, x, y + h}, true)
+	c.fill(clr, ebiten.FillAll)
+}
+
+// StrokeRect strokes a rectangle's outline, centred on its edges
+func (c *Canvas) StrokeRect(x, y, w, h, width float64, clr color.Color) {
+	c.addPoints([]float64{x, y, x + w, y, x + w, y + h, x, y + h}, true)
+	c.stroke(width, clr, vector.LineCapButt)
+}
+
+// FillCircle fills a circle
+func (c *Canvas) FillCircle(x, y, radius float64, clr color.Color) {
+	c.addCircle(x, y, radius)
+	c.fill(clr, ebiten.FillAll)
+}
+
+// StrokeCircle strokes a circle's outline
+func (c *Canvas) StrokeCircle(x, y, radius, width float64, clr color.Color) {
+	c.addCircle(x, y, radius)
+	c.stroke(width, clr, vector.LineCapButt)
+}
+
+// FillPolygon fills a polygon with corners given as x, y pairs. It may be concave or
+// cross itself; areas it winds around are filled.
+func (c *Canvas) FillPolygon(points []float64, clr color.Color) {
+	if c.addPoints(points, true) {
+		c.fill(clr, ebiten.FillRuleNonZero)
+	}
+}
+
+// StrokePolygon strokes a closed polygon with corners given as x, y pairs
+func (c *Canvas) StrokePolygon(points []float64, width float64, clr color.Color) {
+	if c.addPoints(points, true) {
+		c.stroke(width, clr, vector.LineCapButt)
+	}
+}
+
+// Image draws an image placed by geom, in the canvas's coordinates, and tinted by clr
+func (c *Canvas) Image(img *ebiten.Image, geom ebiten.GeoM, clr color.Color) {
+	opts := &ebiten.DrawImageOptions{GeoM: geom}
+	opts.GeoM.Concat(c.view)
+	opts.ColorScale.ScaleWithColor(clr)
+	c.dst.DrawImage(img, opts)
+}
+
+// Text draws text in the editor's font with its top left at x, y, scale times its
+// size in pixels. Lines are split at newlines.
+func (c *Canvas) Text(str string, x, y, scale float64, clr color.Color) {
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Translate(0, float64(textFace.Metrics().Ascent.Ceil()))
+	opts.GeoM.Scale(scale, scale)
+	opts.GeoM.Translate(x, y)
+	opts.GeoM.Concat(c.view)
+	opts.ColorScale.ScaleWithColor(clr)
+	text.DrawWithOptions(c.dst, str, textFace, opts)
+}
+
+// textFace is the font Text draws with
+var textFace = basicfont.Face7x13
+
+// MeasureText returns the width and height Text covers at scale 1
+func MeasureText(str string) (float64, float64) {
+	lines := strings.Split(str, "\n")
+	width := 0
+	for _, line := range lines {
+		width = max(width, font.MeasureString(textFace, line).Ceil())
+	}
+	return float64(width), float64(len(lines) * textFace.Metrics().Height.Ceil())
+}
+
+// addPoints starts a new path through x, y pairs, reporting whether there were
+// enough for a shape
+func (c *Canvas) addPoints(points []float64, closed bool) bool {
+	c.path = vector.Path{}
+	if len(points) < 4 {
+		return false
+	}
+	c.moveTo(points[0], points[1])
+	for i := 2; i+1 < len(points); i += 2 {
+		c.lineTo(points[i], points[i+1])
+	}
+	if closed {
+		c.path.Close()
+	}
+	return true
+}
+
+func (c *Canvas) addCircle(x, y, radius float64) {
+	c.path = vector.Path{}
+	sx, sy := c.view.Apply(x, y)
+	c.path.Arc(float32(sx), float32(sy), float32(radius*c.scale), 0, 2*math.Pi, vector.Clockwise)
+	c.path.Close()
+}
+
+func (c *Canvas) fill(clr color.Color, rule ebiten.FillRule) {
+	c.vertices, c.indices = c.path.AppendVerticesAndIndicesForFilling(c.vertices[:0], c.indices[:0])
+	c.draw(clr, rule)
+}
+
+func (c *Canvas) stroke(width float64, clr color.Color, cap vector.LineCap) {
+	opts := &vector.StrokeOptions{Width: float32(width * c.scale), LineCap: cap, LineJoin: vector.LineJoinRound}
+	c.vertices, c.indices = c.path.AppendVerticesAndIndicesForStroke(c.vertices[:0], c.indices[:0], opts)
+	c.draw(clr, ebiten.FillAll)
+}
+
+// draw draws the triangles of the current shape in one colour
+func (c *Canvas) draw(clr color.Color, rule ebiten.FillRule) {
+	if len(c.indices) == 0 {
+		return
+	}
+	r, g, b, a := clr.RGBA()
+	for i := range c.vertices {
+		v := &c.vertices[i]
+		v.SrcX, v.SrcY = 1, 1
+		v.ColorR = float32(r) / 0xffff
+		v.ColorG = float32(g) / 0xffff
+		v.ColorB = float32(b) / 0xffff
+		v.ColorA = float32(a) / 0xffff
+	}
+	opts := &ebiten.DrawTrianglesOptions{
+		ColorScaleMode: ebiten.ColorScaleModePremultipliedAlpha,
+		FillRule:       rule,
+		AntiAlias:      c.AntiAlias,
+	}
+	c.dst.DrawTriangles(c.vertices, c.indices, shapeSource(), opts)
+}
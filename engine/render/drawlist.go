@@ -0,0 +1,125 @@
+package render
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+type drawKind int
+
+const (
+	drawLine drawKind = iota
+	drawRect
+	drawCircle
+	drawPolygon
+	drawImage
+	drawText
+)
+
+// drawCommand is one recorded call. Its numbers are values[start:end] of the list:
+// the points of a line or polygon, x, y, w, h of a rect, x, y, radius of a circle and
+// x, y, scale of text.
+type drawCommand struct {
+	kind       drawKind
+	fill       bool
+	start, end int
+	width      float64
+	color      color.NRGBA
+	image      *ebiten.Image
+	geom       ebiten.GeoM
+	text       string
+}
+
+// DrawList records shapes, images and text to draw later onto a canvas, so code that
+// runs outside the frame, such as scripts, never touches the target image. Recording
+// allocates nothing once the list has grown to a frame's worth of commands.
+type DrawList struct {
+	commands []drawCommand
+	values   []float64
+}
+
+// Reset empties the list, keeping its memory
+func (l *DrawList) Reset() {
+	for i := range l.commands {
+		l.commands[i].image = nil // Let dropped sprites be collected
+	}
+	l.commands = l.commands[:0]
+	l.values = l.values[:0]
+}
+
+// Len returns the number of recorded commands
+func (l *DrawList) Len() int {
+	return len(l.commands)
+}
+
+func (l *DrawList) add(cmd drawCommand, values ...float64) {
+	cmd.start = len(l.values)
+	l.values = append(l.values, values...)
+	cmd.end = len(l.values)
+	l.commands = append(l.commands, cmd)
+}
+
+// Polyline records connected lines through points given as x, y pairs
+func (l *DrawList) Polyline(points []float64, width float64, clr color.NRGBA) {
+	l.add(drawCommand{kind: drawLine, width: width, color: clr}, points...)
+}
+
+// Rect records a filled or outlined rectangle
+func (l *DrawList) Rect(fill bool, x, y, w, h, width float64, clr color.NRGBA) {
+	l.add(drawCommand{kind: drawRect, fill: fill, width: width, color: clr}, x, y, w, h)
+}
+
+// Circle records a filled or outlined circle
+func (l *DrawList) Circle(fill bool, x, y, radius, width float64, clr color.NRGBA) {
+	l.add(drawCommand{kind: drawCircle, fill: fill, width: width, color: clr}, x, y, radius)
+}
+
+// Polygon records a filled or outlined polygon with corners given as x, y pairs
+func (l *DrawList) Polygon(fill bool, points []float64, width float64, clr color.NRGBA) {
+	l.add(drawCommand{kind: drawPolygon, fill: fill, width: width, color: clr}, points...)
+}
+
+// Image records an image drawn with geom and tinted by clr
+func (l *DrawList) Image(img *ebiten.Image, geom ebiten.GeoM, clr color.NRGBA) {
+	l.add(drawCommand{kind: drawImage, image: img, geom: geom, color: clr})
+}
+
+// Text records text with its top left at x, y
+func (l *DrawList) Text(str string, x, y, scale float64, clr color.NRGBA) {
+	l.add(drawCommand{kind: drawText, text: str, color: clr}, x, y, scale)
+}
+
+// Draw draws the recorded commands onto a canvas in the order they were recorded
+func (l *DrawList) Draw(c *Canvas) {
+	for i := range l.commands {
+		cmd := &l.commands[i]
+		v := l.values[cmd.start:cmd.end]
+		switch cmd.kind {
+		case drawLine:
+			c.Polyline(v, cmd.width, cmd.color)
+		case drawRect:
+			if cmd.fill {
+				c.FillRect(v[0], v[1], v[2], v[3], cmd.color)
+			} else {
+				c.StrokeRect(v[0], v[1], v[2], v[3], cmd.width, cmd.color)
+			}
+		case drawCircle:
+			if cmd.fill {
+				c.FillCircle(v[0], v[1], v[2], cmd.color)
+			} else {
+				c.StrokeCircle(v[0], v[1], v[2], cmd.width, cmd.color)
+			}
+		case drawPolygon:
+			if cmd.fill {
+				c.FillPolygon(v, cmd.color)
+			} else {
+				c.StrokePolygon(v, cmd.width, cmd.color)
+			}
+		case drawImage:
+			c.Image(cmd.image, cmd.geom, cmd.color)
+		case drawText:
+			c.Text(cmd.text, v[0], v[1], v[2], cmd.color)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+
+	"deepthinking.do/luengo/engine/entity"
+)
+
+// DefaultLayer is the layer of entities that name none
+const DefaultLayer = ""
+
+// Layer is a named group of entities drawn together
+type Layer struct {
+	Name    string
+	Visible bool
+	YSort   bool // Trees lower on the screen draw over higher ones, e.g. for top-down games
+}
+
+// DisplayName returns the layer name, with "default" for the default layer
+func (l *Layer) DisplayName() string {
+	if l.Name == DefaultLayer {
+		return "default"
+	}
+	return l.Name
+}
+
+// Layers is the ordered list of render layers; earlier layers draw first, under later ones
+type Layers struct {
+	layers []*Layer
+}
+
+// NewLayers creates the background, default and foreground layers
+func NewLayers() *Layers {
+	l := &Layers{}
+	for _, name := range []string{"background", DefaultLayer, "foreground"} {
+		l.layers = append(l.layers, &Layer{Name: name, Visible: true})
+	}
+	return l
+}
+
+func (l *Layers) Get(name string) (*Layer, bool) {
+	i := l.index(name)
+	if i < 0 {
+		return nil, false
+	}
+	return l.layers[i], true
+}
+
+// All returns the layers from bottom to top
+func (l *Layers) All() []*Layer {
+	return append([]*Layer(nil), l.layers...)
+}
+
+// Add creates a layer on top of the others
+func (l *Layers) Add(name string) (*Layer, error) {
+	return l.insert(name, len(l.layers))
+}
+
+// AddBelow creates a layer just below another one
+func (l *Layers) AddBelow(name, above string) (*Layer, error) {
+	i := l.index(above)
+	if i < 0 {
+		return nil, fmt.Errorf("layer %q does not exist", above)
+	}
+	return l.insert(name, i)
+}
+
+func (l *Layers) insert(name string, at int) (*Layer, error) {
+	if l.index(name) >= 0 {
+		return nil, fmt.Errorf("layer %q already exists", name)
+	}
+	layer := &Layer{Name: name, Visible: true}
+	l.layers = append(l.layers, nil)
+	copy(l.layers[at+1:], l.layers[at:])
+	l.layers[at] = layer
+	return layer, nil
+}
+
+func (l *Layers) index(name string) int {
+	for i, layer := range l.layers {
+		if layer.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ensure returns the index of a layer, adding an unknown one on top so entities
+// naming it still draw and show up in the editor
+func (l *Layers) ensure(name string) int {
+	if i := l.index(name); i >= 0 {
+		return i
+	}
+	l.insert(name, len(l.layers))
+	return len(l.layers) - 1
+}
+
+// Sort returns the entities on visible layers in draw order: by layer, then Z, then,
+// on y-sorted layers, by the bottom of the tree each belongs to. Ties keep the
+// order given, so pass entities parents first for children to draw over them.
+func (l *Layers) Sort(entities []*entity.Entity) []*entity.Entity {
+	type drawKey struct {
+		layer, z int
+		y        float64
+	}
+	keys := make(map[*entity.Entity]drawKey, len(entities))
+	sorted := make([]*entity.Entity, 0, len(entities))
+	for _, e := range entities {
+		i := l.ensure(e.Layer)
+		layer := l.layers[i]
+		if !layer.Visible {
+			continue
+		}
+		key := drawKey{layer: i, z: e.WorldZ()}
+		if layer.YSort {
+			_, _, _, key.y = e.Root().WorldBounds()
+		}
+		keys[e] = key
+		sorted = append(sorted, e)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := keys[sorted[i]], keys[sorted[j]]
+		if a.layer != b.layer {
+			return a.layer < b.layer
+		}
+		if a.z != b.z {
+			return a.z < b.z
+		}
+		return a.y < b.y
+	})
+	return sorted
+}
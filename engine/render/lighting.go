@@ -0,0 +1,334 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"deepthinking.do/luengo/engine/camera"
+	"deepthinking.do/luengo/engine/entity"
+	"deepthinking.do/luengo/engine/logging"
+)
+
+// lightShader draws one light's falloff, and its cone for spot lights
+const lightShader = `//kage:unit pixels
+
+package main
+
+var Center vec2
+var Radius float
+var Color vec3
+var Direction float
+var Spread float // Half the cone angle; negative for a point light
+
+func Fragment(dstPos vec4, srcPos vec2, color vec4) vec4 {
+	offset := dstPos.xy - Center
+	falloff := clamp(1-length(offset)/Radius, 0, 1)
+	falloff *= falloff
+	if Spread >= 0 {
+		angle := atan2(offset.y, offset.x) - Direction
+		angle = abs(mod(angle+3.14159265, 6.28318531) - 3.14159265)
+		falloff *= 1 - smoothstep(Spread*0.8, Spread, angle)
+	}
+	return vec4(Color*falloff, 1)
+}
+`
+
+// multiplyKeepAlpha darkens what is drawn by the light map without changing its alpha,
+// so camera textures stay transparent where nothing was drawn
+var multiplyKeepAlpha = ebiten.Blend{
+	BlendFactorSourceRGB:        ebiten.BlendFactorDestinationColor,
+	BlendFactorSourceAlpha:      ebiten.BlendFactorZero,
+	BlendFactorDestinationRGB:   ebiten.BlendFactorZero,
+	BlendFactorDestinationAlpha: ebiten.BlendFactorOne,
+	BlendOperationRGB:           ebiten.BlendOperationAdd,
+	BlendOperationAlpha:         ebiten.BlendOperationAdd,
+}
+
+// AmbientKey is the ambient colour at a point in time
+type AmbientKey struct {
+	Time  float64
+	Color color.RGBA
+}
+
+// AmbientCurve blends between ambient colours over time, e.g. through a day. With a
+// period the curve repeats, blending from the last key back to the first.
+type AmbientCurve struct {
+	Keys   []AmbientKey // Sorted by time
+	Period float64      // Length of a cycle, e.g. 1440 minutes; 0 does not repeat
+}
+
+// NewAmbientCurve sorts the keys by time
+func NewAmbientCurve(keys []AmbientKey, period float64) *AmbientCurve {
+	keys = append([]AmbientKey(nil), keys...)
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].Time < keys[j].Time })
+	return &AmbientCurve{Keys: keys, Period: period}
+}
+
+// At returns the ambient colour at a time
+func (c *AmbientCurve) At(t float64) color.RGBA {
+	keys := c.Keys
+	if len(keys) == 0 {
+		return entity.White
+	}
+	if c.Period > 0 {
+		t = math.Mod(t, c.Period)
+		if t < 0 {
+			t += c.Period
+		}
+	}
+	// Find the keys either side of t, wrapping around when the curve repeats
+	next := sort.Search(len(keys), func(i int) bool { return keys[i].Time > t })
+	var from, to AmbientKey
+	switch {
+	case c.Period > 0 && (next == 0 || next == len(keys)):
+		from, to = keys[len(keys)-1], keys[0]
+		if next == 0 {
+			from.Time -= c.Period
+		} else {
+			to.Time += c.Period
+		}
+	case next == 0:
+		return keys[0].Color
+	case next == len(keys):
+		return keys[len(keys)-1].Color
+	default:
+		from, to = keys[next-1], keys[next]
+	}
+	if to.Time <= from.Time {
+		return to.Color
+	}
+	return lerpColor(from.Color, to.Color, (t-from.Time)/(to.Time-from.Time))
+}
+
+func lerpColor(a, b color.RGBA, t float64) color.RGBA {
+	lerp := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + (float64(y)-float64(x))*t))
+	}
+	return color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), 255}
+}
+
+// Lighting darkens each camera's view to an ambient colour and adds the lights of
+// entities on top, with shadows behind occluders
+type Lighting struct {
+	Enabled      bool
+	Ambient      color.RGBA
+	Curve        *AmbientCurve // Replaces Ambient when set, evaluated at Time
+	Time         float64
+	AmbientScale float64 // Multiplies the ambient colour, e.g. to darken a storm
+
+	shader  *ebiten.Shader
+	failed  bool
+	buffers map[image.Point]*lightBuffers // By view size, so cameras of different sizes share them
+	pixel   *ebiten.Image                 // Source for shadow triangles
+}
+
+type lightBuffers struct {
+	lightmap *ebiten.Image // Ambient plus every light, multiplied over the view
+	light    *ebiten.Image // One light before it is added to the light map
+	used     bool          // A camera was lit with them since the last Prune
+}
+
+// NewLighting creates lighting that is off, with a dim blue ambient for when it is on
+func NewLighting() *Lighting {
+	return &Lighting{Ambient: color.RGBA{60, 60, 90, 255}, AmbientScale: 1}
+}
+
+// CurrentAmbient returns the ambient colour now, from the curve if there is one
+func (l *Lighting) CurrentAmbient() color.RGBA {
+	ambient := l.Ambient
+	if l.Curve != nil {
+		ambient = l.Curve.At(l.Time)
+	}
+	scale := math.Max(0, l.AmbientScale)
+	channel := func(c uint8) uint8 { return uint8(math.Min(255, float64(c)*scale)) }
+	return color.RGBA{channel(ambient.R), channel(ambient.G), channel(ambient.B), 255}
+}
+
+// prepare compiles the light shader and returns buffers for a view size, or nil when
+// lighting cannot draw
+func (l *Lighting) prepare(width, height int) *lightBuffers {
+	if l.failed {
+		return nil
+	}
+	if l.shader == nil {
+		shader, err := ebiten.NewShader([]byte(lightShader))
+		if err != nil {
+			logging.Errorf("render", "Lighting disabled: %v", err)
+			l.failed = true
+			return nil
+		}
+		l.shader = shader
+		l.buffers = make(map[image.Point]*lightBuffers)
+		pixel := ebiten.NewImage(3, 3)
+		pixel.Fill(color.White)
+		l.pixel = pixel
+	}
+	size := image.Pt(width, height)
+	buffers, ok := l.buffers[size]
+	if !ok {
+		buffers = &lightBuffers{lightmap: ebiten.NewImage(width, height), light: ebiten.NewImage(width, height)}
+		l.buffers[size] = buffers
+	}
+	buffers.used = true
+	return buffers
+}
+
+// Prune disposes the buffers of view sizes no camera was lit at since the last call,
+// e.g. after the window was resized. Call it once per frame, after every camera.
+func (l *Lighting) Prune() {
+	for size, buffers := range l.buffers {
+		if !buffers.used {
+			buffers.lightmap.Dispose()
+			buffers.light.Dispose()
+			delete(l.buffers, size)
+		}
+		buffers.used = false
+	}
+}
+
+// Apply lights a camera's view in dst, which the camera has just drawn into
+func (l *Lighting) Apply(dst *ebiten.Image, cam *camera.Camera, entities []*entity.Entity) {
+	bounds := dst.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if !l.Enabled || width <= 0 || height <= 0 {
+		return
+	}
+	buffers := l.prepare(width, height)
+	if buffers == nil {
+		return
+	}
+	originX, originY := float64(bounds.Min.X), float64(bounds.Min.Y)
+
+	// toMap converts a world position to light map pixels
+	toMap := func(x, y float64) (float64, float64) {
+		sx, sy := cam.WorldToScreen(x, y)
+		return sx - originX, sy - originY
+	}
+
+	var occluders [][4][2]float64
+	for _, e := range entities {
+		if !e.Occluder || e.Sprite == nil {
+			continue
+		}
+		w, h := e.Size()
+		m := e.WorldMatrix()
+		var quad [4][2]float64
+		for i, corner := range [4][2]float64{{0, 0}, {w, 0}, {w, h}, {0, h}} {
+			quad[i][0], quad[i][1] = toMap(m.Apply(corner[0], corner[1]))
+		}
+		occluders = append(occluders, quad)
+	}
+
+	buffers.lightmap.Fill(l.CurrentAmbient())
+	for _, e := range entities {
+		light := e.Light
+		if light == nil || light.Radius <= 0 || light.Intensity <= 0 {
+			continue
+		}
+		cx, cy := toMap(e.LightPosition())
+		radius := light.Radius * cam.Zoom
+		if cx+radius < 0 || cy+radius < 0 || cx-radius > float64(width) || cy-radius > float64(height) {
+			continue
+		}
+
+		buffers.light.Clear()
+		spread := -1.0
+		direction := 0.0
+		if light.Kind == entity.LightSpot {
+			spread = math.Max(light.Spread, 0.01)
+			// Measure the direction on screen so camera rotation turns it too
+			wx, wy := e.LightPosition()
+			angle := e.LightAngle()
+			dx, dy := toMap(wx+math.Cos(angle), wy+math.Sin(angle))
+			direction = math.Atan2(dy-cy, dx-cx)
+		}
+		scale := light.Intensity / 255
+		opts := &ebiten.DrawRectShaderOptions{}
+		opts.GeoM.Translate(cx-radius, cy-radius)
+		opts.Uniforms = map[string]interface{}{
+			"Center":    []float64{cx, cy},
+			"Radius":    radius,
+			"Color":     []float64{float64(light.Color.R) * scale, float64(light.Color.G) * scale, float64(light.Color.B) * scale},
+			"Direction": direction,
+			"Spread":    spread,
+		}
+		size := int(math.Ceil(radius * 2))
+		buffers.light.DrawRectShader(size, size, l.shader, opts)
+
+		if light.Shadows {
+			l.castShadows(buffers.light, cx, cy, radius, occluders)
+		}
+		buffers.lightmap.DrawImage(buffers.light, &ebiten.DrawImageOptions{Blend: ebiten.BlendLighter})
+	}
+
+	opts := &ebiten.DrawImageOptions{Blend: multiplyKeepAlpha}
+	opts.GeoM.Translate(originX, originY)
+	dst.DrawImage(buffers.lightmap, opts)
+}
+
+// castShadows clears the light image behind each occluder in reach of a light, by
+// stretching every edge of the occluder away from the light past the light's radius
+func (l *Lighting) castShadows(dst *ebiten.Image, cx, cy, radius float64, occluders [][4][2]float64) {
+	var vertices []ebiten.Vertex
+	var indices []uint16
+	far := radius * 4
+	for _, quad := range occluders {
+		if !quadNear(quad, cx, cy, radius) {
+			continue
+		}
+		for i := range quad {
+			a, b := quad[i], quad[(i+1)%4]
+			ax, ay := extrude(a, cx, cy, far)
+			bx, by := extrude(b, cx, cy, far)
+			base := uint16(len(vertices))
+			for _, p := range [4][2]float64{a, b, {bx, by}, {ax, ay}} {
+				vertices = append(vertices, ebiten.Vertex{
+					DstX: float32(p[0]), DstY: float32(p[1]),
+					SrcX: 1, SrcY: 1,
+					ColorR: 1, ColorG: 1, ColorB: 1, ColorA: 1,
+				})
+			}
+			indices = append(indices, base, base+1, base+2, base, base+2, base+3)
+		}
+		// Keep each batch within the 16-bit index range
+		if len(vertices) > 60000 {
+			l.drawShadows(dst, vertices, indices)
+			vertices, indices = vertices[:0], indices[:0]
+		}
+	}
+	l.drawShadows(dst, vertices, indices)
+}
+
+func (l *Lighting) drawShadows(dst *ebiten.Image, vertices []ebiten.Vertex, indices []uint16) {
+	if len(vertices) == 0 {
+		return
+	}
+	dst.DrawTriangles(vertices, indices, l.pixel, &ebiten.DrawTrianglesOptions{Blend: ebiten.BlendClear})
+}
+
+// extrude moves a point away from the light by a distance
+func extrude(p [2]float64, cx, cy, distance float64) (float64, float64) {
+	dx, dy := p[0]-cx, p[1]-cy
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return p[0], p[1]
+	}
+	return p[0] + dx/length*distance, p[1] + dy/length*distance
+}
+
+// quadNear reports whether a quad's bounding box reaches a light's circle
+func quadNear(quad [4][2]float64, cx, cy, radius float64) bool {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range quad {
+		minX, maxX = math.Min(minX, p[0]), math.Max(maxX, p[0])
+		minY, maxY = math.Min(minY, p[1]), math.Max(maxY, p[1])
+	}
+	nearX := math.Max(minX, math.Min(cx, maxX))
+	nearY := math.Max(minY, math.Min(cy, maxY))
+	return math.Hypot(nearX-cx, nearY-cy) <= radius
+}
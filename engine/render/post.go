@@ -0,0 +1,141 @@
+package render
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"deepthinking.do/luengo/engine/logging"
+)
+
+// PostEffect is a full-screen shader pass over the game view. The shader reads the
+// picture so far as its first image and can use the Time and Resolution uniforms.
+type PostEffect struct {
+	Name     string
+	Path     string // Asset path the shader was loaded from
+	Shader   *ebiten.Shader
+	Uniforms map[string]interface{}
+	Enabled  bool
+}
+
+// PostChain runs post-processing effects in order over the game view after the
+// entities are drawn, e.g. colour grading then bloom then a CRT filter
+type PostChain struct {
+	effects        []*PostEffect
+	source, target *ebiten.Image
+}
+
+func NewPostChain() *PostChain {
+	return &PostChain{}
+}
+
+// Add appends an effect to the end of the chain
+func (c *PostChain) Add(name, path string, shader *ebiten.Shader) (*PostEffect, error) {
+	if _, exists := c.Get(name); exists {
+		return nil, fmt.Errorf("post effect %s already exists", name)
+	}
+	effect := &PostEffect{Name: name, Path: path, Shader: shader, Uniforms: make(map[string]interface{}), Enabled: true}
+	c.effects = append(c.effects, effect)
+	return effect, nil
+}
+
+func (c *PostChain) Get(name string) (*PostEffect, bool) {
+	for _, effect := range c.effects {
+		if effect.Name == name {
+			return effect, true
+		}
+	}
+	return nil, false
+}
+
+func (c *PostChain) Remove(name string) bool {
+	for i, effect := range c.effects {
+		if effect.Name == name {
+			c.effects = append(c.effects[:i], c.effects[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Effects returns the effects in the order they run
+func (c *PostChain) Effects() []*PostEffect {
+	return append([]*PostEffect(nil), c.effects...)
+}
+
+func (c *PostChain) active() bool {
+	for _, effect := range c.effects {
+		if effect.Enabled && effect.Shader != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Begin returns a cleared image of the game view's size to draw into instead of the
+// screen, or nil when no effect is enabled
+func (c *PostChain) Begin(width, height int) *ebiten.Image {
+	if !c.active() || width <= 0 || height <= 0 {
+		return nil
+	}
+	if c.source == nil || c.source.Bounds().Dx() != width || c.source.Bounds().Dy() != height {
+		if c.source != nil {
+			c.source.Dispose()
+			c.target.Dispose()
+		}
+		c.source = ebiten.NewImage(width, height)
+		c.target = ebiten.NewImage(width, height)
+	}
+	c.source.Clear()
+	return c.source
+}
+
+// End runs the enabled effects over the image Begin returned and draws the result
+// onto dst at x, y. time is in seconds.
+func (c *PostChain) End(dst *ebiten.Image, x, y, time float64) {
+	if c.source == nil {
+		return
+	}
+	w, h := c.source.Bounds().Dx(), c.source.Bounds().Dy()
+	for _, effect := range c.effects {
+		if !effect.Enabled || effect.Shader == nil {
+			continue
+		}
+		opts := &ebiten.DrawRectShaderOptions{Blend: ebiten.BlendCopy}
+		opts.Images[0] = c.source
+		opts.Uniforms = Uniforms(effect.Uniforms, time)
+		opts.Uniforms["Resolution"] = []float64{float64(w), float64(h)}
+		if err := DrawShader(c.target, w, h, effect.Shader, opts); err != nil {
+			logging.Errorf("render", "Post effect %s disabled: %v", effect.Name, err)
+			effect.Enabled = false
+			continue
+		}
+		c.source, c.target = c.target, c.source
+	}
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Translate(x, y)
+	dst.DrawImage(c.source, opts)
+}
+
+// DrawShader draws a shader like DrawRectShader, but returns an error instead of
+// panicking when a uniform does not match the shader's declaration
+func DrawShader(dst *ebiten.Image, width, height int, shader *ebiten.Shader, opts *ebiten.DrawRectShaderOptions) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	dst.DrawRectShader(width, height, shader, opts)
+	return nil
+}
+
+// Uniforms returns a shader's uniforms with the built-in Time uniform added; shaders
+// that do not declare a uniform ignore it
+func Uniforms(values map[string]interface{}, time float64) map[string]interface{} {
+	uniforms := make(map[string]interface{}, len(values)+1)
+	for name, value := range values {
+		uniforms[name] = value
+	}
+	uniforms["Time"] = time
+	return uniforms
+}
@@ -0,0 +1,108 @@
+package resources
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+// AssetKind classifies asset files by extension
+type AssetKind int
+
+const (
+	AssetOther AssetKind = iota
+	AssetImage
+	AssetSound
+	AssetFont
+	AssetScript
+	AssetAnimation // The frames of an animated GIF; files of this kind are listed as images
+	AssetShader    // Kage shader source
+	AssetParticles // Particle effect definition, owned by the particles package
+)
+
+func (k AssetKind) String() string {
+	switch k {
+	case AssetImage:
+		return "image"
+	case AssetSound:
+		return "sound"
+	case AssetFont:
+		return "font"
+	case AssetScript:
+		return "script"
+	case AssetAnimation:
+		return "animation"
+	case AssetShader:
+		return "shader"
+	case AssetParticles:
+		return "particles"
+	default:
+		return "other"
+	}
+}
+
+// AssetInfo describes a file found under the asset root
+type AssetInfo struct {
+	Path string // Slash-separated path, as passed to the loaders
+	Name string
+	Kind AssetKind
+	Size int64
+}
+
+// KindFromPath returns the asset kind for a file path
+func KindFromPath(path string) AssetKind {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".bmp", ".webp":
+		return AssetImage
+	case ".wav":
+		return AssetSound
+	case ".ttf", ".otf":
+		return AssetFont
+	case ".lua":
+		return AssetScript
+	case ".kage":
+		return AssetShader
+	case ".particles":
+		return AssetParticles
+	default:
+		return AssetOther
+	}
+}
+
+// ListAssets returns every file under root, sorted by path
+func (rm *Manager) ListAssets(root string) ([]AssetInfo, error) {
+	assets := make([]AssetInfo, 0)
+	err := fs.WalkDir(rm.files, vfs.Clean(root), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		assets = append(assets, AssetInfo{
+			Path: path,
+			Name: d.Name(),
+			Kind: KindFromPath(path),
+			Size: info.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list assets in %s: %w", root, err)
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].Path < assets[j].Path })
+	return assets, nil
+}
+
+// IsLoaded reports whether an asset is currently in the sprite cache
+func (rm *Manager) IsLoaded(path string) bool {
+	return rm.cached(assetKey{AssetImage, path})
+}
@@ -0,0 +1,253 @@
+package resources
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"deepthinking.do/luengo/engine/logging"
+)
+
+// DefaultBudget is how much memory loaded assets may use before unused ones are evicted
+const DefaultBudget = 256 << 20
+
+type assetKey struct {
+	kind AssetKind
+	path string
+}
+
+// asset is one cache entry; all fields are guarded by Manager.lock
+type asset struct {
+	key      assetKey
+	value    interface{}
+	size     int64 // Approximate memory use in bytes
+	refs     int
+	lastUsed time.Time
+}
+
+// Handle is a counted reference to a loaded asset. While any handle is held the
+// asset stays in memory; released assets stay cached until the budget needs room.
+type Handle[T any] struct {
+	rm       *Manager
+	asset    *asset
+	released bool
+}
+
+// Get returns the asset, which changes if it is reloaded
+func (h *Handle[T]) Get() T {
+	h.rm.lock.Lock()
+	defer h.rm.lock.Unlock()
+	return h.asset.value.(T)
+}
+
+func (h *Handle[T]) Path() string {
+	return h.asset.key.path
+}
+
+// Release drops the reference; further calls do nothing
+func (h *Handle[T]) Release() {
+	if h == nil || h.released {
+		return
+	}
+	h.released = true
+	h.rm.release(h.asset)
+}
+
+// acquire returns a handle to a cached asset, loading it first if needed. load runs
+// without the lock held and returns the value and its approximate size in bytes.
+func acquire[T any](rm *Manager, kind AssetKind, path string, load func() (T, int64, error)) (*Handle[T], error) {
+	key := assetKey{kind, path}
+	if h, ok := acquireCached[T](rm, key); ok {
+		return h, nil
+	}
+
+	value, size, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	a, exists := rm.assets[key]
+	if !exists {
+		a = rm.insert(key, value, size)
+	}
+	a.refs++
+	a.lastUsed = time.Now()
+	rm.evict()
+	return &Handle[T]{rm: rm, asset: a}, nil
+}
+
+func acquireCached[T any](rm *Manager, key assetKey) (*Handle[T], bool) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	a, exists := rm.assets[key]
+	if !exists {
+		return nil, false
+	}
+	a.refs++
+	a.lastUsed = time.Now()
+	return &Handle[T]{rm: rm, asset: a}, true
+}
+
+// insert adds an unreferenced entry; the caller holds the lock
+func (rm *Manager) insert(key assetKey, value interface{}, size int64) *asset {
+	a := &asset{key: key, value: value, size: size, lastUsed: time.Now()}
+	rm.assets[key] = a
+	rm.used += size
+	logging.Infof("resources", "Loaded %s: %s (%s)", key.kind, key.path, FormatBytes(size))
+	return a
+}
+
+func (rm *Manager) release(a *asset) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	if a.refs > 0 {
+		a.refs--
+	}
+	a.lastUsed = time.Now()
+	rm.evict()
+}
+
+// evict drops the least recently used unreferenced assets until memory use is within
+// the budget; the caller holds the lock
+func (rm *Manager) evict() {
+	if rm.used <= rm.budget {
+		return
+	}
+	unused := make([]*asset, 0)
+	for _, a := range rm.assets {
+		if a.refs == 0 {
+			unused = append(unused, a)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool { return unused[i].lastUsed.Before(unused[j].lastUsed) })
+	for _, a := range unused {
+		if rm.used <= rm.budget {
+			break
+		}
+		rm.remove(a)
+		dispose(a.value)
+		logging.Debugf("resources", "Evicted %s: %s (%s)", a.key.kind, a.key.path, FormatBytes(a.size))
+	}
+}
+
+// dispose frees the GPU memory of an evicted image or animation. Nothing references
+// it any more, so the budget frees video memory as well as the cache's share.
+func dispose(value interface{}) {
+	switch v := value.(type) {
+	case *ebiten.Image:
+		v.Dispose()
+	case *Animation:
+		for _, frame := range v.Frames {
+			frame.Dispose()
+		}
+	}
+}
+
+// remove drops an entry from the cache; the caller holds the lock
+func (rm *Manager) remove(a *asset) {
+	delete(rm.assets, a.key)
+	rm.used -= a.size
+}
+
+// SetBudget sets the memory unused assets may keep, evicting at once if over it
+func (rm *Manager) SetBudget(bytes int64) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	rm.budget = bytes
+	rm.evict()
+}
+
+// Memory returns the memory used by cached assets and the budget, in bytes
+func (rm *Manager) Memory() (used, budget int64) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	return rm.used, rm.budget
+}
+
+// AssetUsage describes one cached asset for the editor's memory view
+type AssetUsage struct {
+	Path     string
+	Kind     AssetKind
+	Size     int64
+	Refs     int
+	LastUsed time.Time
+}
+
+// Usage lists the cached assets, largest first
+func (rm *Manager) Usage() []AssetUsage {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	usage := make([]AssetUsage, 0, len(rm.assets))
+	for _, a := range rm.assets {
+		usage = append(usage, AssetUsage{Path: a.key.path, Kind: a.key.kind, Size: a.size, Refs: a.refs, LastUsed: a.lastUsed})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Size != usage[j].Size {
+			return usage[i].Size > usage[j].Size
+		}
+		return usage[i].Path < usage[j].Path
+	})
+	return usage
+}
+
+// FormatBytes formats a size like "1.5 MB"
+func FormatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// Scope collects handles that are released together, e.g. everything a scene uses
+type Scope struct {
+	releases []func()
+	released bool
+}
+
+// add keeps a handle's release for later, or releases it at once if the scope was
+// already released, e.g. a preload that finished after its group was dropped
+func (s *Scope) add(release func()) {
+	if s.released {
+		release()
+		return
+	}
+	s.releases = append(s.releases, release)
+}
+
+// Merge moves another scope's handles into this one
+func (s *Scope) Merge(other *Scope) {
+	for _, release := range other.releases {
+		s.add(release)
+	}
+	other.releases = nil
+}
+
+// Release releases every handle in the scope
+func (s *Scope) Release() {
+	for _, release := range s.releases {
+		release()
+	}
+	s.releases = nil
+	s.released = true
+}
+
+// SceneScope returns the scope LoadSprite adds its references to
+func (rm *Manager) SceneScope() *Scope {
+	return rm.sceneScope
+}
+
+// NewSceneScope starts a new scene scope and returns the previous one, which the
+// caller releases once the next scene has taken its own references
+func (rm *Manager) NewSceneScope() *Scope {
+	old := rm.sceneScope
+	rm.sceneScope = &Scope{}
+	return old
+}
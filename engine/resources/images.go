@@ -0,0 +1,184 @@
+package resources
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io/fs"
+	"time"
+
+	// Decoders for image.Decode; the format is detected from the file's contents
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+// gifDefaultDelay is used for GIF frames without a delay, as browsers do
+const gifDefaultDelay = 100 * time.Millisecond
+
+// decode reads an image file in any registered format and converts it to
+// premultiplied RGBA, the layout ebiten uploads without another conversion. It is
+// safe to call from any goroutine. Animated GIFs decode to their first frame.
+func (rm *Manager) decode(path string) (image.Image, error) {
+	data, err := fs.ReadFile(rm.files, vfs.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sprite file %s: %w", path, err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sprite file %s: %w", path, err)
+	}
+	if format == "gif" {
+		// A GIF frame is paletted and only covers the area that changed, so it is drawn
+		// onto the GIF's logical screen
+		config, err := gif.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode sprite file %s: %w", path, err)
+		}
+		canvas := image.NewRGBA(image.Rect(0, 0, config.Width, config.Height))
+		draw.Draw(canvas, img.Bounds(), img, img.Bounds().Min, draw.Over)
+		return canvas, nil
+	}
+	return premultiply(img), nil
+}
+
+// premultiply returns img as *image.RGBA. Decoders return straight alpha (NRGBA)
+// or other models; drawing with draw.Src converts them exactly.
+func premultiply(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(b)
+	draw.Draw(rgba, b, img, b.Min, draw.Src)
+	return rgba
+}
+
+// Animation is the frames of an animated GIF, composed to full size
+type Animation struct {
+	Frames []*ebiten.Image
+	Delays []time.Duration // How long each frame is shown
+	Loops  int             // Times to play, 0 forever
+}
+
+// AnimationHandle is a counted reference to a loaded animation
+type AnimationHandle = Handle[*Animation]
+
+// Duration returns the length of one pass through the frames
+func (a *Animation) Duration() time.Duration {
+	var total time.Duration
+	for _, d := range a.Delays {
+		total += d
+	}
+	return total
+}
+
+// Frame returns the frame to show at a time since the animation started
+func (a *Animation) Frame(t time.Duration) *ebiten.Image {
+	if len(a.Frames) == 0 {
+		return nil
+	}
+	total := a.Duration()
+	if total <= 0 {
+		return a.Frames[0]
+	}
+	if a.Loops > 0 && t >= total*time.Duration(a.Loops) {
+		return a.Frames[len(a.Frames)-1]
+	}
+	t %= total
+	for i, d := range a.Delays {
+		if t < d {
+			return a.Frames[i]
+		}
+		t -= d
+	}
+	return a.Frames[len(a.Frames)-1]
+}
+
+// LoadAnimation returns an animated GIF's frames, loading them if they are not
+// cached. The reference is held by the current scene scope.
+func (rm *Manager) LoadAnimation(path string) (*Animation, error) {
+	h, err := rm.AcquireAnimation(path)
+	if err != nil {
+		return nil, err
+	}
+	rm.sceneScope.add(h.Release)
+	return h.Get(), nil
+}
+
+// AcquireAnimation returns a handle to an animated GIF's frames, loading them if needed
+func (rm *Manager) AcquireAnimation(path string) (*AnimationHandle, error) {
+	return acquire(rm, AssetAnimation, path, func() (*Animation, int64, error) {
+		return rm.decodeAnimation(path)
+	})
+}
+
+// decodeAnimation composes every frame of a GIF, applying each frame's disposal
+// method, and returns the animation with its size in bytes
+func (rm *Manager) decodeAnimation(path string) (*Animation, int64, error) {
+	f, err := rm.files.Open(vfs.Clean(path))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open animation file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode animation file %s: %w", path, err)
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewRGBA(bounds)
+	anim := &Animation{Loops: gifLoops(g.LoopCount)}
+	for i, frame := range g.Image {
+		var previous *image.RGBA
+		disposal := byte(gif.DisposalNone)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		anim.Frames = append(anim.Frames, ebiten.NewImageFromImage(canvas))
+		delay := gifDefaultDelay
+		if i < len(g.Delay) && g.Delay[i] > 0 {
+			delay = time.Duration(g.Delay[i]) * 10 * time.Millisecond
+		}
+		anim.Delays = append(anim.Delays, delay)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	if len(anim.Frames) == 0 {
+		return nil, 0, fmt.Errorf("animation file %s has no frames", path)
+	}
+	return anim, int64(len(anim.Frames)) * imageSize(canvas), nil
+}
+
+// gifLoops converts a GIF loop count, where 0 loops forever and -1 plays once, to
+// the number of times to play
+func gifLoops(count int) int {
+	switch {
+	case count == 0:
+		return 0
+	case count < 0:
+		return 1
+	default:
+		return count + 1
+	}
+}
@@ -0,0 +1,306 @@
+package resources
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"deepthinking.do/luengo/engine/logging"
+)
+
+// DefaultWorkers is the number of goroutines decoding asynchronous loads
+const DefaultWorkers = 4
+
+// UploadBudget is how long Update may spend turning decoded images into GPU images
+// each frame; at least one image is uploaded per call
+const UploadBudget = 4 * time.Millisecond
+
+// SpriteFuture is a sprite being loaded in the background. It completes on the
+// game goroutine during Update, so it needs no locking there.
+type SpriteFuture struct {
+	Path   string
+	err    error
+	done   bool
+	scope  *Scope        // Takes a reference to the sprite on completion, if set
+	handle *SpriteHandle // The reference, once complete
+}
+
+// Done reports whether the load finished, successfully or not
+func (f *SpriteFuture) Done() bool {
+	return f.done
+}
+
+// Result returns the sprite, or the error that stopped it loading; both are nil until
+// Done, and the sprite is nil again once the future or its scope is released
+func (f *SpriteFuture) Result() (*ebiten.Image, error) {
+	if f.handle == nil || f.handle.released {
+		return nil, f.err
+	}
+	return f.handle.Get(), f.err
+}
+
+// Release drops the future's reference to its sprite, letting it be evicted. Futures
+// started by Preload are released with their group.
+func (f *SpriteFuture) Release() {
+	if f.scope == nil {
+		f.handle.Release()
+	}
+}
+
+// complete finishes the future from the cache, or with err
+func (f *SpriteFuture) complete(rm *Manager, err error) {
+	if err != nil {
+		f.done = true
+		f.err = err
+		return
+	}
+	h, ok := acquireCached[*ebiten.Image](rm, assetKey{AssetImage, f.Path})
+	if !ok {
+		f.done = true
+		f.err = fmt.Errorf("sprite %s was evicted before it could be used", f.Path)
+		return
+	}
+	f.finish(h)
+}
+
+// finish completes the future with a reference to its sprite, which its scope keeps
+// if it has one
+func (f *SpriteFuture) finish(h *SpriteHandle) {
+	f.done = true
+	f.handle = h
+	if f.scope != nil {
+		f.scope.add(h.Release)
+	}
+}
+
+// Group tracks a set of sprites preloaded together, e.g. the sprites of a level.
+// It holds a reference to each sprite until released.
+type Group struct {
+	Name    string
+	futures []*SpriteFuture
+	byPath  map[string]*SpriteFuture
+	scope   Scope
+}
+
+// Sprite returns one of the group's sprites once it has loaded, as it is now if the
+// file was reloaded since, and until the group is released
+func (g *Group) Sprite(path string) (*ebiten.Image, bool) {
+	f, ok := g.byPath[path]
+	if !ok {
+		return nil, false
+	}
+	sprite, _ := f.Result()
+	return sprite, sprite != nil
+}
+
+// Release lets the group's sprites be evicted once nothing else uses them
+func (g *Group) Release() {
+	g.scope.Release()
+}
+
+// Progress returns how many of the group's sprites finished loading
+func (g *Group) Progress() (loaded, total int) {
+	for _, f := range g.futures {
+		if f.done {
+			loaded++
+		}
+	}
+	return loaded, len(g.futures)
+}
+
+// Fraction returns the progress between 0 and 1; an empty group is complete
+func (g *Group) Fraction() float64 {
+	loaded, total := g.Progress()
+	if total == 0 {
+		return 1
+	}
+	return float64(loaded) / float64(total)
+}
+
+func (g *Group) Done() bool {
+	loaded, total := g.Progress()
+	return loaded == total
+}
+
+// Errors returns the loads that failed
+func (g *Group) Errors() []error {
+	var errs []error
+	for _, f := range g.futures {
+		if f.err != nil {
+			errs = append(errs, f.err)
+		}
+	}
+	return errs
+}
+
+type decoded struct {
+	path  string
+	image image.Image
+	err   error
+}
+
+// loader decodes images on worker goroutines and hands them back to the game
+// goroutine, which owns the GPU upload and the futures
+type loader struct {
+	rm      *Manager
+	jobs    chan string
+	results chan decoded
+	quit    chan struct{}
+
+	// Only touched on the game goroutine
+	pending map[string][]*SpriteFuture
+	groups  map[string]*Group
+}
+
+func newLoader(rm *Manager, workers int) *loader {
+	l := &loader{
+		rm:      rm,
+		jobs:    make(chan string, 256),
+		results: make(chan decoded, 64),
+		quit:    make(chan struct{}),
+		pending: make(map[string][]*SpriteFuture),
+		groups:  make(map[string]*Group),
+	}
+	for i := 0; i < workers; i++ {
+		go l.work()
+	}
+	return l
+}
+
+func (l *loader) work() {
+	for {
+		select {
+		case path := <-l.jobs:
+			img, err := l.rm.decode(path)
+			select {
+			case l.results <- decoded{path: path, image: img, err: err}:
+			case <-l.quit:
+				return
+			}
+		case <-l.quit:
+			return
+		}
+	}
+}
+
+func (l *loader) close() {
+	close(l.quit)
+}
+
+// LoadSpriteAsync starts loading a sprite in the background. Cached sprites
+// complete immediately; a path already loading shares the same decode. The future
+// holds a reference to the sprite until it is released.
+func (rm *Manager) LoadSpriteAsync(path string) *SpriteFuture {
+	return rm.loadAsync(path, nil)
+}
+
+func (rm *Manager) loadAsync(path string, scope *Scope) *SpriteFuture {
+	f := &SpriteFuture{Path: path, scope: scope}
+	if rm.cached(assetKey{AssetImage, path}) {
+		f.complete(rm, nil)
+		return f
+	}
+	if KindFromPath(path) != AssetImage {
+		f.complete(rm, fmt.Errorf("cannot preload %s: only images are supported", path))
+		return f
+	}
+
+	l := rm.loader
+	waiting, loading := l.pending[path]
+	l.pending[path] = append(waiting, f)
+	if !loading {
+		select {
+		case l.jobs <- path:
+		default:
+			// The queue is full; wait for a worker without blocking the game
+			go func() {
+				select {
+				case l.jobs <- path:
+				case <-l.quit:
+				}
+			}()
+		}
+	}
+	return f
+}
+
+// Preload starts loading a group of sprites and remembers it by name, releasing
+// any earlier group with the same name
+func (rm *Manager) Preload(name string, paths []string) *Group {
+	if old, ok := rm.loader.groups[name]; ok {
+		old.Release()
+	}
+	g := &Group{Name: name, byPath: make(map[string]*SpriteFuture)}
+	for _, path := range paths {
+		if _, seen := g.byPath[path]; path == "" || seen {
+			continue
+		}
+		f := rm.loadAsync(path, &g.scope)
+		g.futures = append(g.futures, f)
+		g.byPath[path] = f
+	}
+	rm.loader.groups[name] = g
+	logging.Debugf("resources", "Preloading %s: %d sprites", name, len(g.futures))
+	return g
+}
+
+// Group returns a preload group by name
+func (rm *Manager) Group(name string) (*Group, bool) {
+	g, ok := rm.loader.groups[name]
+	return g, ok
+}
+
+// ReleaseGroup releases a preload group's sprites and forgets the group
+func (rm *Manager) ReleaseGroup(name string) {
+	if g, ok := rm.loader.groups[name]; ok {
+		g.Release()
+		delete(rm.loader.groups, name)
+	}
+}
+
+// Groups returns the names of the preload groups
+func (rm *Manager) Groups() []string {
+	names := make([]string, 0, len(rm.loader.groups))
+	for name := range rm.loader.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Update reloads changed assets, uploads decoded images and completes their futures.
+// Call it once per frame from the game goroutine; uploads stop after UploadBudget.
+func (rm *Manager) Update() {
+	rm.reloadChanged()
+
+	l := rm.loader
+	start := time.Now()
+	for {
+		select {
+		case result := <-l.results:
+			futures := l.pending[result.path]
+			delete(l.pending, result.path)
+			if result.err != nil {
+				logging.Errorf("resources", "%v", result.err)
+				for _, f := range futures {
+					f.complete(rm, result.err)
+				}
+			} else {
+				rm.upload(result.path, result.image, futures)
+			}
+			if time.Since(start) >= UploadBudget {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Loading returns how many sprites are still being loaded in the background
+func (rm *Manager) Loading() int {
+	return len(rm.loader.pending)
+}
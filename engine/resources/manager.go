@@ -1,65 +1,161 @@
 package resources
 
 import (
-	"fmt"
-	"image/png"
-	"os"
+	"image"
+	"io/fs"
+	"sync"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
+// SpriteHandle is a counted reference to a loaded sprite
+type SpriteHandle = Handle[*ebiten.Image]
+
 type Manager struct {
-	sprites map[string]*ebiten.Image
+	files fs.FS
+
+	// The cache is shared with loader goroutines and the audio goroutine
+	lock   sync.Mutex
+	assets map[assetKey]*asset
+	used   int64
+	budget int64
+
+	sceneScope *Scope // References taken by LoadSprite, released when the scene changes
+	loader     *loader
+	watcher    *watcher // Nil unless Watch was called
+	reloads    []Reload
 }
 
-func NewManager() *Manager {
-	return &Manager{
-		sprites: make(map[string]*ebiten.Image),
+// NewManager creates a resource manager that reads assets from files and decodes
+// asynchronous loads on a pool of background goroutines
+func NewManager(files fs.FS) *Manager {
+	rm := &Manager{
+		files:      files,
+		assets:     make(map[assetKey]*asset),
+		budget:     DefaultBudget,
+		sceneScope: &Scope{},
 	}
+	rm.loader = newLoader(rm, DefaultWorkers)
+	return rm
 }
 
-func (rm *Manager) LoadSprite(path string) (*ebiten.Image, error) {
-	// Check if already loaded
-	if sprite, exists := rm.sprites[path]; exists {
-		return sprite, nil
+// Close stops the loader and watcher goroutines
+func (rm *Manager) Close() {
+	rm.loader.close()
+	if rm.watcher != nil {
+		rm.watcher.close()
 	}
+}
 
-	// Load the sprite
-	f, err := os.Open(path)
+// LoadSprite returns a sprite, loading it on the calling goroutine if it is not
+// cached. The reference is held by the current scene scope.
+func (rm *Manager) LoadSprite(path string) (*ebiten.Image, error) {
+	h, err := rm.AcquireSprite(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open sprite file %s: %w", path, err)
+		return nil, err
 	}
-	defer f.Close()
+	rm.sceneScope.add(h.Release)
+	return h.Get(), nil
+}
 
-	img, err := png.Decode(f)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode sprite file %s: %w", path, err)
+// AcquireSprite returns a handle to a sprite, loading it on the calling goroutine if needed
+func (rm *Manager) AcquireSprite(path string) (*SpriteHandle, error) {
+	return acquire(rm, AssetImage, path, func() (*ebiten.Image, int64, error) {
+		img, err := rm.decode(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		return ebiten.NewImageFromImage(img), imageSize(img), nil
+	})
+}
+
+// upload turns a decoded image into a GPU image, keeping an existing sprite if
+// another load got there first, and completes the futures waiting for it. Their
+// references are taken before anything is evicted, so when memory is tight the
+// sprite just decoded is not the one dropped.
+func (rm *Manager) upload(path string, img image.Image, futures []*SpriteFuture) {
+	key := assetKey{AssetImage, path}
+	rm.lock.Lock()
+	a, exists := rm.assets[key]
+	if !exists {
+		a = rm.insert(key, ebiten.NewImageFromImage(img), imageSize(img))
 	}
+	a.refs += len(futures)
+	a.lastUsed = time.Now()
+	rm.evict()
+	rm.lock.Unlock()
 
-	sprite := ebiten.NewImageFromImage(img)
-	rm.sprites[path] = sprite
+	for _, f := range futures {
+		f.finish(&SpriteHandle{rm: rm, asset: a})
+	}
+}
 
-	fmt.Printf("[Resources] Loaded sprite: %s\n", path)
-	return sprite, nil
+// AddSprite caches an image made at runtime under a path that is not a file, e.g. a
+// camera's texture, so entities and prefabs can use it as a sprite. An earlier image
+// with the same path is replaced.
+func (rm *Manager) AddSprite(path string, img *ebiten.Image) *SpriteHandle {
+	key := assetKey{AssetImage, path}
+	size := imageSize(img)
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	a, exists := rm.assets[key]
+	if exists {
+		rm.used += size - a.size
+		a.value, a.size = img, size
+	} else {
+		a = rm.insert(key, img, size)
+	}
+	a.refs++
+	a.lastUsed = time.Now()
+	rm.evict()
+	return &SpriteHandle{rm: rm, asset: a}
 }
 
-func (rm *Manager) GetSprite(path string) (*ebiten.Image, bool) {
-	sprite, exists := rm.sprites[path]
-	return sprite, exists
+// imageSize estimates the GPU memory of an image as 4 bytes per pixel
+func imageSize(img image.Image) int64 {
+	b := img.Bounds()
+	return int64(b.Dx()) * int64(b.Dy()) * 4
 }
 
+// GetSprite returns a handle to a cached sprite without loading it
+func (rm *Manager) GetSprite(path string) (*SpriteHandle, bool) {
+	return acquireCached[*ebiten.Image](rm, assetKey{AssetImage, path})
+}
+
+// cached reports whether an asset is in the cache
+func (rm *Manager) cached(key assetKey) bool {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	_, exists := rm.assets[key]
+	return exists
+}
+
+// UnloadSprite drops a sprite from the cache; entities using it keep their image
 func (rm *Manager) UnloadSprite(path string) {
-	delete(rm.sprites, path)
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	if a, exists := rm.assets[assetKey{AssetImage, path}]; exists {
+		rm.remove(a)
+	}
 }
 
+// UnloadAll empties the cache; outstanding handles keep their values
 func (rm *Manager) UnloadAll() {
-	rm.sprites = make(map[string]*ebiten.Image)
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	rm.assets = make(map[assetKey]*asset)
+	rm.used = 0
 }
 
 func (rm *Manager) GetLoadedSprites() []string {
-	paths := make([]string, 0, len(rm.sprites))
-	for path := range rm.sprites {
-		paths = append(paths, path)
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	paths := make([]string, 0, len(rm.assets))
+	for key := range rm.assets {
+		if key.kind == AssetImage {
+			paths = append(paths, key.path)
+		}
 	}
 	return paths
 }
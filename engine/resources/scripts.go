@@ -0,0 +1,36 @@
+package resources
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+// ScriptHandle is a counted reference to a compiled Lua chunk; run it with
+// LState.NewFunctionFromProto
+type ScriptHandle = Handle[*lua.FunctionProto]
+
+// AcquireScript returns a handle to a compiled script, compiling it if it is not cached
+func (rm *Manager) AcquireScript(path string) (*ScriptHandle, error) {
+	return acquire(rm, AssetScript, path, func() (*lua.FunctionProto, int64, error) {
+		source, err := fs.ReadFile(rm.files, vfs.Clean(path))
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read script %s: %w", path, err)
+		}
+		chunk, err := parse.Parse(bytes.NewReader(source), path)
+		if err != nil {
+			return nil, 0, err
+		}
+		proto, err := lua.Compile(chunk, path)
+		if err != nil {
+			return nil, 0, err
+		}
+		// The compiled form is roughly a few times the source size
+		return proto, int64(len(source)) * 4, nil
+	})
+}
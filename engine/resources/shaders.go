@@ -0,0 +1,67 @@
+package resources
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"deepthinking.do/luengo/engine/logging"
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+// ShaderHandle is a counted reference to a compiled Kage shader
+type ShaderHandle = Handle[*ebiten.Shader]
+
+// LoadShader returns a compiled shader that stays loaded until the scene changes
+func (rm *Manager) LoadShader(path string) (*ebiten.Shader, error) {
+	h, err := rm.AcquireShader(path)
+	if err != nil {
+		return nil, err
+	}
+	rm.sceneScope.add(h.Release)
+	return h.Get(), nil
+}
+
+// AcquireShader returns a handle to a shader, compiling it if it is not cached
+func (rm *Manager) AcquireShader(path string) (*ShaderHandle, error) {
+	return acquire(rm, AssetShader, path, func() (*ebiten.Shader, int64, error) {
+		return rm.compileShader(path)
+	})
+}
+
+func (rm *Manager) compileShader(path string) (*ebiten.Shader, int64, error) {
+	source, err := fs.ReadFile(rm.files, vfs.Clean(path))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read shader %s: %w", path, err)
+	}
+	shader, err := ebiten.NewShader(source)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to compile shader %s: %w", path, err)
+	}
+	// The compiled programs are small; count the source so the memory view lists them
+	return shader, int64(len(source)), nil
+}
+
+// reloadShader recompiles a cached shader. Shaders cannot be changed in place, so the
+// reload records the old and new shader for the engine to swap; a shader that fails
+// to compile keeps the old one running.
+func (rm *Manager) reloadShader(key assetKey) {
+	shader, size, err := rm.compileShader(key.path)
+	if err != nil {
+		logging.Warnf("resources", "Could not reload %v", err)
+		return
+	}
+
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	a, exists := rm.assets[key]
+	if !exists {
+		shader.Dispose()
+		return
+	}
+	previous := a.value.(*ebiten.Shader)
+	rm.resize(a, shader, size)
+	rm.reloads = append(rm.reloads, Reload{Path: key.path, Kind: AssetShader, PreviousShader: previous, Shader: shader})
+	logging.Infof("resources", "Reloaded %s: %s", AssetShader, key.path)
+}
@@ -0,0 +1,46 @@
+package resources
+
+import (
+	"fmt"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+// Sound is a decoded WAV file kept in memory so it can be played many times
+type Sound struct {
+	Buffer *beep.Buffer
+	Format beep.Format
+}
+
+// SoundHandle is a counted reference to a loaded sound
+type SoundHandle = Handle[*Sound]
+
+// AcquireSound returns a handle to a sound, decoding it if it is not cached
+func (rm *Manager) AcquireSound(path string) (*SoundHandle, error) {
+	return acquire(rm, AssetSound, path, func() (*Sound, int64, error) {
+		return rm.decodeSound(path)
+	})
+}
+
+// decodeSound reads a WAV file into memory and returns it with its size in bytes
+func (rm *Manager) decodeSound(path string) (*Sound, int64, error) {
+	f, err := rm.files.Open(vfs.Clean(path))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open audio file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	streamer, format, err := wav.Decode(f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode audio file %s: %w", path, err)
+	}
+	defer streamer.Close()
+
+	buffer := beep.NewBuffer(format)
+	buffer.Append(streamer)
+	// Samples are buffered as two float64 channels
+	return &Sound{Buffer: buffer, Format: format}, int64(buffer.Len()) * 16, nil
+}
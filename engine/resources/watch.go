@@ -0,0 +1,253 @@
+package resources
+
+import (
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"deepthinking.do/luengo/engine/logging"
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+// WatchInterval is how often Watch checks the asset root for changed files
+const WatchInterval = 500 * time.Millisecond
+
+// Reload is a cached asset that changed on disk and was reloaded
+type Reload struct {
+	Path string
+	Kind AssetKind
+
+	// A sprite that changed size cannot be updated in place, so it is replaced:
+	// Previous is the image entities still draw and Sprite the one to draw instead
+	Previous *ebiten.Image
+	Sprite   *ebiten.Image
+
+	// A reloaded shader is always a new one, to use wherever PreviousShader is
+	PreviousShader *ebiten.Shader
+	Shader         *ebiten.Shader
+
+	// A reloaded animation is a new one, to play wherever PreviousAnimation is
+	PreviousAnimation *Animation
+	Animation         *Animation
+}
+
+// watcher polls modification times on its own goroutine and collects the paths
+// that changed until the game goroutine takes them
+type watcher struct {
+	root    string
+	quit    chan struct{}
+	lock    sync.Mutex
+	changed map[string]bool
+}
+
+// Watch starts polling the images, sounds, shaders and particle definitions under root;
+// Update reloads the cached ones that change. Stopped by Close.
+func (rm *Manager) Watch(root string) {
+	if rm.watcher != nil {
+		return
+	}
+	w := &watcher{root: vfs.Clean(root), quit: make(chan struct{}), changed: make(map[string]bool)}
+	rm.watcher = w
+	go w.run(rm.files)
+	logging.Infof("resources", "Watching %s for changes", root)
+}
+
+func (w *watcher) run(files fs.FS) {
+	known := w.scan(files)
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			current := w.scan(files)
+			w.lock.Lock()
+			for path, modTime := range current {
+				if previous, ok := known[path]; ok && !previous.Equal(modTime) {
+					w.changed[path] = true
+				}
+			}
+			w.lock.Unlock()
+			known = current
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// scan returns the modification time of every file Watch polls under the root
+func (w *watcher) scan(files fs.FS) map[string]time.Time {
+	times := make(map[string]time.Time)
+	fs.WalkDir(files, w.root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		switch KindFromPath(name) {
+		case AssetImage, AssetSound, AssetShader, AssetParticles:
+		default:
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			times[name] = info.ModTime()
+		}
+		return nil
+	})
+	return times
+}
+
+// take returns the paths that changed since the last call, sorted
+func (w *watcher) take() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	paths := make([]string, 0, len(w.changed))
+	for path := range w.changed {
+		paths = append(paths, path)
+	}
+	w.changed = make(map[string]bool)
+	sort.Strings(paths)
+	return paths
+}
+
+func (w *watcher) close() {
+	close(w.quit)
+}
+
+// reloadChanged reloads the cached assets whose files changed; it runs on the game
+// goroutine because sprites and shaders live on the GPU
+func (rm *Manager) reloadChanged() {
+	if rm.watcher == nil {
+		return
+	}
+	for _, path := range rm.watcher.take() {
+		// Particle definitions are not cached here, so their owner is told of every change
+		if KindFromPath(path) == AssetParticles {
+			rm.lock.Lock()
+			rm.reloads = append(rm.reloads, Reload{Path: path, Kind: AssetParticles})
+			rm.lock.Unlock()
+			continue
+		}
+		for _, key := range rm.cachedKeys(path) {
+			switch key.kind {
+			case AssetImage:
+				rm.reloadSprite(key)
+			case AssetSound:
+				rm.reloadSound(key)
+			case AssetAnimation:
+				rm.reloadAnimation(key)
+			case AssetShader:
+				rm.reloadShader(key)
+			case AssetScript:
+				rm.forget(key)
+			}
+		}
+	}
+}
+
+// cachedKeys returns the cache entries loaded from a file, however their paths were written
+func (rm *Manager) cachedKeys(file string) []assetKey {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	var keys []assetKey
+	for key := range rm.assets {
+		if vfs.Clean(key.path) == file {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// reloadSprite redraws a cached sprite with the file's new pixels, so every entity
+// and handle using the image sees them. A sprite whose size changed gets a new image.
+func (rm *Manager) reloadSprite(key assetKey) {
+	path := key.path
+	img, err := rm.decode(path)
+	if err != nil {
+		// Editors often write files in several steps; the next change retries
+		logging.Warnf("resources", "Could not reload %s: %v", path, err)
+		return
+	}
+
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	a, exists := rm.assets[key]
+	if !exists {
+		return
+	}
+	sprite := a.value.(*ebiten.Image)
+	reload := Reload{Path: path, Kind: AssetImage}
+	if sprite.Bounds().Size() == img.Bounds().Size() {
+		updated := ebiten.NewImageFromImage(img)
+		sprite.Clear()
+		sprite.DrawImage(updated, nil)
+		updated.Dispose()
+	} else {
+		reload.Previous, reload.Sprite = sprite, ebiten.NewImageFromImage(img)
+		rm.resize(a, reload.Sprite, imageSize(img))
+	}
+	rm.reloads = append(rm.reloads, reload)
+	logging.Infof("resources", "Reloaded %s: %s", AssetImage, path)
+}
+
+// reloadSound replaces a cached sound; sounds already playing finish with the old samples
+func (rm *Manager) reloadSound(key assetKey) {
+	path := key.path
+	sound, size, err := rm.decodeSound(path)
+	if err != nil {
+		logging.Warnf("resources", "Could not reload %s: %v", path, err)
+		return
+	}
+
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	if a, exists := rm.assets[key]; exists {
+		rm.resize(a, sound, size)
+		rm.reloads = append(rm.reloads, Reload{Path: path, Kind: AssetSound})
+		logging.Infof("resources", "Reloaded %s: %s", AssetSound, path)
+	}
+}
+
+// reloadAnimation replaces a cached animation; handles return the new frames
+func (rm *Manager) reloadAnimation(key assetKey) {
+	anim, size, err := rm.decodeAnimation(key.path)
+	if err != nil {
+		logging.Warnf("resources", "Could not reload %s: %v", key.path, err)
+		return
+	}
+
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	if a, exists := rm.assets[key]; exists {
+		previous, _ := a.value.(*Animation)
+		rm.resize(a, anim, size)
+		rm.reloads = append(rm.reloads, Reload{Path: key.path, Kind: AssetAnimation, PreviousAnimation: previous, Animation: anim})
+		logging.Infof("resources", "Reloaded %s: %s", AssetAnimation, key.path)
+	}
+}
+
+// forget drops a changed script's compiled form, so the next load compiles the new
+// source; scripts already running keep the old code until the mod is reloaded
+func (rm *Manager) forget(key assetKey) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	if a, exists := rm.assets[key]; exists {
+		rm.remove(a)
+	}
+}
+
+// resize swaps an entry's value for a reloaded one; the caller holds the lock
+func (rm *Manager) resize(a *asset, value interface{}, size int64) {
+	rm.used += size - a.size
+	a.value, a.size = value, size
+	rm.evict()
+}
+
+// TakeReloads returns the assets reloaded since the last call
+func (rm *Manager) TakeReloads() []Reload {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	reloads := rm.reloads
+	rm.reloads = nil
+	return reloads
+}
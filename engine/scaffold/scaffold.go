@@ -0,0 +1,156 @@
+package scaffold
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Templates lists the starters luengo new can create
+var Templates = []string{"topdown", "platformer"}
+
+// DefaultTemplate is used when no template is chosen
+const DefaultTemplate = "topdown"
+
+// nameToken is replaced by the project name in template paths
+const nameToken = "__name__"
+
+//go:embed all:templates
+var templates embed.FS
+
+var namePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// Project is what a template is rendered with
+type Project struct {
+	Name     string // Folder and mod id
+	Title    string // Window title
+	Template string
+}
+
+// Create writes a new project into dir/name from the common files and a starter
+// template, returning the files it wrote. It refuses to touch an existing folder.
+func Create(dir, name, templateName string) ([]string, error) {
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid project name %q: use letters, digits, - and _, starting with a letter", name)
+	}
+	if !isTemplate(templateName) {
+		return nil, fmt.Errorf("unknown template %q (available: %s)", templateName, strings.Join(Templates, ", "))
+	}
+	root := filepath.Join(dir, name)
+	if _, err := os.Stat(root); err == nil {
+		return nil, fmt.Errorf("%s already exists", root)
+	}
+
+	project := Project{Name: name, Title: title(name), Template: templateName}
+	var files []string
+	for _, base := range []string{"common", templateName} {
+		written, err := render(root, path.Join("templates", base), project)
+		files = append(files, written...)
+		if err != nil {
+			return files, err
+		}
+	}
+
+	// Placeholder sprites, so the sample scene shows something before real art exists
+	sprites := map[string]color.RGBA{
+		"player.png": {80, 200, 120, 255},
+		"tile.png":   {120, 120, 130, 255},
+	}
+	for _, name := range []string{"player.png", "tile.png"} {
+		file := filepath.Join(root, "assets", "sprites", name)
+		if err := writeSprite(file, sprites[name]); err != nil {
+			return files, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func isTemplate(name string) bool {
+	for _, t := range Templates {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// title turns a project name like "space-cats" into "Space Cats"
+func title(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' })
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// render executes every file under a template folder into root
+func render(root, base string, project Project) ([]string, error) {
+	var files []string
+	err := fs.WalkDir(templates, base, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel := strings.ReplaceAll(strings.TrimPrefix(p, base+"/"), nameToken, project.Name)
+		target := filepath.Join(root, filepath.FromSlash(rel))
+
+		data, err := templates.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(p).Parse(string(data))
+		if err != nil {
+			return fmt.Errorf("failed to parse template %s: %w", p, err)
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, project); err != nil {
+			return fmt.Errorf("failed to render template %s: %w", p, err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, out.Bytes(), 0o644); err != nil {
+			return err
+		}
+		files = append(files, target)
+		return nil
+	})
+	return files, err
+}
+
+// writeSprite writes a 32x32 square with a darker border
+func writeSprite(file string, fill color.RGBA) error {
+	const size = 32
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	border := color.RGBA{fill.R / 2, fill.G / 2, fill.B / 2, 255}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := fill
+			if x < 2 || y < 2 || x >= size-2 || y >= size-2 {
+				c = border
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
@@ -0,0 +1,27 @@
+-- Tests for {{.Name}}, run headless with `luengo test`
+
+local game = get_mod("{{.Name}}")
+
+describe("{{.Name}} physics", function()
+  it("lands on the ground", function()
+    advance(1)
+    local _, y = get_player_position()
+    expect(y):to_be_close_to(game.player.ground_y, 0.01)
+    expect(game.player.on_ground):to_be_truthy()
+  end)
+
+  it("jumps while Space is held", function()
+    advance(1)
+    press("Space")
+    advance(0.1)
+    local _, y = get_player_position()
+    expect(y):to_be_less_than(game.player.ground_y)
+  end)
+
+  it("runs right", function()
+    local start_x = get_player_position()
+    press("D")
+    advance(0.5)
+    expect(get_player_position()):to_be_greater_than(start_x)
+  end)
+end)
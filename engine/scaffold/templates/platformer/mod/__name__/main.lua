@@ -0,0 +1,44 @@
+-- {{.Title}}: platformer starter
+-- Runs left and right, jumps, and falls back onto the ground.
+
+player = {
+  run_speed = 220, -- Pixels per second
+  jump_speed = 650,
+  gravity = 1800, -- Pixels per second squared
+  ground_y = 568, -- Player y when standing on the ground in mod/scenes/main.scene
+  velocity_y = 0,
+  on_ground = false,
+}
+
+local last_time = 0
+
+function on_start()
+  last_time = game_time()
+  log("{{.Title}} started")
+end
+
+function on_update()
+  local now = game_time()
+  local dt = now - last_time
+  last_time = now
+
+  local dx = 0
+  if action_pressed("left") then dx = dx - player.run_speed * dt end
+  if action_pressed("right") then dx = dx + player.run_speed * dt end
+
+  if player.on_ground and action_pressed("jump") then
+    player.velocity_y = -player.jump_speed
+    player.on_ground = false
+  end
+
+  player.velocity_y = player.velocity_y + player.gravity * dt
+  local _, y = get_player_position()
+  local dy = player.velocity_y * dt
+  if y + dy >= player.ground_y then
+    dy = player.ground_y - y
+    player.velocity_y = 0
+    player.on_ground = true
+  end
+
+  move_player(dx, dy)
+end
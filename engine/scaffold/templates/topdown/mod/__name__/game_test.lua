@@ -0,0 +1,31 @@
+-- Tests for {{.Name}}, run headless with `luengo test`
+
+local game = get_mod("{{.Name}}")
+
+describe("{{.Name}} movement", function()
+  local start_x, start_y
+
+  before_each(function()
+    start_x, start_y = get_player_position()
+  end)
+
+  it("moves down while S is held", function()
+    press("S")
+    advance(0.5)
+    local _, y = get_player_position()
+    expect(y):to_be_greater_than(start_y)
+  end)
+
+  it("stays put without input", function()
+    advance(0.5)
+    local x, y = get_player_position()
+    expect(x):to_be(start_x)
+    expect(y):to_be(start_y)
+  end)
+
+  it("binds every direction", function()
+    for _, action in ipairs({ "up", "down", "left", "right" }) do
+      expect(game.bindings[action]).never:to_be_nil()
+    end
+  end)
+end)
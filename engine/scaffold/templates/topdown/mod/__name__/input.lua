@@ -0,0 +1,18 @@
+-- Input bindings: each action lists the keys that trigger it
+
+bindings = {
+  up = { "W", "ArrowUp" },
+  down = { "S", "ArrowDown" },
+  left = { "A", "ArrowLeft" },
+  right = { "D", "ArrowRight" },
+}
+
+-- action_pressed returns true while any key bound to the action is held
+function action_pressed(action)
+  for _, key in ipairs(bindings[action] or {}) do
+    if is_key_pressed(key) then
+      return true
+    end
+  end
+  return false
+end
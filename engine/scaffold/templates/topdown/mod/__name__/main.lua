@@ -0,0 +1,32 @@
+-- {{.Title}}: top-down starter
+-- Moves the player in eight directions at a constant speed.
+
+player = {
+  speed = 180, -- Pixels per second
+}
+
+local last_time = 0
+
+function on_start()
+  last_time = game_time()
+  log("{{.Title}} started")
+end
+
+function on_update()
+  local now = game_time()
+  local dt = now - last_time
+  last_time = now
+
+  local dx, dy = 0, 0
+  if action_pressed("left") then dx = dx - 1 end
+  if action_pressed("right") then dx = dx + 1 end
+  if action_pressed("up") then dy = dy - 1 end
+  if action_pressed("down") then dy = dy + 1 end
+
+  if dx ~= 0 or dy ~= 0 then
+    -- Diagonals are no faster than straight moves
+    local length = math.sqrt(dx * dx + dy * dy)
+    local step = player.speed * dt / length
+    move_player(dx * step, dy * step)
+  end
+end
@@ -0,0 +1,204 @@
+package scene
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"deepthinking.do/luengo/engine/entity"
+	"deepthinking.do/luengo/engine/logging"
+	"deepthinking.do/luengo/engine/prefab"
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+// Entity is the saved form of an entity. Prefab instances store only the prefab
+// name, their position and the properties they override, so edits to the prefab
+// reach every instance the next time the scene is loaded.
+type Entity struct {
+	Prefab    string           `json:"prefab,omitempty"`
+	Position  entity.Vec2      `json:"position"`
+	Overrides prefab.Overrides `json:"overrides,omitempty"`
+	Node      *prefab.Node     `json:"node,omitempty"` // Full data for entities not linked to a prefab
+	Children  []*Entity        `json:"children,omitempty"`
+}
+
+// Scene is the saved form of every entity in the entity manager
+type Scene struct {
+	Entities []*Entity `json:"entities"`
+}
+
+// Capture builds a scene from the current entities
+func Capture(em *entity.Manager, pm *prefab.Manager) *Scene {
+	s := &Scene{Entities: make([]*Entity, 0)}
+	for _, root := range em.GetRoots() {
+		s.Entities = append(s.Entities, capture(root, pm))
+	}
+	return s
+}
+
+func capture(e *entity.Entity, pm *prefab.Manager) *Entity {
+	saved := &Entity{Position: e.Position}
+	if e.IsPrefabRoot() {
+		saved.Prefab = e.Prefab.Name
+		saved.Overrides = pm.InstanceOverrides(e)
+	} else {
+		saved.Node = prefab.NodeFromEntity(e)
+	}
+
+	// Children created by the prefab are rebuilt from it; anything else is saved explicitly
+	for _, child := range e.Children {
+		if prefab.IsInstanceChild(e, child) {
+			saved.Children = append(saved.Children, captureExtraChildren(child, pm)...)
+			continue
+		}
+		saved.Children = append(saved.Children, capture(child, pm))
+	}
+	return saved
+}
+
+// captureExtraChildren collects entities attached below a prefab's own children.
+// They are reattached to the instance root when the scene is restored.
+func captureExtraChildren(e *entity.Entity, pm *prefab.Manager) []*Entity {
+	extra := make([]*Entity, 0)
+	for _, child := range e.Children {
+		if child.Prefab != nil && child.Prefab.Path != "" {
+			extra = append(extra, captureExtraChildren(child, pm)...)
+			continue
+		}
+		extra = append(extra, capture(child, pm))
+	}
+	return extra
+}
+
+// Restore replaces the current entities with the scene's entities. If any of them
+// cannot be built, the entities from before are put back.
+func (s *Scene) Restore(em *entity.Manager, pm *prefab.Manager) error {
+	previous := em.Swap(make(map[entity.ID]*entity.Entity))
+	for _, saved := range s.Entities {
+		if _, err := restore(saved, nil, em, pm); err != nil {
+			em.Swap(previous)
+			return err
+		}
+	}
+	return nil
+}
+
+func restore(saved *Entity, parent *entity.Entity, em *entity.Manager, pm *prefab.Manager) (*entity.Entity, error) {
+	var e *entity.Entity
+	switch {
+	case saved.Prefab != "":
+		instance, err := pm.InstantiateWithOverrides(saved.Prefab, saved.Position.X, saved.Position.Y, saved.Overrides)
+		if err != nil {
+			return nil, err
+		}
+		e = instance
+	case saved.Node != nil:
+		e = pm.CreateFromNode(saved.Node)
+		e.Position = saved.Position
+	default:
+		return nil, fmt.Errorf("scene entity has neither prefab nor node data")
+	}
+
+	if parent != nil {
+		if err := em.SetParent(e, parent, false); err != nil {
+			return nil, err
+		}
+	}
+	for _, child := range saved.Children {
+		if _, err := restore(child, e, em, pm); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Save writes the current entities to a scene file on disk
+func Save(path string, em *entity.Manager, pm *prefab.Manager) error {
+	data, err := json.MarshalIndent(Capture(em, pm), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode scene: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create scene folder: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write scene file %s: %w", path, err)
+	}
+	logging.Infof("scene", "Saved: %s", path)
+	return nil
+}
+
+// Sprites lists the sprite paths a scene file uses, including those of its prefabs,
+// so they can be preloaded before the scene is loaded
+func Sprites(files fs.FS, path string, pm *prefab.Manager) ([]string, error) {
+	s, err := read(files, path)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var sprites []string
+	add := func(sprite string) {
+		if sprite != "" && !seen[sprite] {
+			seen[sprite] = true
+			sprites = append(sprites, sprite)
+		}
+	}
+	var addNode func(n *prefab.Node)
+	addNode = func(n *prefab.Node) {
+		add(n.Sprite)
+		for _, child := range n.Children {
+			addNode(child)
+		}
+	}
+	var addEntity func(e *Entity)
+	addEntity = func(e *Entity) {
+		if e.Prefab != "" {
+			if p, ok := pm.Get(e.Prefab); ok {
+				addNode(p.Root)
+			}
+			for _, values := range e.Overrides {
+				if sprite, ok := values["sprite"].(string); ok {
+					add(sprite)
+				}
+			}
+		}
+		if e.Node != nil {
+			addNode(e.Node)
+		}
+		for _, child := range e.Children {
+			addEntity(child)
+		}
+	}
+	for _, e := range s.Entities {
+		addEntity(e)
+	}
+	return sprites, nil
+}
+
+// read parses a scene file
+func read(files fs.FS, path string) (*Scene, error) {
+	data, err := fs.ReadFile(files, vfs.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scene file %s: %w", path, err)
+	}
+	s := &Scene{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse scene file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Load replaces the current entities with the ones in a scene file read from files
+func Load(files fs.FS, path string, em *entity.Manager, pm *prefab.Manager) error {
+	s, err := read(files, path)
+	if err != nil {
+		return err
+	}
+	if err := s.Restore(em, pm); err != nil {
+		return fmt.Errorf("failed to restore scene %s: %w", path, err)
+	}
+	logging.Infof("scene", "Loaded: %s", path)
+	return nil
+}
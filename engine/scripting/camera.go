@@ -0,0 +1,368 @@
+package scripting
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	lua "github.com/yuin/gopher-lua"
+
+	"deepthinking.do/luengo/engine/camera"
+	"deepthinking.do/luengo/engine/entity"
+	"deepthinking.do/luengo/engine/resources"
+)
+
+// TexturePrefix starts the sprite path of a camera rendering to a texture, followed
+// by the camera's name
+const TexturePrefix = "camera:"
+
+// RegisterCameraFunctions exposes the main camera to Lua as the camera table and
+// every camera through the cameras table. Positions are the world point at the
+// centre of the view.
+func (sm *Manager) RegisterCameraFunctions(cams *camera.Manager, em *entity.Manager, rm *resources.Manager) {
+	L := sm.luaState
+	tables := map[*camera.Camera]*lua.LTable{}
+	textures := map[string]*resources.SpriteHandle{}
+
+	// replaceTexture points the entities showing a camera's texture at a new one, or
+	// at none, and drops the old one from the sprite cache
+	replaceTexture := func(cam *camera.Camera, old, texture *ebiten.Image) {
+		for _, e := range em.GetEntitiesSlice() {
+			if e.Sprite == old {
+				e.Sprite = texture
+			}
+		}
+		if texture == nil {
+			rm.UnloadSprite(TexturePrefix + cam.Name)
+		}
+		if handle, ok := textures[cam.Name]; ok {
+			handle.Release()
+			delete(textures, cam.Name)
+		}
+	}
+	// renderToScreen stops a camera drawing into a texture; entities showing the
+	// texture show nothing
+	renderToScreen := func(cam *camera.Camera) {
+		if old := cam.Texture; old != nil {
+			cam.RenderToScreen()
+			replaceTexture(cam, old, nil)
+		}
+	}
+
+	// cameraTable returns the table for a camera, the same one each time
+	cameraTable := func(cam *camera.Camera) *lua.LTable {
+		if api, ok := tables[cam]; ok {
+			return api
+		}
+		api := sm.cameraFunctions(cam, em)
+		L.SetFuncs(api, map[string]lua.LGFunction{
+			"get_name": func(L *lua.LState) int {
+				L.Push(lua.LString(cam.Name))
+				return 1
+			},
+			// cam.set_area(x, y, width, height) sets the part of the game view the camera
+			// draws into, as fractions, e.g. 0, 0, 0.5, 1 for the left half
+			"set_area": func(L *lua.LState) int {
+				cam.Area = camera.Rect{X: float64(L.CheckNumber(1)), Y: float64(L.CheckNumber(2)), W: float64(L.CheckNumber(3)), H: float64(L.CheckNumber(4))}
+				sm.layoutCameras(cams)
+				return 0
+			},
+			// cam.set_layers({...}) draws only entities on the listed render layers, "" being
+			// the default layer; no argument draws every layer
+			"set_layers": func(L *lua.LState) int {
+				cam.Layers = nil
+				if t, ok := L.Get(1).(*lua.LTable); ok {
+					t.ForEach(func(_, v lua.LValue) {
+						cam.Layers = append(cam.Layers, v.String())
+					})
+				}
+				return 0
+			},
+			"set_enabled": func(L *lua.LState) int {
+				cam.Enabled = L.CheckBool(1)
+				return 0
+			},
+			// cam.set_order(n) sets the draw order; higher draws over lower
+			"set_order": func(L *lua.LState) int {
+				cam.Order = L.CheckInt(1)
+				return 0
+			},
+			// cam.set_background(r, g, b [, a]) fills the view before drawing; no arguments
+			// draws over what is below
+			"set_background": func(L *lua.LState) int {
+				if L.GetTop() == 0 {
+					cam.Background = nil
+					return 0
+				}
+				cam.Background = color.RGBA{
+					R: uint8(L.CheckInt(1)), G: uint8(L.CheckInt(2)), B: uint8(L.CheckInt(3)),
+					A: uint8(L.OptInt(4, 255)),
+				}
+				return 0
+			},
+			// cam.render_to_texture(width, height) draws the camera into an offscreen image
+			// instead of the screen, and returns its sprite path for entities to show
+			"render_to_texture": func(L *lua.LState) int {
+				old := cam.Texture
+				texture, err := cam.RenderToTexture(L.CheckInt(1), L.CheckInt(2))
+				if err != nil {
+					L.ArgError(1, err.Error())
+				}
+				path := TexturePrefix + cam.Name
+				if texture != old {
+					handle := rm.AddSprite(path, texture)
+					if old != nil {
+						replaceTexture(cam, old, texture)
+					}
+					textures[cam.Name] = handle
+				}
+				L.Push(lua.LString(path))
+				return 1
+			},
+			"render_to_screen": func(L *lua.LState) int {
+				renderToScreen(cam)
+				sm.layoutCameras(cams)
+				return 0
+			},
+		})
+		tables[cam] = api
+		return api
+	}
+
+	cameras := L.NewTable()
+	L.SetFuncs(cameras, map[string]lua.LGFunction{
+		// cameras.add(name [, x, y, width, height]) creates a camera drawing into part of
+		// the game view, given as fractions, and returns its table
+		"add": func(L *lua.LState) int {
+			cam, err := cams.Add(L.CheckString(1))
+			if err != nil {
+				L.ArgError(1, err.Error())
+			}
+			if L.GetTop() >= 5 {
+				cam.Area = camera.Rect{X: float64(L.CheckNumber(2)), Y: float64(L.CheckNumber(3)), W: float64(L.CheckNumber(4)), H: float64(L.CheckNumber(5))}
+			}
+			// Start where the main camera looks
+			main := cams.Main()
+			cam.Zoom = main.Zoom
+			sm.layoutCameras(cams)
+			x, y := main.Center()
+			cam.SetCenter(x, y)
+			L.Push(cameraTable(cam))
+			return 1
+		},
+		// cameras.get(name) returns a camera's table, or nil
+		"get": func(L *lua.LState) int {
+			cam, ok := cams.Get(L.CheckString(1))
+			if !ok {
+				L.Push(lua.LNil)
+				return 1
+			}
+			L.Push(cameraTable(cam))
+			return 1
+		},
+		// cameras.remove(name) removes a camera other than the main one
+		"remove": func(L *lua.LState) int {
+			name := L.CheckString(1)
+			cam, ok := cams.Get(name)
+			if !ok || !cams.Remove(name) {
+				L.Push(lua.LFalse)
+				return 1
+			}
+			renderToScreen(cam)
+			delete(tables, cam)
+			L.Push(lua.LTrue)
+			return 1
+		},
+		// cameras.list() returns the camera names in draw order
+		"list": func(L *lua.LState) int {
+			list := L.NewTable()
+			for _, name := range cams.Names() {
+				list.Append(lua.LString(name))
+			}
+			L.Push(list)
+			return 1
+		},
+	})
+	L.SetGlobal("cameras", cameras)
+	L.SetGlobal("camera", cameraTable(cams.Main()))
+}
+
+// layoutCameras lays out a camera added or changed from Lua in the same screen area
+// as the main camera, until the engine lays them all out again
+func (sm *Manager) layoutCameras(cams *camera.Manager) {
+	main := cams.Main()
+	v, a := main.Viewport, main.Area
+	if a.W <= 0 || a.H <= 0 {
+		return
+	}
+	w, h := v.W/a.W, v.H/a.H
+	cams.Layout(v.X-a.X*w, v.Y-a.Y*h, w, h)
+}
+
+// cameraFunctions returns a table of the functions every camera has
+func (sm *Manager) cameraFunctions(cam *camera.Camera, em *entity.Manager) *lua.LTable {
+	L := sm.luaState
+	api := L.NewTable()
+
+	pushPair := func(L *lua.LState, a, b float64) int {
+		L.Push(lua.LNumber(a))
+		L.Push(lua.LNumber(b))
+		return 2
+	}
+
+	// easing reads an optional easing name argument
+	easing := func(L *lua.LState, n int) camera.Easing {
+		name := L.OptString(n, camera.DefaultEasing)
+		ease, ok := camera.EasingByName(name)
+		if !ok {
+			L.ArgError(n, fmt.Sprintf("unknown easing %q, expected one of %s", name, strings.Join(camera.EasingNames(), ", ")))
+		}
+		return ease
+	}
+
+	L.SetFuncs(api, map[string]lua.LGFunction{
+		// camera.get_position() returns the world x, y at the centre of the view
+		"get_position": func(L *lua.LState) int {
+			x, y := cam.Center()
+			return pushPair(L, x, y)
+		},
+		// camera.set_position(x, y) centres the view on a world position
+		"set_position": func(L *lua.LState) int {
+			cam.SetCenter(float64(L.CheckNumber(1)), float64(L.CheckNumber(2)))
+			return 0
+		},
+		"get_zoom": func(L *lua.LState) int {
+			L.Push(lua.LNumber(cam.Zoom))
+			return 1
+		},
+		// camera.set_zoom(zoom) zooms around the centre of the view, within the limits
+		"set_zoom": func(L *lua.LState) int {
+			cam.ZoomCentered(float64(L.CheckNumber(1)) / cam.Zoom)
+			return 0
+		},
+		"set_zoom_limits": func(L *lua.LState) int {
+			if err := cam.SetZoomLimits(float64(L.CheckNumber(1)), float64(L.CheckNumber(2))); err != nil {
+				L.ArgError(1, err.Error())
+			}
+			return 0
+		},
+		// camera.set_bounds(x, y, width, height) keeps the view inside a world area
+		"set_bounds": func(L *lua.LState) int {
+			cam.SetBounds(float64(L.CheckNumber(1)), float64(L.CheckNumber(2)), float64(L.CheckNumber(3)), float64(L.CheckNumber(4)))
+			return 0
+		},
+		"clear_bounds": func(L *lua.LState) int {
+			cam.ClearBounds()
+			return 0
+		},
+		// camera.follow(id) follows an entity, or the player with "player"
+		"follow": func(L *lua.LState) int {
+			var target *entity.Entity
+			if L.Get(1) == lua.LString("player") {
+				target = sm.player
+			} else if e, ok := em.GetEntity(entity.ID(L.CheckInt(1))); ok {
+				target = e
+			}
+			if target == nil {
+				L.Push(lua.LFalse)
+				return 1
+			}
+			cam.SetTarget(target)
+			L.Push(lua.LTrue)
+			return 1
+		},
+		"stop_follow": func(L *lua.LState) int {
+			cam.SetTarget(nil)
+			return 0
+		},
+		// camera.set_dead_zone(width, height) lets the target move inside a box of
+		// screen pixels around the centre before the camera follows
+		"set_dead_zone": func(L *lua.LState) int {
+			cam.Follow.DeadZoneW = float64(L.CheckNumber(1))
+			cam.Follow.DeadZoneH = float64(L.CheckNumber(2))
+			return 0
+		},
+		// camera.set_look_ahead(distance) leads the target by world units in the direction it moves
+		"set_look_ahead": func(L *lua.LState) int {
+			cam.Follow.LookAhead = float64(L.CheckNumber(1))
+			return 0
+		},
+		// camera.set_smoothing(speed) sets how quickly the camera catches up; 0 snaps
+		"set_smoothing": func(L *lua.LState) int {
+			cam.Follow.Smoothing = float64(L.CheckNumber(1))
+			return 0
+		},
+		"screen_to_world": func(L *lua.LState) int {
+			x, y := cam.ScreenToWorld(float64(L.CheckNumber(1)), float64(L.CheckNumber(2)))
+			return pushPair(L, x, y)
+		},
+		"world_to_screen": func(L *lua.LState) int {
+			x, y := cam.WorldToScreen(float64(L.CheckNumber(1)), float64(L.CheckNumber(2)))
+			return pushPair(L, x, y)
+		},
+		// camera.shake(trauma) shakes the view; trauma from 0 to 1 adds up and wears off
+		"shake": func(L *lua.LState) int {
+			cam.AddTrauma(float64(L.CheckNumber(1)))
+			return 0
+		},
+		// camera.set_shake(max_offset, max_angle, decay) sets the shake at full trauma in
+		// pixels and radians, and the trauma lost per second
+		"set_shake": func(L *lua.LState) int {
+			cam.Shake.MaxOffset = float64(L.CheckNumber(1))
+			cam.Shake.MaxAngle = float64(L.OptNumber(2, lua.LNumber(cam.Shake.MaxAngle)))
+			cam.Shake.Decay = float64(L.OptNumber(3, lua.LNumber(cam.Shake.Decay)))
+			return 0
+		},
+		"get_rotation": func(L *lua.LState) int {
+			L.Push(lua.LNumber(cam.Rotation))
+			return 1
+		},
+		"set_rotation": func(L *lua.LState) int {
+			cam.Rotation = float64(L.CheckNumber(1))
+			return 0
+		},
+		// camera.pan_to(x, y, seconds [, easing]) moves the centre of the view
+		"pan_to": func(L *lua.LState) int {
+			cam.PanTo(float64(L.CheckNumber(1)), float64(L.CheckNumber(2)), float64(L.CheckNumber(3)), easing(L, 4))
+			return 0
+		},
+		"zoom_to": func(L *lua.LState) int {
+			cam.ZoomTo(float64(L.CheckNumber(1)), float64(L.CheckNumber(2)), easing(L, 3))
+			return 0
+		},
+		// camera.rotate_to(radians, seconds [, easing]) turns the view
+		"rotate_to": func(L *lua.LState) int {
+			cam.RotateTo(float64(L.CheckNumber(1)), float64(L.CheckNumber(2)), easing(L, 3))
+			return 0
+		},
+		// camera.focus(id, seconds [, easing]) pans and zooms to frame an entity
+		"focus": func(L *lua.LState) int {
+			e, ok := em.GetEntity(entity.ID(L.CheckInt(1)))
+			if !ok {
+				L.Push(lua.LFalse)
+				return 1
+			}
+			minX, minY, maxX, maxY := e.WorldBounds()
+			cam.FocusOn(camera.Rect{X: minX, Y: minY, W: maxX - minX, H: maxY - minY}, float64(L.CheckNumber(2)), easing(L, 3))
+			L.Push(lua.LTrue)
+			return 1
+		},
+		// camera.is_transitioning() reports whether a pan, zoom or rotation is running
+		"is_transitioning": func(L *lua.LState) int {
+			L.Push(lua.LBool(cam.IsTransitioning()))
+			return 1
+		},
+		"stop_transitions": func(L *lua.LState) int {
+			cam.StopTransitions()
+			return 0
+		},
+		// camera.get_viewport() returns the screen x, y, width and height the game is drawn in
+		"get_viewport": func(L *lua.LState) int {
+			v := cam.Viewport
+			pushPair(L, v.X, v.Y)
+			return 2 + pushPair(L, v.W, v.H)
+		},
+	})
+	return api
+}
@@ -0,0 +1,248 @@
+package scripting
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	lua "github.com/yuin/gopher-lua"
+
+	"deepthinking.do/luengo/engine/render"
+	"deepthinking.do/luengo/engine/resources"
+)
+
+// DrawFrame holds what the draw hooks recorded for one frame. Scripts only record
+// commands; the engine draws them when it draws the frame.
+type DrawFrame struct {
+	World  render.DrawList // on_draw, in world units
+	Screen render.DrawList // on_draw after set_space("screen"), in pixels of the game view
+	UI     render.DrawList // on_draw_ui, in pixels of the window, over post-processing
+}
+
+// Reset empties the frame's lists
+func (f *DrawFrame) Reset() {
+	f.World.Reset()
+	f.Screen.Reset()
+	f.UI.Reset()
+}
+
+// drawState is what the draw table records with while a draw hook runs
+type drawState struct {
+	frame         *DrawFrame
+	list          *render.DrawList // The list commands go to
+	ui            bool             // Running on_draw_ui, which has no world space
+	width, height float64          // Pixels of the area the hook draws on
+	color         color.NRGBA
+	lineWidth     float64
+}
+
+// reset puts the state back to how each mod's hook starts
+func (s *drawState) reset() {
+	s.list = &s.frame.World
+	if s.ui {
+		s.list = &s.frame.UI
+	}
+	s.color = color.NRGBA{255, 255, 255, 255}
+	s.lineWidth = 1
+}
+
+// RecordDraw calls on_draw and then on_draw_ui in every mod that defines them,
+// recording what they draw into frame. The game view and the window are given in
+// pixels. The draw table only records during the call.
+func (sm *Manager) RecordDraw(frame *DrawFrame, viewWidth, viewHeight, width, height float64) []*ScriptError {
+	frame.Reset()
+	defer func() { sm.drawing = nil }()
+
+	sm.drawing = &drawState{frame: frame, width: viewWidth, height: viewHeight}
+	errs := sm.callHook("on_draw", func(*Mod) { sm.drawing.reset() })
+
+	sm.drawing = &drawState{frame: frame, ui: true, width: width, height: height}
+	return append(errs, sm.callHook("on_draw_ui", func(*Mod) { sm.drawing.reset() })...)
+}
+
+// RegisterDrawFunctions exposes immediate-mode drawing to Lua as the draw table.
+// Shapes, sprites and text are recorded from on_draw, in world units unless
+// set_space("screen") is called, and from on_draw_ui in pixels of the window. Each
+// hook starts with white lines one unit wide.
+func (sm *Manager) RegisterDrawFunctions(rm *resources.Manager) {
+	L := sm.luaState
+	api := L.NewTable()
+	// Sprites draw.sprite has used, referenced until the scene changes, and those that
+	// failed to load, so a bad path is not read from disk every frame
+	sprites := make(map[string]*resources.SpriteHandle)
+	failed := make(map[string]error)
+	scope := rm.SceneScope()
+
+	// state returns what to record with, raising an error outside the draw hooks
+	state := func(L *lua.LState) *drawState {
+		if sm.drawing == nil {
+			L.RaiseError("draw functions can only be called from on_draw or on_draw_ui")
+		}
+		return sm.drawing
+	}
+	// filled reads a "fill" or "line" mode argument
+	filled := func(L *lua.LState, n int) bool {
+		switch mode := L.CheckString(n); mode {
+		case "fill":
+			return true
+		case "line":
+			return false
+		default:
+			L.ArgError(n, "expected \"fill\" or \"line\", got \""+mode+"\"")
+			return false
+		}
+	}
+	number := func(L *lua.LState, n int) float64 {
+		return float64(L.CheckNumber(n))
+	}
+	// field reads a number from an options table, or def when it is missing
+	field := func(opts *lua.LTable, name string, def float64) float64 {
+		if opts == nil {
+			return def
+		}
+		if n, ok := opts.RawGetString(name).(lua.LNumber); ok {
+			return float64(n)
+		}
+		return def
+	}
+
+	L.SetFuncs(api, map[string]lua.LGFunction{
+		// draw.set_color(r, g, b [, a]) sets the colour of what follows, 0 to 255; sprites
+		// are tinted by it
+		"set_color": func(L *lua.LState) int {
+			s := state(L)
+			s.color = color.NRGBA{R: channel(L, 1), G: channel(L, 2), B: channel(L, 3), A: uint8(clamp(float64(L.OptNumber(4, 255)), 0, 255))}
+			return 0
+		},
+		// draw.set_line_width(width) sets how wide lines and outlines are
+		"set_line_width": func(L *lua.LState) int {
+			state(L).lineWidth = float64(L.CheckNumber(1))
+			return 0
+		},
+		// draw.set_space("world" | "screen") picks whether on_draw positions are world
+		// units seen through the camera or pixels of the game view
+		"set_space": func(L *lua.LState) int {
+			s := state(L)
+			if s.ui {
+				L.RaiseError("on_draw_ui always draws in screen space")
+			}
+			switch space := L.CheckString(1); space {
+			case "world":
+				s.list = &s.frame.World
+			case "screen":
+				s.list = &s.frame.Screen
+			default:
+				L.ArgError(1, "expected \"world\" or \"screen\", got \""+space+"\"")
+			}
+			return 0
+		},
+		// draw.get_size() returns the width and height in pixels of the game view in
+		// on_draw, or of the window in on_draw_ui
+		"get_size": func(L *lua.LState) int {
+			s := state(L)
+			L.Push(lua.LNumber(s.width))
+			L.Push(lua.LNumber(s.height))
+			return 2
+		},
+		// draw.line(x1, y1, x2, y2, ...) draws connected lines through the points
+		"line": func(L *lua.LState) int {
+			s := state(L)
+			if L.GetTop() < 4 || L.GetTop()%2 != 0 {
+				L.RaiseError("draw.line needs two or more x, y pairs")
+			}
+			points := make([]float64, L.GetTop())
+			for i := range points {
+				points[i] = number(L, i+1)
+			}
+			s.list.Polyline(points, s.lineWidth, s.color)
+			return 0
+		},
+		// draw.rect(mode, x, y, w, h) draws a rectangle, filled or outlined
+		"rect": func(L *lua.LState) int {
+			s := state(L)
+			s.list.Rect(filled(L, 1), number(L, 2), number(L, 3), number(L, 4), number(L, 5), s.lineWidth, s.color)
+			return 0
+		},
+		// draw.circle(mode, x, y, radius) draws a circle, filled or outlined
+		"circle": func(L *lua.LState) int {
+			s := state(L)
+			s.list.Circle(filled(L, 1), number(L, 2), number(L, 3), number(L, 4), s.lineWidth, s.color)
+			return 0
+		},
+		// draw.polygon(mode, {x1, y1, x2, y2, x3, y3, ...}) draws a closed polygon, which
+		// may be concave
+		"polygon": func(L *lua.LState) int {
+			s := state(L)
+			fill := filled(L, 1)
+			tbl := L.CheckTable(2)
+			if tbl.Len() < 6 || tbl.Len()%2 != 0 {
+				L.ArgError(2, "expected three or more x, y pairs")
+			}
+			points := make([]float64, tbl.Len())
+			for i := range points {
+				n, ok := tbl.RawGetInt(i + 1).(lua.LNumber)
+				if !ok {
+					L.ArgError(2, "points must be numbers")
+				}
+				points[i] = float64(n)
+			}
+			s.list.Polygon(fill, points, s.lineWidth, s.color)
+			return 0
+		},
+		// draw.sprite(path, x, y [, {rotation, scale, scale_x, scale_y, origin_x, origin_y}])
+		// draws an image with its origin, the top left by default, at x, y. It returns
+		// false and the error when the image cannot be loaded.
+		"sprite": func(L *lua.LState) int {
+			s := state(L)
+			path := L.CheckString(1)
+			x, y := number(L, 2), number(L, 3)
+			opts := L.OptTable(4, nil)
+			// A new scene drops the sprites the last one drew, and tries failed ones again
+			if current := rm.SceneScope(); current != scope {
+				for _, h := range sprites {
+					h.Release()
+				}
+				sprites, failed, scope = make(map[string]*resources.SpriteHandle), make(map[string]error), current
+			}
+			h, ok := sprites[path]
+			if !ok {
+				err := failed[path]
+				if err == nil {
+					h, err = rm.AcquireSprite(path)
+				}
+				if err != nil {
+					failed[path] = err
+					L.Push(lua.LFalse)
+					L.Push(lua.LString(err.Error()))
+					return 2
+				}
+				sprites[path] = h
+			}
+			scale := field(opts, "scale", 1)
+			var geom ebiten.GeoM
+			geom.Translate(-field(opts, "origin_x", 0), -field(opts, "origin_y", 0))
+			geom.Scale(field(opts, "scale_x", scale), field(opts, "scale_y", scale))
+			geom.Rotate(field(opts, "rotation", 0))
+			geom.Translate(x, y)
+			s.list.Image(h.Get(), geom, s.color)
+			L.Push(lua.LTrue)
+			return 1
+		},
+		// draw.text(text, x, y [, scale]) draws text with its top left at x, y, in a
+		// 7x13 pixel font
+		"text": func(L *lua.LState) int {
+			s := state(L)
+			s.list.Text(L.CheckString(1), number(L, 2), number(L, 3), float64(L.OptNumber(4, 1)), s.color)
+			return 0
+		},
+		// draw.text_size(text [, scale]) returns the width and height draw.text covers,
+		// e.g. to centre it; it works outside the draw hooks too
+		"text_size": func(L *lua.LState) int {
+			scale := float64(L.OptNumber(2, 1))
+			w, h := render.MeasureText(L.CheckString(1))
+			L.Push(lua.LNumber(w * scale))
+			L.Push(lua.LNumber(h * scale))
+			return 2
+		},
+	})
+	L.SetGlobal("draw", api)
+}
@@ -0,0 +1,81 @@
+package scripting
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"deepthinking.do/luengo/engine/logging"
+)
+
+var (
+	// Runtime errors: "mod/main.lua:33: attempt to call a nil value"
+	runtimeErrorPattern = regexp.MustCompile(`^(.+?\.lua):(\d+):\s*(.*)$`)
+	// Syntax errors: "mod/main.lua line:5(column:3) near 'end':   syntax error"
+	syntaxErrorPattern = regexp.MustCompile(`^(.+?\.lua) line:(\d+)\(column:\d+\) near (.*)$`)
+)
+
+// ScriptError is a Lua error with the mod, source location and traceback it came from
+type ScriptError struct {
+	Mod       string // Mod id, e.g. "player"
+	Function  string // Hook being called, or "load" for errors while loading a file
+	File      string
+	Line      int
+	Message   string
+	Traceback string
+}
+
+func (e *ScriptError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location = fmt.Sprintf("%s:%d", e.File, e.Line)
+	}
+	if location == "" {
+		return fmt.Sprintf("[%s] %s: %s", e.Mod, e.Function, e.Message)
+	}
+	return fmt.Sprintf("[%s] %s: %s: %s", e.Mod, e.Function, location, e.Message)
+}
+
+// newScriptError extracts the file, line and traceback from a gopher-lua error.
+// file is used as the location when the message does not carry one.
+func newScriptError(mod, function, file string, err error) *ScriptError {
+	se := &ScriptError{Mod: mod, Function: function, File: file}
+
+	msg := err.Error()
+	if apiErr, ok := err.(*lua.ApiError); ok {
+		msg = apiErr.Object.String()
+		se.Traceback = apiErr.StackTrace
+	}
+	msg = strings.TrimSpace(msg)
+
+	if m := runtimeErrorPattern.FindStringSubmatch(msg); m != nil {
+		se.File = m[1]
+		se.Line, _ = strconv.Atoi(m[2])
+		msg = m[3]
+	} else if m := syntaxErrorPattern.FindStringSubmatch(msg); m != nil {
+		se.File = m[1]
+		se.Line, _ = strconv.Atoi(m[2])
+		msg = "near " + strings.Join(strings.Fields(m[3]), " ")
+	}
+	se.Message = msg
+	return se
+}
+
+// log records the error in the engine log with its location and traceback
+func (e *ScriptError) log() {
+	source := "lua"
+	if e.File != "" {
+		source = "lua:" + e.File
+	}
+	logging.Default().Add(logging.Entry{
+		Level:   logging.LevelError,
+		Source:  source,
+		Message: e.Error(),
+		File:    e.File,
+		Line:    e.Line,
+		Detail:  e.Traceback,
+	})
+}
@@ -0,0 +1,142 @@
+package scripting
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Eval runs a chunk of Lua against the live state and returns its results formatted for display.
+// Expressions are tried first so that "player.stats" prints its value without a return.
+// Names resolve as in consoleGet; globals the chunk sets stay in the console's own environment.
+func (sm *Manager) Eval(code string) ([]string, error) {
+	L := sm.luaState
+
+	fn, err := L.LoadString("return " + code)
+	if err != nil {
+		fn, err = L.LoadString(code)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fn.Env = sm.console()
+
+	top := L.GetTop()
+	L.Push(fn)
+	if err := L.PCall(0, lua.MultRet, nil); err != nil {
+		return nil, err
+	}
+
+	count := L.GetTop() - top
+	results := make([]string, count)
+	for i := 0; i < count; i++ {
+		results[i] = FormatValue(L.Get(top+1+i), 1)
+	}
+	L.Pop(count)
+	return results, nil
+}
+
+// console returns the environment console chunks run in. Names it does not hold are
+// looked up by consoleGet, so the prompt sees what the game is running.
+func (sm *Manager) console() *lua.LTable {
+	if sm.consoleEnv == nil {
+		L := sm.luaState
+		sm.consoleEnv = L.NewTable()
+		meta := L.NewTable()
+		meta.RawSetString("__index", L.NewFunction(func(L *lua.LState) int {
+			L.Push(sm.consoleGet(L.CheckAny(2)))
+			return 1
+		}))
+		L.SetMetatable(sm.consoleEnv, meta)
+	}
+	return sm.consoleEnv
+}
+
+// consoleGet looks a name up in the enabled mods' environments, in load order, and
+// then in the shared globals
+func (sm *Manager) consoleGet(key lua.LValue) lua.LValue {
+	for _, m := range sm.mods {
+		if m.Disabled {
+			continue
+		}
+		if value := m.Env.RawGet(key); value != lua.LNil {
+			return value
+		}
+	}
+	return sm.luaState.G.Global.RawGet(key)
+}
+
+// Complete returns the global names (or table fields, for dotted prefixes) starting with prefix,
+// including globals the enabled mods define
+func (sm *Manager) Complete(prefix string) []string {
+	// Tables whose fields can complete the last segment; names start with every global table
+	tables := []*lua.LTable{sm.console()}
+	for _, m := range sm.mods {
+		if !m.Disabled {
+			tables = append(tables, m.Env)
+		}
+	}
+	tables = append(tables, sm.luaState.G.Global)
+
+	// Resolve every segment before the last separator to a table
+	base := ""
+	partial := prefix
+	if i := strings.LastIndexAny(prefix, ".:"); i >= 0 {
+		base = prefix[:i+1]
+		partial = prefix[i+1:]
+		var value lua.LValue
+		for n, segment := range strings.FieldsFunc(prefix[:i], func(r rune) bool { return r == '.' || r == ':' }) {
+			if n == 0 {
+				value = sm.console().RawGetString(segment)
+				if value == lua.LNil {
+					value = sm.consoleGet(lua.LString(segment))
+				}
+			} else {
+				value = value.(*lua.LTable).RawGetString(segment)
+			}
+			if _, ok := value.(*lua.LTable); !ok {
+				return nil
+			}
+		}
+		tables = []*lua.LTable{value.(*lua.LTable)}
+	}
+
+	seen := make(map[string]bool)
+	matches := make([]string, 0)
+	for _, table := range tables {
+		table.ForEach(func(key, _ lua.LValue) {
+			name, ok := key.(lua.LString)
+			if ok && strings.HasPrefix(string(name), partial) && !seen[string(name)] {
+				seen[string(name)] = true
+				matches = append(matches, base+string(name))
+			}
+		})
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// FormatValue renders a Lua value for display, expanding tables up to the given depth
+func FormatValue(value lua.LValue, depth int) string {
+	switch v := value.(type) {
+	case lua.LString:
+		return fmt.Sprintf("%q", string(v))
+	case *lua.LTable:
+		if depth <= 0 {
+			return "{...}"
+		}
+		parts := make([]string, 0)
+		v.ForEach(func(key, val lua.LValue) {
+			if len(parts) < 8 {
+				parts = append(parts, fmt.Sprintf("%s=%s", key.String(), FormatValue(val, depth-1)))
+			} else if len(parts) == 8 {
+				parts = append(parts, "...")
+			}
+		})
+		return "{" + strings.Join(parts, ", ") + "}"
+	default:
+		return value.String()
+	}
+}
@@ -0,0 +1,75 @@
+package scripting
+
+import (
+	lua "github.com/yuin/gopher-lua"
+
+	"deepthinking.do/luengo/engine/entity"
+	"deepthinking.do/luengo/engine/render"
+)
+
+// RegisterLayerFunctions exposes the render layers to Lua as the layers table.
+// The default layer is named "".
+func (sm *Manager) RegisterLayerFunctions(layers *render.Layers, em *entity.Manager) {
+	L := sm.luaState
+	api := L.NewTable()
+
+	// layer looks up the layer named by argument n
+	layer := func(L *lua.LState, n int) *render.Layer {
+		l, ok := layers.Get(L.CheckString(n))
+		if !ok {
+			L.ArgError(n, "unknown layer "+L.CheckString(n))
+		}
+		return l
+	}
+
+	L.SetFuncs(api, map[string]lua.LGFunction{
+		// layers.add(name [, below]) adds a layer on top, or just below another layer
+		"add": func(L *lua.LState) int {
+			var err error
+			if L.GetTop() >= 2 {
+				_, err = layers.AddBelow(L.CheckString(1), L.CheckString(2))
+			} else {
+				_, err = layers.Add(L.CheckString(1))
+			}
+			if err != nil {
+				L.ArgError(1, err.Error())
+			}
+			return 0
+		},
+		// layers.list() returns the layer names from bottom to top
+		"list": func(L *lua.LState) int {
+			list := L.NewTable()
+			for _, l := range layers.All() {
+				list.Append(lua.LString(l.Name))
+			}
+			L.Push(list)
+			return 1
+		},
+		"set_visible": func(L *lua.LState) int {
+			layer(L, 1).Visible = L.CheckBool(2)
+			return 0
+		},
+		"is_visible": func(L *lua.LState) int {
+			L.Push(lua.LBool(layer(L, 1).Visible))
+			return 1
+		},
+		// layers.set_y_sort(name, on) draws entities lower on the screen over higher ones
+		"set_y_sort": func(L *lua.LState) int {
+			layer(L, 1).YSort = L.CheckBool(2)
+			return 0
+		},
+		// layers.assign(id, name [, z]) moves an entity to a layer, optionally setting its z-index
+		"assign": func(L *lua.LState) int {
+			e, ok := em.GetEntity(entity.ID(L.CheckInt(1)))
+			if !ok {
+				L.Push(lua.LFalse)
+				return 1
+			}
+			e.Layer = L.CheckString(2)
+			e.Z = L.OptInt(3, e.Z)
+			L.Push(lua.LTrue)
+			return 1
+		},
+	})
+	L.SetGlobal("layers", api)
+}
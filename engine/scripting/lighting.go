@@ -0,0 +1,176 @@
+package scripting
+
+import (
+	"image/color"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"deepthinking.do/luengo/engine/entity"
+	"deepthinking.do/luengo/engine/render"
+)
+
+// RegisterLightingFunctions exposes lighting to Lua as the lighting table: the
+// ambient colour and its day/night curve, lights attached to entities and the
+// occluders that cast their shadows
+func (sm *Manager) RegisterLightingFunctions(lighting *render.Lighting, em *entity.Manager) {
+	L := sm.luaState
+	api := L.NewTable()
+
+	// light runs fn on the light of the entity whose id is the first argument,
+	// returning false when the entity does not exist or has no light
+	light := func(fn func(L *lua.LState, l *entity.Light)) lua.LGFunction {
+		return func(L *lua.LState) int {
+			e, ok := em.GetEntity(entity.ID(L.CheckInt(1)))
+			if ok && e.Light != nil {
+				fn(L, e.Light)
+			}
+			L.Push(lua.LBool(ok && e.Light != nil))
+			return 1
+		}
+	}
+
+	L.SetFuncs(api, map[string]lua.LGFunction{
+		// lighting.enable(on) turns lighting on or off; it starts off
+		"enable": func(L *lua.LState) int {
+			lighting.Enabled = L.CheckBool(1)
+			return 0
+		},
+		"is_enabled": func(L *lua.LState) int {
+			L.Push(lua.LBool(lighting.Enabled))
+			return 1
+		},
+		// lighting.set_ambient(r, g, b) sets the colour of unlit areas and drops any curve
+		"set_ambient": func(L *lua.LState) int {
+			lighting.Ambient = color.RGBA{R: channel(L, 1), G: channel(L, 2), B: channel(L, 3), A: 255}
+			lighting.Curve = nil
+			return 0
+		},
+		// lighting.get_ambient() returns the ambient colour now, after the curve and scale
+		"get_ambient": func(L *lua.LState) int {
+			ambient := lighting.CurrentAmbient()
+			L.Push(lua.LNumber(ambient.R))
+			L.Push(lua.LNumber(ambient.G))
+			L.Push(lua.LNumber(ambient.B))
+			return 3
+		},
+		// lighting.set_ambient_curve({{time, r, g, b}, ...} [, period]) blends the ambient
+		// colour between keys as the time set by set_time passes, repeating every period
+		"set_ambient_curve": func(L *lua.LState) int {
+			tbl := L.CheckTable(1)
+			var keys []render.AmbientKey
+			var bad bool
+			tbl.ForEach(func(_, value lua.LValue) {
+				key, ok := value.(*lua.LTable)
+				if !ok {
+					bad = true
+					return
+				}
+				number := func(i int) float64 {
+					n, ok := key.RawGetInt(i).(lua.LNumber)
+					if !ok {
+						bad = true
+					}
+					return float64(n)
+				}
+				channelAt := func(i int) uint8 { return uint8(clamp(number(i), 0, 255)) }
+				keys = append(keys, render.AmbientKey{
+					Time:  number(1),
+					Color: color.RGBA{R: channelAt(2), G: channelAt(3), B: channelAt(4), A: 255},
+				})
+			})
+			if bad || len(keys) == 0 {
+				L.ArgError(1, "expected a list of {time, r, g, b} keys")
+			}
+			lighting.Curve = render.NewAmbientCurve(keys, float64(L.OptNumber(2, 0)))
+			return 0
+		},
+		// lighting.set_time(t) moves the ambient curve to a time, e.g. minutes into the day
+		"set_time": func(L *lua.LState) int {
+			lighting.Time = float64(L.CheckNumber(1))
+			return 0
+		},
+		// lighting.set_ambient_scale(s) brightens or darkens the ambient colour, e.g. 0.6 in a storm
+		"set_ambient_scale": func(L *lua.LState) int {
+			lighting.AmbientScale = float64(L.CheckNumber(1))
+			return 0
+		},
+		// lighting.add_light(id [, options]) gives an entity a light, replacing any it has.
+		// options: kind ("point" or "spot"), radius, color = {r, g, b}, intensity, angle,
+		// spread, offset_x, offset_y, shadows.
+		"add_light": func(L *lua.LState) int {
+			e, ok := em.GetEntity(entity.ID(L.CheckInt(1)))
+			if !ok {
+				L.Push(lua.LFalse)
+				return 1
+			}
+			l := entity.NewLight(entity.LightPoint)
+			if options := L.OptTable(2, nil); options != nil {
+				if name, ok := options.RawGetString("kind").(lua.LString); ok {
+					kind, ok := entity.ParseLightKind(string(name))
+					if !ok {
+						L.ArgError(2, "unknown light kind "+string(name)+", expected point or spot")
+					}
+					l.Kind = kind
+				}
+				number := func(name string, value *float64) {
+					if n, ok := options.RawGetString(name).(lua.LNumber); ok {
+						*value = float64(n)
+					}
+				}
+				number("radius", &l.Radius)
+				number("intensity", &l.Intensity)
+				number("angle", &l.Angle)
+				number("spread", &l.Spread)
+				number("offset_x", &l.Offset.X)
+				number("offset_y", &l.Offset.Y)
+				if shadows, ok := options.RawGetString("shadows").(lua.LBool); ok {
+					l.Shadows = bool(shadows)
+				}
+				if c, ok := options.RawGetString("color").(*lua.LTable); ok {
+					rgb := [3]uint8{255, 255, 255}
+					for i := range rgb {
+						if n, ok := c.RawGetInt(i + 1).(lua.LNumber); ok {
+							rgb[i] = uint8(clamp(float64(n), 0, 255))
+						}
+					}
+					l.Color = color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 255}
+				}
+			}
+			e.Light = l
+			L.Push(lua.LTrue)
+			return 1
+		},
+		"remove_light": func(L *lua.LState) int {
+			e, ok := em.GetEntity(entity.ID(L.CheckInt(1)))
+			if ok {
+				e.Light = nil
+			}
+			L.Push(lua.LBool(ok))
+			return 1
+		},
+		// lighting.set_light_intensity(id, intensity), e.g. to flicker a torch
+		"set_light_intensity": light(func(L *lua.LState, l *entity.Light) {
+			l.Intensity = float64(L.CheckNumber(2))
+		}),
+		"set_light_radius": light(func(L *lua.LState, l *entity.Light) {
+			l.Radius = float64(L.CheckNumber(2))
+		}),
+		"set_light_color": light(func(L *lua.LState, l *entity.Light) {
+			l.Color = color.RGBA{R: channel(L, 2), G: channel(L, 3), B: channel(L, 4), A: 255}
+		}),
+		// lighting.set_light_angle(id, radians) points a spot light, relative to the entity's rotation
+		"set_light_angle": light(func(L *lua.LState, l *entity.Light) {
+			l.Angle = float64(L.CheckNumber(2))
+		}),
+		// lighting.set_occluder(id, on) makes the entity's sprite cast shadows
+		"set_occluder": func(L *lua.LState) int {
+			e, ok := em.GetEntity(entity.ID(L.CheckInt(1)))
+			if ok {
+				e.Occluder = L.CheckBool(2)
+			}
+			L.Push(lua.LBool(ok))
+			return 1
+		},
+	})
+	L.SetGlobal("lighting", api)
+}
@@ -0,0 +1,63 @@
+package scripting
+
+import (
+	lua "github.com/yuin/gopher-lua"
+
+	"deepthinking.do/luengo/engine/resources"
+)
+
+// SceneChanger switches scenes for change_scene, preloading in the background
+type SceneChanger interface {
+	ChangeScene(path, loadingScene string) error
+	SceneLoadProgress() (fraction float64, loading bool)
+}
+
+// RegisterLoadingFunctions exposes background preloading and scene changes to Lua
+func (sm *Manager) RegisterLoadingFunctions(rm *resources.Manager, scenes SceneChanger) {
+	L := sm.luaState
+
+	// preload(group, {paths...}) starts loading sprites in the background
+	L.SetGlobal("preload", L.NewFunction(func(L *lua.LState) int {
+		name := L.CheckString(1)
+		list := L.CheckTable(2)
+		paths := make([]string, 0, list.Len())
+		list.ForEach(func(_, value lua.LValue) {
+			if path, ok := value.(lua.LString); ok {
+				paths = append(paths, string(path))
+			}
+		})
+		rm.Preload(name, paths)
+		return 0
+	}))
+
+	// load_progress([group]) returns the fraction loaded and whether loading is done;
+	// without a group it reports the scene change in progress
+	L.SetGlobal("load_progress", L.NewFunction(func(L *lua.LState) int {
+		if L.GetTop() == 0 {
+			fraction, loading := scenes.SceneLoadProgress()
+			L.Push(lua.LNumber(fraction))
+			L.Push(lua.LBool(!loading))
+			return 2
+		}
+		g, ok := rm.Group(L.CheckString(1))
+		if !ok {
+			L.Push(lua.LNil)
+			return 1
+		}
+		L.Push(lua.LNumber(g.Fraction()))
+		L.Push(lua.LBool(g.Done()))
+		return 2
+	}))
+
+	// change_scene(path [, loading_scene]) shows the loading scene while the target
+	// scene's sprites load, then switches to it
+	L.SetGlobal("change_scene", L.NewFunction(func(L *lua.LState) int {
+		if err := scenes.ChangeScene(L.CheckString(1), L.OptString(2, "")); err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+		L.Push(lua.LTrue)
+		return 1
+	}))
+}
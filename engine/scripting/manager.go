@@ -1,107 +1,389 @@
 package scripting
 
 import (
+	"bytes"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"path"
+	"strings"
 
-	"github.com/hajimehoshi/ebiten/v2"
 	lua "github.com/yuin/gopher-lua"
 
 	"deepthinking.do/luengo/engine/audio"
+	"deepthinking.do/luengo/engine/debugger"
 	"deepthinking.do/luengo/engine/entity"
 	"deepthinking.do/luengo/engine/input"
+	"deepthinking.do/luengo/engine/logging"
+	"deepthinking.do/luengo/engine/luatest"
+	"deepthinking.do/luengo/engine/prefab"
+	"deepthinking.do/luengo/engine/resources"
+	"deepthinking.do/luengo/engine/vfs"
 )
 
 type Manager struct {
 	luaState     *lua.LState
+	files        fs.FS
 	audioManager *audio.Manager
+	inputManager *input.Manager
+	player       *entity.Entity
+	inputBlocked bool
+	mods         []*Mod
+	maxFailures  int
+	debugger     *debugger.Debugger
+	resources    *resources.Manager                 // Caches compiled scripts when set
+	scripts      map[string]*resources.ScriptHandle // Last compiled form of each loaded file
+	gameTime     float64                            // Seconds of game time, advanced by the engine before each on_update
+	drawing      *drawState                         // Set while on_draw runs
+	consoleEnv   *lua.LTable                        // Globals of console chunks, created on first use
 }
 
-func NewManager(audioManager *audio.Manager) *Manager {
-	return &Manager{
+// AdvanceTime moves the clock read by game_time forward
+func (sm *Manager) AdvanceTime(seconds float64) {
+	sm.gameTime += seconds
+}
+
+// NewManager creates a script manager that loads scripts, and modules found by
+// require, from files
+func NewManager(audioManager *audio.Manager, inputManager *input.Manager, files fs.FS) *Manager {
+	sm := &Manager{
 		luaState:     lua.NewState(),
+		files:        files,
 		audioManager: audioManager,
+		inputManager: inputManager,
+		maxFailures:  DefaultMaxFailures,
 	}
+	sm.replaceFileLoader()
+	return sm
 }
 
 func (sm *Manager) Close() {
 	if sm.luaState != nil {
 		sm.luaState.Close()
 	}
+	for _, h := range sm.scripts {
+		h.Release()
+	}
+	clear(sm.scripts)
+}
+
+// SetResources makes scripts and modules load through the resource cache, so each
+// file is compiled once however many times it is loaded
+func (sm *Manager) SetResources(rm *resources.Manager) {
+	sm.resources = rm
+	sm.scripts = make(map[string]*resources.ScriptHandle)
 }
 
 func (sm *Manager) GetLuaState() *lua.LState {
 	return sm.luaState
 }
 
+// SetInputBlocked makes is_key_pressed report false, e.g. while the console has focus
+func (sm *Manager) SetInputBlocked(blocked bool) {
+	sm.inputBlocked = blocked
+}
+
+// SetPlayer changes the entity moved by move_player
+func (sm *Manager) SetPlayer(player *entity.Entity) {
+	sm.player = player
+}
+
 func (sm *Manager) RegisterGameFunctions(em *entity.Manager, player *entity.Entity) {
 	L := sm.luaState
+	sm.player = player
 
 	L.SetGlobal("log", L.NewFunction(func(L *lua.LState) int {
 		msg := L.ToString(1)
-		fmt.Println("[Lua]:", msg)
+		logging.Infof(luaSource(L), "%s", msg)
 		return 0
 	}))
 
 	L.SetGlobal("debug", L.NewFunction(func(L *lua.LState) int {
 		val := L.ToString(1)
-		fmt.Println("[DEBUG]:", val)
+		logging.Debugf(luaSource(L), "%s", val)
+		return 0
+	}))
+
+	L.SetGlobal("print", L.NewFunction(func(L *lua.LState) int {
+		parts := make([]string, L.GetTop())
+		for i := range parts {
+			parts[i] = L.ToStringMeta(L.Get(i + 1)).String()
+		}
+		logging.Infof(luaSource(L), "%s", strings.Join(parts, "\t"))
 		return 0
 	}))
 
 	L.SetGlobal("emit", L.NewFunction(func(L *lua.LState) int {
 		event := L.ToString(1)
 		payload := L.ToString(2)
-		fmt.Printf("[Event] %s -> %s\n", event, payload)
+		logging.Debugf(luaSource(L), "Event %s -> %s", event, payload)
 		return 0
 	}))
 
 	L.SetGlobal("play_sound", L.NewFunction(func(L *lua.LState) int {
 		path := L.ToString(1)
 		if err := sm.audioManager.PlaySound(path); err != nil {
-			fmt.Printf("[Audio Error]: %v\n", err)
+			logging.Errorf("audio", "%v", err)
 		}
 		return 0
 	}))
 
 	L.SetGlobal("is_key_pressed", L.NewFunction(func(L *lua.LState) int {
 		key := L.ToString(1)
-		pressed := ebiten.IsKeyPressed(input.KeyFromString(key))
+		pressed := !sm.inputBlocked && sm.inputManager.IsKeyPressed(input.KeyFromString(key))
 		L.Push(lua.LBool(pressed))
 		return 1
 	}))
 
+	L.SetGlobal("get_player_position", L.NewFunction(func(L *lua.LState) int {
+		if sm.player == nil {
+			return 0
+		}
+		x, y := sm.player.WorldPosition()
+		L.Push(lua.LNumber(x))
+		L.Push(lua.LNumber(y))
+		return 2
+	}))
+
+	L.SetGlobal("game_time", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LNumber(sm.gameTime))
+		return 1
+	}))
+
+	L.SetGlobal("get_mod", L.NewFunction(func(L *lua.LState) int {
+		m, ok := sm.FindMod(L.CheckString(1))
+		if !ok {
+			L.Push(lua.LNil)
+			return 1
+		}
+		L.Push(m.Env)
+		return 1
+	}))
+
 	L.SetGlobal("move_player", L.NewFunction(func(L *lua.LState) int {
+		if sm.player == nil {
+			return 0
+		}
 		dx := L.ToNumber(1)
 		dy := L.ToNumber(2)
-		player.Position.X += float64(dx)
-		player.Position.Y += float64(dy)
+		sm.player.Position.X += float64(dx)
+		sm.player.Position.Y += float64(dy)
 		return 0
 	}))
 }
 
+// RegisterPrefabFunctions exposes prefab instantiation to Lua
+func (sm *Manager) RegisterPrefabFunctions(pm *prefab.Manager) {
+	L := sm.luaState
+
+	L.SetGlobal("spawn_prefab", L.NewFunction(func(L *lua.LState) int {
+		name := L.CheckString(1)
+		x := float64(L.OptNumber(2, 0))
+		y := float64(L.OptNumber(3, 0))
+		e, err := pm.Instantiate(name, x, y)
+		if err != nil {
+			logging.Errorf("prefab", "%v", err)
+			L.Push(lua.LNil)
+			return 1
+		}
+		L.Push(lua.LNumber(e.ID))
+		return 1
+	}))
+}
+
+// LoadScriptsFromFolder runs every script in a folder, grouping them into mods by
+// top-level folder. Scripts can require modules relative to the folder. Test files
+// are skipped; the test runner loads them.
 func (sm *Manager) LoadScriptsFromFolder(folder string) error {
-	return filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+	folder = vfs.Clean(folder)
+	sm.addPackagePath(folder)
+	return fs.WalkDir(sm.files, folder, func(file string, d fs.DirEntry, err error) error {
 		if err != nil {
-			fmt.Printf("[Mod Walk Error]: %v\n", err)
+			logging.Warnf("mod", "Walk error: %v", err)
 			return nil
 		}
-		if !info.IsDir() && filepath.Ext(path) == ".lua" {
-			fmt.Printf("[Mod] Loading: %s\n", path)
-			if err := sm.luaState.DoFile(path); err != nil {
-				fmt.Printf("[Lua Error]: %v\n", err)
+		if !d.IsDir() && path.Ext(file) == ".lua" && !strings.HasSuffix(file, luatest.FileSuffix) {
+			m := sm.mod(modID(folder, file))
+			m.Files = append(m.Files, file)
+			logging.Infof("mod", "Loading: %s (mod %s)", file, m.ID)
+			if err := sm.loadFile(m, file); err != nil {
+				sm.fail(m, err)
 			}
 		}
 		return nil
 	})
 }
 
-func (sm *Manager) CallFunction(functionName string) error {
-	if fn := sm.luaState.GetGlobal(functionName); fn.Type() == lua.LTFunction {
+// loadFile runs a script inside its mod's environment
+func (sm *Manager) loadFile(m *Mod, path string) *ScriptError {
+	L := sm.luaState
+	fn, err := sm.compile(path)
+	if err != nil {
+		return newScriptError(m.ID, "load", path, err)
+	}
+	fn.Env = m.Env
+	L.Push(fn)
+	if err := L.PCall(0, 1, nil); err != nil {
+		return newScriptError(m.ID, "load", path, err)
+	}
+	// A script that returns a module is what require gives for it too, so other
+	// scripts and tests share the copy the game runs
+	if module := L.Get(-1); module != lua.LNil {
+		sm.registerModule(path, module)
+	}
+	L.Pop(1)
+	return nil
+}
+
+// LoadScript compiles a script from the manager's files as mods are compiled,
+// without running it, e.g. for a test file
+func (sm *Manager) LoadScript(file string) (*lua.LFunction, error) {
+	return sm.compile(vfs.Clean(file))
+}
+
+// registerModule records a loaded script's module in package.loaded under the name
+// require finds the file by, unless something already required it
+func (sm *Manager) registerModule(file string, module lua.LValue) {
+	pkg, ok := sm.luaState.GetGlobal("package").(*lua.LTable)
+	if !ok {
+		return
+	}
+	loaded, ok := pkg.RawGetString("loaded").(*lua.LTable)
+	if !ok {
+		return
+	}
+	for _, pattern := range strings.Split(lua.LVAsString(pkg.RawGetString("path")), ";") {
+		prefix, suffix, ok := strings.Cut(pattern, "?")
+		if !ok || !strings.HasPrefix(file, prefix) || !strings.HasSuffix(file, suffix) || len(file) <= len(prefix)+len(suffix) {
+			continue
+		}
+		name := strings.ReplaceAll(file[len(prefix):len(file)-len(suffix)], "/", ".")
+		if loaded.RawGetString(name) == lua.LNil {
+			loaded.RawSetString(name, module)
+		}
+		return
+	}
+}
+
+// compile loads a script, instrumented for the debugger when one is attached and
+// otherwise through the resource cache if there is one
+func (sm *Manager) compile(file string) (*lua.LFunction, error) {
+	if sm.debugger == nil && sm.resources != nil {
+		h, err := sm.resources.AcquireScript(file)
+		if err != nil {
+			return nil, err
+		}
+		// Hold the newest compile of each file, so reloading a mod takes no extra references
+		sm.scripts[file].Release()
+		sm.scripts[file] = h
+		return sm.luaState.NewFunctionFromProto(h.Get()), nil
+	}
+	data, err := fs.ReadFile(sm.files, file)
+	if err != nil {
+		return nil, err
+	}
+	if sm.debugger != nil {
+		return sm.debugger.Compile(sm.luaState, bytes.NewReader(data), file)
+	}
+	return sm.luaState.Load(bytes.NewReader(data), file)
+}
+
+// EnableDebugger attaches a debugger. Call it before loading scripts: only scripts
+// loaded afterwards, including modules found by require, can hit breakpoints.
+func (sm *Manager) EnableDebugger(d *debugger.Debugger) {
+	sm.debugger = d
+	d.Attach(sm.luaState)
+}
+
+// replaceFileLoader makes require read modules from the manager's files, compiled
+// like every other script
+func (sm *Manager) replaceFileLoader() {
+	L := sm.luaState
+	pkg, ok := L.GetGlobal("package").(*lua.LTable)
+	if !ok {
+		return
+	}
+	loaders, ok := pkg.RawGetString("loaders").(*lua.LTable)
+	if !ok {
+		return
+	}
+	loaders.RawSetInt(2, L.NewFunction(func(L *lua.LState) int {
+		name := L.CheckString(1)
+		file, ok := sm.findModule(lua.LVAsString(pkg.RawGetString("path")), name)
+		if !ok {
+			L.Push(lua.LString(fmt.Sprintf("no file for module '%s' in package.path", name)))
+			return 1
+		}
+		fn, err := sm.compile(file)
+		if err != nil {
+			L.RaiseError("%v", err)
+		}
+		L.Push(fn)
+		return 1
+	}))
+}
+
+// findModule resolves a module name against a package.path template list
+func (sm *Manager) findModule(searchPath, name string) (string, bool) {
+	name = strings.ReplaceAll(name, ".", "/")
+	for _, pattern := range strings.Split(searchPath, ";") {
+		file := strings.ReplaceAll(pattern, "?", name)
+		if !fs.ValidPath(file) {
+			continue
+		}
+		if info, err := fs.Stat(sm.files, file); err == nil && !info.IsDir() {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// addPackagePath lets require find modules inside folder
+func (sm *Manager) addPackagePath(folder string) {
+	pkg, ok := sm.luaState.GetGlobal("package").(*lua.LTable)
+	if !ok {
+		return
+	}
+	searchPath := folder + "/?.lua;" + folder + "/?/init.lua;" + lua.LVAsString(pkg.RawGetString("path"))
+	pkg.RawSetString("path", lua.LString(searchPath))
+}
+
+// luaSource returns the log source for the Lua code calling into Go, e.g. "lua:mod/player/init.lua"
+func luaSource(L *lua.LState) string {
+	where := strings.TrimSuffix(L.Where(1), ":")
+	if i := strings.LastIndex(where, ":"); i > 0 {
+		return "lua:" + where[:i]
+	}
+	return "lua"
+}
+
+// CallFunction calls a lifecycle hook such as on_update in every enabled mod that
+// defines it. Errors are logged with their location and traceback, and a mod that
+// keeps failing is disabled while the others keep running.
+func (sm *Manager) CallFunction(functionName string) []*ScriptError {
+	return sm.callHook(functionName, nil)
+}
+
+// callHook is CallFunction, running before, if set, ahead of each mod's call
+func (sm *Manager) callHook(functionName string, before func(m *Mod)) []*ScriptError {
+	var errs []*ScriptError
+	for _, m := range sm.mods {
+		if m.Disabled {
+			continue
+		}
+		fn, ok := m.Env.RawGetString(functionName).(*lua.LFunction)
+		if !ok {
+			continue
+		}
+		if before != nil {
+			before(m)
+		}
 		if err := sm.luaState.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true}); err != nil {
-			return fmt.Errorf("[Lua Error][%s]: %v", functionName, err)
+			se := newScriptError(m.ID, functionName, "", err)
+			sm.fail(m, se)
+			errs = append(errs, se)
+			continue
 		}
+		m.Failures = 0
 	}
-	return nil
+	return errs
 }
@@ -0,0 +1,111 @@
+package scripting
+
+import (
+	"fmt"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"deepthinking.do/luengo/engine/logging"
+)
+
+// DefaultMaxFailures is how many consecutive errors a mod may raise before it is disabled
+const DefaultMaxFailures = 3
+
+// Mod is a group of scripts sharing one Lua environment: a top-level folder of the
+// mod root, or a single top-level script. Globals a mod defines, including its
+// lifecycle hooks, live in its environment so mods cannot overwrite each other.
+type Mod struct {
+	ID        string
+	Files     []string
+	Env       *lua.LTable
+	Failures  int // Consecutive failures, reset by a successful call
+	Disabled  bool
+	LastError *ScriptError
+}
+
+// modID returns the id of the mod a script belongs to
+func modID(root, file string) string {
+	rel := strings.TrimPrefix(file, root+"/")
+	if root == "." {
+		rel = file
+	}
+	parts := strings.Split(rel, "/")
+	if len(parts) == 1 {
+		return strings.TrimSuffix(parts[0], ".lua")
+	}
+	return parts[0]
+}
+
+// mod returns the mod with the given id, creating it and its environment on first use
+func (sm *Manager) mod(id string) *Mod {
+	for _, m := range sm.mods {
+		if m.ID == id {
+			return m
+		}
+	}
+
+	L := sm.luaState
+	env := L.NewTable()
+	meta := L.NewTable()
+	meta.RawSetString("__index", L.Get(lua.GlobalsIndex))
+	L.SetMetatable(env, meta)
+
+	m := &Mod{ID: id, Env: env}
+	sm.mods = append(sm.mods, m)
+	return m
+}
+
+// Mods returns the loaded mods in load order
+func (sm *Manager) Mods() []*Mod {
+	return sm.mods
+}
+
+// FindMod returns the mod with the given id
+func (sm *Manager) FindMod(id string) (*Mod, bool) {
+	for _, m := range sm.mods {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// SetMaxFailures sets how many consecutive errors disable a mod; 0 never disables
+func (sm *Manager) SetMaxFailures(n int) {
+	sm.maxFailures = n
+}
+
+// EnableMod re-enables a disabled mod and clears its failure count
+func (sm *Manager) EnableMod(id string) error {
+	m, ok := sm.FindMod(id)
+	if !ok {
+		return fmt.Errorf("unknown mod: %s", id)
+	}
+	m.Disabled = false
+	m.Failures = 0
+	logging.Infof("mod", "Enabled mod %s", id)
+	return nil
+}
+
+// DisableMod stops calling a mod's hooks
+func (sm *Manager) DisableMod(id string) error {
+	m, ok := sm.FindMod(id)
+	if !ok {
+		return fmt.Errorf("unknown mod: %s", id)
+	}
+	m.Disabled = true
+	logging.Warnf("mod", "Disabled mod %s", id)
+	return nil
+}
+
+// fail records an error raised by a mod and disables the mod once it keeps failing
+func (sm *Manager) fail(m *Mod, err *ScriptError) {
+	err.log()
+	m.LastError = err
+	m.Failures++
+	if sm.maxFailures > 0 && m.Failures >= sm.maxFailures && !m.Disabled {
+		m.Disabled = true
+		logging.Errorf("mod", "Disabled mod %s after %d consecutive errors; other mods keep running (console: mod enable %s)", m.ID, m.Failures, m.ID)
+	}
+}
@@ -0,0 +1,126 @@
+package scripting
+
+import (
+	lua "github.com/yuin/gopher-lua"
+
+	"deepthinking.do/luengo/engine/entity"
+	"deepthinking.do/luengo/engine/particles"
+)
+
+// RegisterParticleFunctions exposes particle effects to Lua as the particles table.
+// Effects are named by their definition file without the extension; emitters are
+// referred to by the id spawn, burst and attach return.
+func (sm *Manager) RegisterParticleFunctions(pm *particles.Manager, em *entity.Manager) {
+	L := sm.luaState
+	api := L.NewTable()
+
+	// started pushes an emitter's id, or nil and the error
+	started := func(L *lua.LState, e *particles.Emitter, err error) int {
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+		L.Push(lua.LNumber(e.ID))
+		return 1
+	}
+	// with runs fn on the emitter whose id is the first argument, returning false
+	// when it has finished or been removed
+	with := func(fn func(L *lua.LState, e *particles.Emitter)) lua.LGFunction {
+		return func(L *lua.LState) int {
+			e, ok := pm.Emitter(L.CheckInt(1))
+			if ok {
+				fn(L, e)
+			}
+			L.Push(lua.LBool(ok))
+			return 1
+		}
+	}
+
+	L.SetFuncs(api, map[string]lua.LGFunction{
+		// particles.spawn(name, x, y) starts an emitter at a world position
+		"spawn": func(L *lua.LState) int {
+			e, err := pm.Spawn(L.CheckString(1), float64(L.CheckNumber(2)), float64(L.CheckNumber(3)))
+			return started(L, e, err)
+		},
+		// particles.burst(name, x, y) plays an effect once, e.g. when an enemy dies
+		"burst": func(L *lua.LState) int {
+			e, err := pm.Burst(L.CheckString(1), float64(L.CheckNumber(2)), float64(L.CheckNumber(3)))
+			return started(L, e, err)
+		},
+		// particles.attach(id, name [, offset_x, offset_y]) makes an entity emit an effect
+		// from its centre, replacing any it emits
+		"attach": func(L *lua.LState) int {
+			owner, ok := em.GetEntity(entity.ID(L.CheckInt(1)))
+			if !ok {
+				L.Push(lua.LNil)
+				L.Push(lua.LString("unknown entity"))
+				return 2
+			}
+			offset := entity.Vec2{X: float64(L.OptNumber(3, 0)), Y: float64(L.OptNumber(4, 0))}
+			e, err := pm.Attach(owner, L.CheckString(2), offset)
+			return started(L, e, err)
+		},
+		// particles.detach(id) stops an entity's effect; its particles fade out
+		"detach": func(L *lua.LState) int {
+			owner, ok := em.GetEntity(entity.ID(L.CheckInt(1)))
+			if ok {
+				pm.Detach(owner)
+			}
+			L.Push(lua.LBool(ok))
+			return 1
+		},
+		// particles.stop(emitter) stops emitting; live particles finish
+		"stop": with(func(L *lua.LState, e *particles.Emitter) {
+			e.Playing = false
+		}),
+		// particles.play(emitter) plays a stopped emitter from the beginning
+		"play": with(func(L *lua.LState, e *particles.Emitter) {
+			e.Restart()
+		}),
+		// particles.remove(emitter) removes an emitter and its particles at once
+		"remove": func(L *lua.LState) int {
+			L.Push(lua.LBool(pm.Remove(L.CheckInt(1))))
+			return 1
+		},
+		// particles.emit(emitter, count) spawns extra particles at once
+		"emit": with(func(L *lua.LState, e *particles.Emitter) {
+			e.Emit(L.CheckInt(2))
+		}),
+		"set_position": with(func(L *lua.LState, e *particles.Emitter) {
+			e.X, e.Y = float64(L.CheckNumber(2)), float64(L.CheckNumber(3))
+		}),
+		// particles.set_angle(emitter, radians) turns the emitter's shape and direction
+		"set_angle": with(func(L *lua.LState, e *particles.Emitter) {
+			e.Angle = float64(L.CheckNumber(2))
+		}),
+		// particles.set_rate(emitter, rate) sets the particles emitted per second
+		"set_rate": with(func(L *lua.LState, e *particles.Emitter) {
+			e.Rate = float64(L.CheckNumber(2))
+		}),
+		// particles.count([emitter]) returns the live particles of an emitter, or of all of them
+		"count": func(L *lua.LState) int {
+			if L.GetTop() == 0 {
+				L.Push(lua.LNumber(pm.Count()))
+				return 1
+			}
+			e, ok := pm.Emitter(L.CheckInt(1))
+			if !ok {
+				L.Push(lua.LNumber(0))
+				return 1
+			}
+			L.Push(lua.LNumber(e.Alive()))
+			return 1
+		},
+		// particles.list() returns the effect names in alphabetical order
+		"list": func(L *lua.LState) int {
+			list := L.NewTable()
+			for _, name := range pm.Names() {
+				list.Append(lua.LString(name))
+			}
+			L.Push(list)
+			return 1
+		},
+	})
+	L.SetGlobal("particles", api)
+}
@@ -0,0 +1,156 @@
+package scripting
+
+import (
+	lua "github.com/yuin/gopher-lua"
+
+	"deepthinking.do/luengo/engine/entity"
+	"deepthinking.do/luengo/engine/render"
+	"deepthinking.do/luengo/engine/resources"
+)
+
+// RegisterShaderFunctions exposes Kage shaders to Lua: the material table draws
+// entities through a shader and the post table runs full-screen effects over the
+// game view. Shaders can use the Time uniform, and post effects Resolution.
+func (sm *Manager) RegisterShaderFunctions(rm *resources.Manager, em *entity.Manager, post *render.PostChain) {
+	L := sm.luaState
+	handles := map[string]*resources.ShaderHandle{} // Post effect shaders by effect name
+
+	material := L.NewTable()
+	L.SetFuncs(material, map[string]lua.LGFunction{
+		// material.set(id, shader_path) draws the entity through a shader, keeping the
+		// uniforms already set
+		"set": func(L *lua.LState) int {
+			e, ok := em.GetEntity(entity.ID(L.CheckInt(1)))
+			if !ok {
+				L.Push(lua.LNil)
+				L.Push(lua.LString("unknown entity"))
+				return 2
+			}
+			path := L.CheckString(2)
+			shader, err := rm.LoadShader(path)
+			if err != nil {
+				L.Push(lua.LNil)
+				L.Push(lua.LString(err.Error()))
+				return 2
+			}
+			if e.Material == nil {
+				e.Material = entity.NewMaterial(shader, path)
+			} else {
+				e.Material.Shader, e.Material.ShaderPath = shader, path
+			}
+			L.Push(lua.LTrue)
+			return 1
+		},
+		"clear": func(L *lua.LState) int {
+			if e, ok := em.GetEntity(entity.ID(L.CheckInt(1))); ok {
+				e.Material = nil
+			}
+			return 0
+		},
+		// material.get_shader(id) returns the entity's shader path, or nil
+		"get_shader": func(L *lua.LState) int {
+			e, ok := em.GetEntity(entity.ID(L.CheckInt(1)))
+			if !ok || e.Material == nil {
+				L.Push(lua.LNil)
+				return 1
+			}
+			L.Push(lua.LString(e.Material.ShaderPath))
+			return 1
+		},
+		// material.set_uniform(id, name, value) sets a uniform to a number or a list of
+		// numbers, e.g. {1, 0.5, 0.5, 1} for a vec4
+		"set_uniform": func(L *lua.LState) int {
+			e, ok := em.GetEntity(entity.ID(L.CheckInt(1)))
+			if !ok || e.Material == nil {
+				L.Push(lua.LFalse)
+				return 1
+			}
+			e.Material.Uniforms[L.CheckString(2)] = uniformArg(L, 3)
+			L.Push(lua.LTrue)
+			return 1
+		},
+	})
+	L.SetGlobal("material", material)
+
+	api := L.NewTable()
+	L.SetFuncs(api, map[string]lua.LGFunction{
+		// post.add(name, shader_path) appends a post-processing effect to the chain
+		"add": func(L *lua.LState) int {
+			name, path := L.CheckString(1), L.CheckString(2)
+			handle, err := rm.AcquireShader(path)
+			if err != nil {
+				L.Push(lua.LNil)
+				L.Push(lua.LString(err.Error()))
+				return 2
+			}
+			if _, err := post.Add(name, path, handle.Get()); err != nil {
+				handle.Release()
+				L.Push(lua.LNil)
+				L.Push(lua.LString(err.Error()))
+				return 2
+			}
+			handles[name] = handle
+			L.Push(lua.LTrue)
+			return 1
+		},
+		"remove": func(L *lua.LState) int {
+			name := L.CheckString(1)
+			removed := post.Remove(name)
+			if handle, ok := handles[name]; ok {
+				handle.Release()
+				delete(handles, name)
+			}
+			L.Push(lua.LBool(removed))
+			return 1
+		},
+		"set_enabled": func(L *lua.LState) int {
+			effect, ok := post.Get(L.CheckString(1))
+			if ok {
+				effect.Enabled = L.CheckBool(2)
+			}
+			L.Push(lua.LBool(ok))
+			return 1
+		},
+		// post.set_uniform(name, uniform, value) sets an effect's uniform to a number or a
+		// list of numbers
+		"set_uniform": func(L *lua.LState) int {
+			effect, ok := post.Get(L.CheckString(1))
+			if ok {
+				effect.Uniforms[L.CheckString(2)] = uniformArg(L, 3)
+			}
+			L.Push(lua.LBool(ok))
+			return 1
+		},
+		// post.list() returns the effect names in the order they run
+		"list": func(L *lua.LState) int {
+			list := L.NewTable()
+			for _, effect := range post.Effects() {
+				list.Append(lua.LString(effect.Name))
+			}
+			L.Push(list)
+			return 1
+		},
+	})
+	L.SetGlobal("post", api)
+}
+
+// uniformArg reads a uniform value argument: a number or a table of numbers
+func uniformArg(L *lua.LState, n int) interface{} {
+	switch v := L.Get(n).(type) {
+	case lua.LNumber:
+		return float64(v)
+	case *lua.LTable:
+		values := make([]float64, 0, v.Len())
+		for i := 1; i <= v.Len(); i++ {
+			number, ok := v.RawGetInt(i).(lua.LNumber)
+			if !ok {
+				L.ArgError(n, "uniform lists hold numbers only")
+			}
+			values = append(values, float64(number))
+		}
+		return values
+	default:
+		L.ArgError(n, "uniform must be a number or a list of numbers")
+		return nil
+	}
+}
@@ -0,0 +1,168 @@
+package scripting
+
+import (
+	"image/color"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"deepthinking.do/luengo/engine/entity"
+	"deepthinking.do/luengo/engine/resources"
+)
+
+// RegisterSpriteFunctions exposes entity transforms and appearance to Lua as the
+// sprite table. Every function takes an entity id first; setters return false when
+// the entity does not exist and getters return nil.
+func (sm *Manager) RegisterSpriteFunctions(rm *resources.Manager, em *entity.Manager) {
+	L := sm.luaState
+	api := L.NewTable()
+
+	// with runs fn on the entity whose id is the first argument
+	with := func(fn func(L *lua.LState, e *entity.Entity) int) lua.LGFunction {
+		return func(L *lua.LState) int {
+			e, ok := em.GetEntity(entity.ID(L.CheckInt(1)))
+			if !ok {
+				L.Push(lua.LNil)
+				return 1
+			}
+			return fn(L, e)
+		}
+	}
+	// set wraps a setter so it returns true once applied
+	set := func(fn func(L *lua.LState, e *entity.Entity)) lua.LGFunction {
+		return func(L *lua.LState) int {
+			e, ok := em.GetEntity(entity.ID(L.CheckInt(1)))
+			if ok {
+				fn(L, e)
+			}
+			L.Push(lua.LBool(ok))
+			return 1
+		}
+	}
+	number := func(L *lua.LState, n int) float64 {
+		return float64(L.CheckNumber(n))
+	}
+
+	L.SetFuncs(api, map[string]lua.LGFunction{
+		"get_rotation": with(func(L *lua.LState, e *entity.Entity) int {
+			L.Push(lua.LNumber(e.Rotation))
+			return 1
+		}),
+		// sprite.set_rotation(id, radians) turns the entity around its pivot
+		"set_rotation": set(func(L *lua.LState, e *entity.Entity) {
+			e.Rotation = number(L, 2)
+		}),
+		"get_scale": with(func(L *lua.LState, e *entity.Entity) int {
+			L.Push(lua.LNumber(e.Scale.X))
+			L.Push(lua.LNumber(e.Scale.Y))
+			return 2
+		}),
+		// sprite.set_scale(id, x [, y]) scales around the pivot; y defaults to x
+		"set_scale": set(func(L *lua.LState, e *entity.Entity) {
+			x := number(L, 2)
+			e.Scale = entity.Vec2{X: x, Y: float64(L.OptNumber(3, lua.LNumber(x)))}
+		}),
+		"get_pivot": with(func(L *lua.LState, e *entity.Entity) int {
+			L.Push(lua.LNumber(e.Pivot.X))
+			L.Push(lua.LNumber(e.Pivot.Y))
+			return 2
+		}),
+		// sprite.set_pivot(id, x, y) sets the point in the sprite that rotation and scale happen around
+		"set_pivot": set(func(L *lua.LState, e *entity.Entity) {
+			e.Pivot = entity.Vec2{X: number(L, 2), Y: number(L, 3)}
+		}),
+		"get_flip": with(func(L *lua.LState, e *entity.Entity) int {
+			L.Push(lua.LBool(e.FlipX))
+			L.Push(lua.LBool(e.FlipY))
+			return 2
+		}),
+		// sprite.set_flip(id, horizontal [, vertical]) mirrors the sprite
+		"set_flip": set(func(L *lua.LState, e *entity.Entity) {
+			e.FlipX = L.ToBool(2)
+			e.FlipY = L.ToBool(3)
+		}),
+		"get_tint": with(func(L *lua.LState, e *entity.Entity) int {
+			L.Push(lua.LNumber(e.Tint.R))
+			L.Push(lua.LNumber(e.Tint.G))
+			L.Push(lua.LNumber(e.Tint.B))
+			return 3
+		}),
+		// sprite.set_tint(id, r, g, b) multiplies the sprite's colours; 255, 255, 255 is none
+		"set_tint": set(func(L *lua.LState, e *entity.Entity) {
+			e.Tint = color.RGBA{R: channel(L, 2), G: channel(L, 3), B: channel(L, 4), A: 255}
+		}),
+		"get_alpha": with(func(L *lua.LState, e *entity.Entity) int {
+			L.Push(lua.LNumber(e.Alpha))
+			return 1
+		}),
+		// sprite.set_alpha(id, alpha) sets the opacity from 0 to 1, which children inherit
+		"set_alpha": set(func(L *lua.LState, e *entity.Entity) {
+			e.Alpha = clamp(number(L, 2), 0, 1)
+		}),
+		"get_blend": with(func(L *lua.LState, e *entity.Entity) int {
+			L.Push(lua.LString(e.Blend.String()))
+			return 1
+		}),
+		// sprite.set_blend(id, mode) sets the blend mode by name, e.g. "add"
+		"set_blend": set(func(L *lua.LState, e *entity.Entity) {
+			name := L.CheckString(2)
+			mode, ok := entity.ParseBlendMode(name)
+			if !ok {
+				L.ArgError(2, "unknown blend mode "+name+", expected one of "+strings.Join(entity.BlendModeNames(), ", "))
+			}
+			e.Blend = mode
+		}),
+		// sprite.play(id, path) plays an animated GIF on the entity from its first frame,
+		// replacing its sprite; it returns nil and the error if the file cannot be loaded
+		"play": func(L *lua.LState) int {
+			e, ok := em.GetEntity(entity.ID(L.CheckInt(1)))
+			if !ok {
+				L.Push(lua.LNil)
+				L.Push(lua.LString("unknown entity"))
+				return 2
+			}
+			path := L.CheckString(2)
+			anim, err := rm.LoadAnimation(path)
+			if err != nil {
+				L.Push(lua.LNil)
+				L.Push(lua.LString(err.Error()))
+				return 2
+			}
+			e.Animation = &entity.Animation{Frames: anim, Path: path}
+			e.Sprite = anim.Frame(0)
+			L.Push(lua.LTrue)
+			return 1
+		},
+		// sprite.stop(id) stops the animation, leaving its current frame as the sprite
+		"stop": set(func(L *lua.LState, e *entity.Entity) {
+			e.Animation = nil
+		}),
+		// sprite.get_animation(id) returns the playing animation's path and the seconds it
+		// has played, or nil
+		"get_animation": with(func(L *lua.LState, e *entity.Entity) int {
+			if e.Animation == nil {
+				L.Push(lua.LNil)
+				return 1
+			}
+			L.Push(lua.LString(e.Animation.Path))
+			L.Push(lua.LNumber(e.Animation.Elapsed.Seconds()))
+			return 2
+		}),
+	})
+	L.SetGlobal("sprite", api)
+}
+
+// channel reads a colour channel argument from 0 to 255
+func channel(L *lua.LState, n int) uint8 {
+	return uint8(clamp(float64(L.CheckNumber(n)), 0, 255))
+}
+
+func clamp(value, min, max float64) float64 {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
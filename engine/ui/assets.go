@@ -0,0 +1,308 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font/basicfont"
+
+	"deepthinking.do/luengo/engine/render"
+	"deepthinking.do/luengo/engine/resources"
+)
+
+const (
+	assetPanelHeight = 130
+	assetTileWidth   = 76
+	assetThumbSize   = 48
+
+	// thumbnailGroup is the preload group holding the asset browser's images
+	thumbnailGroup = "editor:thumbnails"
+)
+
+// SpriteDrop is an image dragged from the asset browser and released over the viewport
+type SpriteDrop struct {
+	Path             string
+	ScreenX, ScreenY int
+}
+
+// assetBrowserState holds the asset browser panel state
+type assetBrowserState struct {
+	open       bool
+	root       string
+	assets     []resources.AssetInfo
+	thumbnails *resources.Group // Images of the listed assets, decoded in the background
+	scroll     int
+	mouseDown  bool
+	mouseX     int
+	mouseY     int
+	dragging   string
+	err        error
+
+	soundRequest    string
+	particleRequest string
+	previewing      string // Particle definition playing in the viewport
+	drop            *SpriteDrop
+}
+
+func newAssetBrowserState() assetBrowserState {
+	return assetBrowserState{root: "assets"}
+}
+
+// SetResources sets the resource manager the asset browser lists and previews from
+func (ui *EditorUI) SetResources(rm *resources.Manager) {
+	ui.resources = rm
+}
+
+// SetAssetRoot sets the folder the asset browser lists
+func (ui *EditorUI) SetAssetRoot(root string) {
+	ui.assets.root = root
+	if ui.assets.open {
+		ui.RefreshAssets()
+	}
+}
+
+func (ui *EditorUI) ToggleAssetBrowser() {
+	ui.assets.open = !ui.assets.open
+	if ui.assets.open {
+		ui.RefreshAssets()
+	} else if ui.resources != nil {
+		// Let the thumbnails be evicted while the browser is closed
+		ui.resources.ReleaseGroup(thumbnailGroup)
+		ui.assets.thumbnails = nil
+	}
+}
+
+func (ui *EditorUI) IsAssetBrowserOpen() bool {
+	return ui.assets.open
+}
+
+// RefreshAssets rescans the asset root
+func (ui *EditorUI) RefreshAssets() {
+	if ui.resources == nil {
+		return
+	}
+	ui.assets.assets, ui.assets.err = ui.resources.ListAssets(ui.assets.root)
+
+	// Thumbnails load through the cache on the loader's goroutines and appear as they
+	// finish; a new group replaces the old one
+	images := make([]string, 0, len(ui.assets.assets))
+	for _, asset := range ui.assets.assets {
+		if asset.Kind == resources.AssetImage {
+			images = append(images, asset.Path)
+		}
+	}
+	ui.assets.thumbnails = ui.resources.Preload(thumbnailGroup, images)
+}
+
+// TakeSoundPreview returns the sound clicked in the asset browser since the last call, if any
+func (ui *EditorUI) TakeSoundPreview() (string, bool) {
+	path := ui.assets.soundRequest
+	ui.assets.soundRequest = ""
+	return path, path != ""
+}
+
+// TakeParticlePreview returns the particle definition clicked in the asset browser since
+// the last call, if any
+func (ui *EditorUI) TakeParticlePreview() (string, bool) {
+	path := ui.assets.particleRequest
+	ui.assets.particleRequest = ""
+	return path, path != ""
+}
+
+// SetParticlePreview sets the particle definition shown as playing, or "" for none
+func (ui *EditorUI) SetParticlePreview(path string) {
+	ui.assets.previewing = path
+}
+
+// TakeSpriteDrop returns the image dropped onto the viewport since the last call, if any
+func (ui *EditorUI) TakeSpriteDrop() (SpriteDrop, bool) {
+	drop := ui.assets.drop
+	ui.assets.drop = nil
+	if drop == nil {
+		return SpriteDrop{}, false
+	}
+	return *drop, true
+}
+
+// assetPanelTop returns the top edge of the asset browser, above the log panel
+func assetPanelTop(screenHeight int) int {
+	return screenHeight - logPanelHeight - assetPanelHeight
+}
+
+func (ui *EditorUI) isInAssetBrowser(x, y, screenWidth, screenHeight int) bool {
+	return ui.assets.open && x >= 0 && x < ui.viewportWidth(screenWidth) &&
+		y >= assetPanelTop(screenHeight) && y < screenHeight-logPanelHeight
+}
+
+// assetAt returns the index of the tile under the given screen position, or -1
+func (ui *EditorUI) assetAt(x, y, screenHeight int) int {
+	top := assetPanelTop(screenHeight) + 22
+	if y < top || y >= top+assetThumbSize+30 {
+		return -1
+	}
+	index := (x - 10 + ui.assets.scroll) / assetTileWidth
+	if x-10+ui.assets.scroll < 0 || index >= len(ui.assets.assets) {
+		return -1
+	}
+	return index
+}
+
+// UpdateAssetBrowser handles scrolling, sound and particle previews and sprite dragging in the asset browser.
+// It returns true when the mouse is owned by the panel this frame.
+func (ui *EditorUI) UpdateAssetBrowser(mouseX, mouseY int, mouseDown bool, wheelY float64, screenWidth, screenHeight int) bool {
+	a := &ui.assets
+	justPressed := mouseDown && !a.mouseDown
+	justReleased := !mouseDown && a.mouseDown
+	a.mouseDown = mouseDown
+	a.mouseX, a.mouseY = mouseX, mouseY
+
+	if !a.open {
+		a.dragging = ""
+		return false
+	}
+
+	inPanel := ui.isInAssetBrowser(mouseX, mouseY, screenWidth, screenHeight)
+
+	if inPanel && wheelY != 0 {
+		maxScroll := len(a.assets)*assetTileWidth - ui.viewportWidth(screenWidth) + 20
+		a.scroll -= int(wheelY * assetTileWidth)
+		if a.scroll > maxScroll {
+			a.scroll = maxScroll
+		}
+		if a.scroll < 0 {
+			a.scroll = 0
+		}
+	}
+
+	if justPressed && inPanel {
+		if index := ui.assetAt(mouseX, mouseY, screenHeight); index >= 0 {
+			asset := a.assets[index]
+			switch asset.Kind {
+			case resources.AssetSound:
+				a.soundRequest = asset.Path
+			case resources.AssetParticles:
+				a.particleRequest = asset.Path
+			case resources.AssetImage:
+				a.dragging = asset.Path
+			}
+		}
+		return true
+	}
+
+	if a.dragging != "" {
+		if justReleased {
+			if !ui.IsMouseOverUI(mouseX, mouseY, screenWidth, screenHeight) {
+				a.drop = &SpriteDrop{Path: a.dragging, ScreenX: mouseX, ScreenY: mouseY}
+			}
+			a.dragging = ""
+		}
+		return true
+	}
+
+	return inPanel
+}
+
+// thumbnail returns the preview image for an asset, or nil while it is loading or
+// if it failed to load
+func (ui *EditorUI) thumbnail(path string) *ebiten.Image {
+	if ui.assets.thumbnails == nil {
+		return nil
+	}
+	thumb, _ := ui.assets.thumbnails.Sprite(path)
+	return thumb
+}
+
+// drawThumbnail draws an image scaled to fit a square of the given size
+func drawThumbnail(screen, img *ebiten.Image, x, y, size float64, alpha float32) {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	scale := size / float64(max(w, h))
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Scale(scale, scale)
+	opts.GeoM.Translate(x+(size-float64(w)*scale)/2, y+(size-float64(h)*scale)/2)
+	opts.ColorScale.ScaleAlpha(alpha)
+	screen.DrawImage(img, opts)
+}
+
+// DrawAssetBrowser draws the asset browser panel above the log panel
+func (ui *EditorUI) DrawAssetBrowser(screen *ebiten.Image, screenWidth, screenHeight int) {
+	a := &ui.assets
+	if !a.open || ui.resources == nil {
+		return
+	}
+
+	panelWidth := ui.viewportWidth(screenWidth)
+	top := assetPanelTop(screenHeight)
+
+	// Background
+	canvas := render.NewCanvas(screen)
+	canvas.AntiAlias = false
+	canvas.FillRect(0, float64(top), float64(panelWidth), assetPanelHeight, color.RGBA{30, 30, 40, 230})
+
+	loaded := 0
+	for _, asset := range a.assets {
+		if ui.resources.IsLoaded(asset.Path) {
+			loaded++
+		}
+	}
+	title := fmt.Sprintf("ASSETS (%s/)  %d files, %d cached  click: play sound or particles  drag: place sprite", a.root, len(a.assets), loaded)
+	text.Draw(screen, title, basicfont.Face7x13, 10, top+15, color.White)
+
+	if a.err != nil {
+		text.Draw(screen, a.err.Error(), basicfont.Face7x13, 10, top+40, color.RGBA{255, 100, 100, 255})
+		return
+	}
+
+	tileTop := top + 22
+	for i, asset := range a.assets {
+		x := 10 + i*assetTileWidth - a.scroll
+		if x+assetTileWidth < 0 || x > panelWidth {
+			continue
+		}
+
+		// Tile background, green when the asset is in the cache
+		tileColor := color.RGBA{50, 50, 60, 255}
+		if ui.resources.IsLoaded(asset.Path) {
+			tileColor = color.RGBA{40, 90, 50, 255}
+		}
+		canvas.FillRect(float64(x), float64(tileTop), assetThumbSize+8, assetThumbSize+8, tileColor)
+
+		switch asset.Kind {
+		case resources.AssetImage:
+			if thumb := ui.thumbnail(asset.Path); thumb != nil {
+				drawThumbnail(screen, thumb, float64(x+4), float64(tileTop+4), assetThumbSize, 1)
+			} else {
+				text.Draw(screen, "...", basicfont.Face7x13, x+20, tileTop+32, color.RGBA{150, 150, 150, 255})
+			}
+		case resources.AssetSound:
+			text.Draw(screen, "SOUND", basicfont.Face7x13, x+10, tileTop+26, color.RGBA{180, 200, 255, 255})
+			text.Draw(screen, "> play", basicfont.Face7x13, x+8, tileTop+42, color.RGBA{150, 150, 150, 255})
+		case resources.AssetParticles:
+			text.Draw(screen, "FX", basicfont.Face7x13, x+21, tileTop+26, color.RGBA{255, 200, 120, 255})
+			if asset.Path == a.previewing {
+				text.Draw(screen, "# stop", basicfont.Face7x13, x+8, tileTop+42, color.RGBA{120, 220, 120, 255})
+			} else {
+				text.Draw(screen, "> play", basicfont.Face7x13, x+8, tileTop+42, color.RGBA{150, 150, 150, 255})
+			}
+		default:
+			text.Draw(screen, "FILE", basicfont.Face7x13, x+14, tileTop+32, color.RGBA{150, 150, 150, 255})
+		}
+
+		name := asset.Name
+		if maxChars := assetTileWidth/7 - 1; len(name) > maxChars {
+			name = name[:maxChars]
+		}
+		text.Draw(screen, name, basicfont.Face7x13, x, tileTop+assetThumbSize+22, color.RGBA{200, 200, 200, 255})
+		if ui.resources.IsLoaded(asset.Path) {
+			text.Draw(screen, "cached", basicfont.Face7x13, x, tileTop+assetThumbSize+36, color.RGBA{120, 220, 120, 255})
+		}
+	}
+
+	// Sprite being dragged follows the cursor
+	if a.dragging != "" {
+		if thumb := ui.thumbnail(a.dragging); thumb != nil {
+			drawThumbnail(screen, thumb, float64(a.mouseX-assetThumbSize/2), float64(a.mouseY-assetThumbSize/2), assetThumbSize, 0.7)
+		}
+	}
+}
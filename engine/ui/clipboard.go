@@ -0,0 +1,58 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// clipboardTimeout is how long the clipboard tool may run; xclip can wait for a
+// selection owner indefinitely
+const clipboardTimeout = 2 * time.Second
+
+// copyToClipboardAsync copies text on another goroutine, so the tool never stalls a
+// frame, and sends the outcome on the returned channel
+func copyToClipboardAsync(text string) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- copyToClipboard(text)
+	}()
+	return done
+}
+
+// copyToClipboard copies text to the system clipboard using the platform's clipboard tool
+func copyToClipboard(text string) error {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "windows":
+		candidates = [][]string{{"clip"}}
+	case "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	default:
+		candidates = [][]string{
+			{"wl-copy"},
+			{"xclip", "-selection", "clipboard"},
+			{"xsel", "--clipboard", "--input"},
+		}
+	}
+
+	for _, args := range candidates {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		err := cmd.Run()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%s did not finish within %v", args[0], clipboardTimeout)
+		}
+		return err
+	}
+	return fmt.Errorf("no clipboard tool found")
+}
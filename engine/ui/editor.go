@@ -2,7 +2,9 @@ package ui
 
 import (
 	"fmt"
+	"image"
 	"image/color"
+	"math"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text"
@@ -10,30 +12,51 @@ import (
 
 	"deepthinking.do/luengo/engine/camera"
 	"deepthinking.do/luengo/engine/entity"
+	"deepthinking.do/luengo/engine/logging"
+	"deepthinking.do/luengo/engine/render"
+	"deepthinking.do/luengo/engine/resources"
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+const (
+	logPanelHeight = 120
+	inspectorWidth = 200
 )
 
 type EditorUI struct {
-	inspectorOpen  bool
-	logMessages    []string
-	maxLogMessages int
-	selectedEntity *entity.Entity
-	showDebug      bool
+	inspectorOpen      bool
+	inspectorMouseDown bool
+	inspectorFieldsTop int // Baseline of the first editable row as last drawn, 0 before
+	logger             *logging.Logger
+	logPanel           logPanelState
+	selectedEntity     *entity.Entity
+	showDebug          bool
+	hierarchy          hierarchyState
+	assets             assetBrowserState
+	memory             memoryPanelState
+	layers             layersPanelState
+	resources          *resources.Manager
+	files              *vfs.FS
 }
 
 func NewEditorUI() *EditorUI {
 	return &EditorUI{
-		inspectorOpen:  true,
-		logMessages:    make([]string, 0),
-		maxLogMessages: 50,
+		inspectorOpen: true,
+		logger:        logging.Default(),
+		logPanel:      newLogPanelState(),
+		hierarchy:     newHierarchyState(),
+		assets:        newAssetBrowserState(),
 	}
 }
 
+// AddLogMessage logs an engine message at info level
 func (ui *EditorUI) AddLogMessage(msg string, frame int) {
-	ui.logMessages = append(ui.logMessages, fmt.Sprintf("[%d] %s", frame, msg))
-	if len(ui.logMessages) > ui.maxLogMessages {
-		ui.logMessages = ui.logMessages[1:]
-	}
-	fmt.Println(msg) // Also print to console
+	ui.logger.Add(logging.Entry{Frame: frame, Level: logging.LevelInfo, Source: "engine", Message: msg})
+}
+
+// AddLogError logs an engine message at error level
+func (ui *EditorUI) AddLogError(msg string, frame int) {
+	ui.logger.Add(logging.Entry{Frame: frame, Level: logging.LevelError, Source: "engine", Message: msg})
 }
 
 func (ui *EditorUI) ToggleInspector() {
@@ -60,6 +83,30 @@ func (ui *EditorUI) IsDebugVisible() bool {
 	return ui.showDebug
 }
 
+// viewportWidth returns the width left of the inspector
+func (ui *EditorUI) viewportWidth(screenWidth int) int {
+	if ui.inspectorOpen {
+		return screenWidth - inspectorWidth
+	}
+	return screenWidth
+}
+
+// ViewportSize returns the screen area left for the game view, left of the inspector
+// and above the log panel
+func (ui *EditorUI) ViewportSize(screenWidth, screenHeight int) (int, int) {
+	return ui.viewportWidth(screenWidth), screenHeight - logPanelHeight
+}
+
+// IsMouseOverUI reports whether a screen position is over one of the editor panels
+func (ui *EditorUI) IsMouseOverUI(x, y, screenWidth, screenHeight int) bool {
+	return x >= ui.viewportWidth(screenWidth) ||
+		y >= screenHeight-logPanelHeight ||
+		ui.isInHierarchy(x, y, screenHeight) ||
+		ui.isInAssetBrowser(x, y, screenWidth, screenHeight) ||
+		ui.isInMemoryPanel(x, y, screenWidth, screenHeight) ||
+		ui.isInLayersPanel(x, y, screenWidth)
+}
+
 // DrawModeIndicator draws the current mode indicator
 func (ui *EditorUI) DrawModeIndicator(screen *ebiten.Image, editorMode bool) {
 	modeText := "PLAY MODE"
@@ -82,9 +129,9 @@ func (ui *EditorUI) DrawCameraInfo(screen *ebiten.Image, cam *camera.Camera, edi
 // DrawControls draws the control help text
 func (ui *EditorUI) DrawControls(screen *ebiten.Image, editorMode bool) {
 	controlY := 40
-	text.Draw(screen, "F1: Mode F2: Inspector F11: Fullscreen", basicfont.Face7x13, 10, controlY, color.RGBA{128, 128, 128, 255})
+	text.Draw(screen, "F1: Mode F2: Inspector F4: Hierarchy F11: Fullscreen", basicfont.Face7x13, 10, controlY, color.RGBA{128, 128, 128, 255})
 	if editorMode {
-		text.Draw(screen, "WASD/Arrows: Pan  Wheel/+/-: Zoom  R: Reset", basicfont.Face7x13, 10, controlY+15, color.RGBA{128, 128, 128, 255})
+		text.Draw(screen, "WASD/Arrows: Pan  Wheel/+/-: Zoom  R: Reset  F: Frame selection", basicfont.Face7x13, 10, controlY+15, color.RGBA{128, 128, 128, 255})
 		text.Draw(screen, "Drag: Move entity  Middle: Pan camera", basicfont.Face7x13, 10, controlY+30, color.RGBA{128, 128, 128, 255})
 	}
 }
@@ -97,7 +144,9 @@ func (ui *EditorUI) DrawDebugInfo(screen *ebiten.Image, player *entity.Entity, f
 
 	debugY := 100
 	text.Draw(screen, "Luengo Engine - DEBUG", basicfont.Face7x13, 10, debugY, color.White)
-	text.Draw(screen, fmt.Sprintf("Player Pos: X=%.0f Y=%.0f", player.Position.X, player.Position.Y), basicfont.Face7x13, 10, debugY+20, color.White)
+	if player != nil {
+		text.Draw(screen, fmt.Sprintf("Player Pos: X=%.0f Y=%.0f", player.Position.X, player.Position.Y), basicfont.Face7x13, 10, debugY+20, color.White)
+	}
 	text.Draw(screen, fmt.Sprintf("Frame: %d", frame), basicfont.Face7x13, 10, debugY+40, color.White)
 	text.Draw(screen, fmt.Sprintf("Entities: %d", entityCount), basicfont.Face7x13, 10, debugY+60, color.White)
 	text.Draw(screen, fmt.Sprintf("Screen: %dx%d", screenWidth, screenHeight), basicfont.Face7x13, 10, debugY+80, color.White)
@@ -110,7 +159,6 @@ func (ui *EditorUI) DrawInspector(screen *ebiten.Image, cam *camera.Camera, scre
 		return
 	}
 
-	inspectorWidth := 200
 	inspectorX := screenWidth - inspectorWidth // Right side panel
 
 	// Background
@@ -132,18 +180,43 @@ func (ui *EditorUI) DrawInspector(screen *ebiten.Image, cam *camera.Camera, scre
 		text.Draw(screen, fmt.Sprintf("X: %.1f", ui.selectedEntity.Position.X), basicfont.Face7x13, inspectorX+10, y, color.White)
 		y += 20
 		text.Draw(screen, fmt.Sprintf("Y: %.1f", ui.selectedEntity.Position.Y), basicfont.Face7x13, inspectorX+10, y, color.White)
+		y = ui.drawInspectorFields(screen, screenWidth, y+20)
+		y += 20
+		text.Draw(screen, fmt.Sprintf("Pivot: %.0f,%.0f", ui.selectedEntity.Pivot.X, ui.selectedEntity.Pivot.Y), basicfont.Face7x13, inspectorX+10, y, color.White)
+		y += 20
+		layer := ui.selectedEntity.Layer
+		if layer == "" {
+			layer = "default"
+		}
+		text.Draw(screen, fmt.Sprintf("Layer: %s  Z: %d", layer, ui.selectedEntity.Z), basicfont.Face7x13, inspectorX+10, y, color.White)
+
+		parentName := "(none)"
+		if ui.selectedEntity.Parent != nil {
+			parentName = ui.selectedEntity.Parent.Name
+		}
+		y += 20
+		text.Draw(screen, fmt.Sprintf("Parent: %s", parentName), basicfont.Face7x13, inspectorX+10, y, color.White)
+		y += 20
+		text.Draw(screen, fmt.Sprintf("Children: %d", len(ui.selectedEntity.Children)), basicfont.Face7x13, inspectorX+10, y, color.White)
 
 		if ui.selectedEntity.Sprite != nil {
 			y += 20
 			w, h := ui.selectedEntity.Sprite.Bounds().Dx(), ui.selectedEntity.Sprite.Bounds().Dy()
 			text.Draw(screen, fmt.Sprintf("Size: %dx%d", w, h), basicfont.Face7x13, inspectorX+10, y, color.White)
 		}
+		if ui.selectedEntity.Particles != "" {
+			y += 20
+			text.Draw(screen, fmt.Sprintf("Particles: %s", ui.selectedEntity.Particles), basicfont.Face7x13, inspectorX+10, y, color.White)
+		}
 
 		// Camera-relative position
 		y += 30
 		text.Draw(screen, "-- Camera View --", basicfont.Face7x13, inspectorX+10, y, color.RGBA{150, 150, 150, 255})
 		y += 20
-		screenX, screenY := cam.WorldToScreen(ui.selectedEntity.Position.X, ui.selectedEntity.Position.Y)
+		worldX, worldY := ui.selectedEntity.WorldPosition()
+		text.Draw(screen, fmt.Sprintf("World: %.1f,%.1f", worldX, worldY), basicfont.Face7x13, inspectorX+10, y, color.RGBA{150, 150, 150, 255})
+		y += 20
+		screenX, screenY := cam.WorldToScreen(worldX, worldY)
 		text.Draw(screen, fmt.Sprintf("Screen: %.1f,%.1f", screenX, screenY), basicfont.Face7x13, inspectorX+10, y, color.RGBA{150, 150, 150, 255})
 
 	} else {
@@ -163,76 +236,29 @@ func (ui *EditorUI) DrawInspector(screen *ebiten.Image, cam *camera.Camera, scre
 	text.Draw(screen, "R: Reset", basicfont.Face7x13, inspectorX+10, y, color.RGBA{120, 120, 120, 255})
 }
 
-// DrawLogPanel draws the log panel at the bottom
-func (ui *EditorUI) DrawLogPanel(screen *ebiten.Image, screenWidth, screenHeight int) {
-	logHeight := 120
-	logY := screenHeight - logHeight
-	logWidth := screenWidth
-	if ui.inspectorOpen {
-		logWidth -= 200 // Leave space for inspector
-	}
-
-	// Background
-	logBg := ebiten.NewImage(logWidth, logHeight)
-	logBg.Fill(color.RGBA{20, 20, 20, 200})
-	opts := &ebiten.DrawImageOptions{}
-	opts.GeoM.Translate(0, float64(logY))
-	screen.DrawImage(logBg, opts)
-
-	// Title
-	text.Draw(screen, "EXECUTION LOG", basicfont.Face7x13, 10, logY+20, color.White)
-
-	// Log messages (show last few)
-	maxVisible := 7
-	start := len(ui.logMessages) - maxVisible
-	if start < 0 {
-		start = 0
-	}
-
-	for i, msg := range ui.logMessages[start:] {
-		y := logY + 40 + i*12
-		if y < logY+logHeight-10 {
-			text.Draw(screen, msg, basicfont.Face7x13, 10, y, color.RGBA{200, 200, 200, 255})
-		}
-	}
-}
-
-// DrawGrid draws a grid in the background for editor mode
-func (ui *EditorUI) DrawGrid(screen *ebiten.Image, cam *camera.Camera, viewportWidth, viewportHeight int) {
+// DrawGrid draws a grid in the background for editor mode, in world space so it
+// turns and zooms with the camera
+func (ui *EditorUI) DrawGrid(screen *ebiten.Image, cam *camera.Camera) {
 	gridSize := 50.0 // Grid cell size in world units
 	gridColor := color.RGBA{60, 60, 70, 255}
 
-	// Calculate grid lines to draw based on camera position and zoom
-	startX := int((cam.X / gridSize)) - 1
-	endX := int(((cam.X + float64(viewportWidth)/cam.Zoom) / gridSize)) + 1
-	startY := int((cam.Y / gridSize)) - 1
-	endY := int(((cam.Y + float64(viewportHeight)/cam.Zoom) / gridSize)) + 1
-
-	// Draw vertical lines
-	for x := startX; x <= endX; x++ {
-		worldX := float64(x) * gridSize
-		screenX, _ := cam.WorldToScreen(worldX, 0)
-
-		if screenX >= 0 && screenX <= float64(viewportWidth) {
-			line := ebiten.NewImage(1, viewportHeight)
-			line.Fill(gridColor)
-			opts := &ebiten.DrawImageOptions{}
-			opts.GeoM.Translate(screenX, 0)
-			screen.DrawImage(line, opts)
-		}
+	viewport := image.Rect(int(cam.Viewport.X), int(cam.Viewport.Y),
+		int(cam.Viewport.X+cam.Viewport.W), int(cam.Viewport.Y+cam.Viewport.H))
+	canvas := render.NewWorldCanvas(screen.SubImage(viewport).(*ebiten.Image), cam)
+	canvas.AntiAlias = false
+	width := 1 / canvas.Scale() // One pixel whatever the zoom
+
+	// Cover the area the camera sees, rounded out to whole cells
+	visible := cam.VisibleBounds()
+	startX := math.Floor(visible.X/gridSize) * gridSize
+	startY := math.Floor(visible.Y/gridSize) * gridSize
+	endX := visible.X + visible.W
+	endY := visible.Y + visible.H
+
+	for x := startX; x <= endX; x += gridSize {
+		canvas.Line(x, startY, x, endY, width, gridColor)
 	}
-
-	// Draw horizontal lines
-	for y := startY; y <= endY; y++ {
-		worldY := float64(y) * gridSize
-		_, screenY := cam.WorldToScreen(0, worldY)
-
-		if screenY >= 0 && screenY <= float64(viewportHeight) {
-			line := ebiten.NewImage(viewportWidth, 1)
-			line.Fill(gridColor)
-			opts := &ebiten.DrawImageOptions{}
-			opts.GeoM.Translate(0, screenY)
-			screen.DrawImage(line, opts)
-		}
+	for y := startY; y <= endY; y += gridSize {
+		canvas.Line(startX, y, endX, y, width, gridColor)
 	}
 }
@@ -0,0 +1,310 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font/basicfont"
+
+	"deepthinking.do/luengo/engine/entity"
+)
+
+const (
+	hierarchyWidth     = 180
+	hierarchyTop       = 75
+	hierarchyRowHeight = 16
+	hierarchyIndent    = 12
+)
+
+type hierarchyRow struct {
+	entity *entity.Entity
+	depth  int
+}
+
+// hierarchyState holds the scene tree panel state
+type hierarchyState struct {
+	open      bool
+	collapsed map[entity.ID]bool
+	rows      []hierarchyRow
+	scroll    int // Rows scrolled past at the top of the tree
+	mouseDown bool
+	dragging  *entity.Entity
+	dropRow   int
+
+	// Prefab palette shown below the tree
+	prefabNames  []string
+	spawnRequest string
+}
+
+func newHierarchyState() hierarchyState {
+	return hierarchyState{
+		open:      true,
+		collapsed: make(map[entity.ID]bool),
+		dropRow:   -1,
+	}
+}
+
+func (ui *EditorUI) ToggleHierarchy() {
+	ui.hierarchy.open = !ui.hierarchy.open
+}
+
+func (ui *EditorUI) IsHierarchyOpen() bool {
+	return ui.hierarchy.open
+}
+
+// SetPrefabNames sets the prefabs listed in the panel's palette
+func (ui *EditorUI) SetPrefabNames(names []string) {
+	ui.hierarchy.prefabNames = names
+}
+
+// TakeSpawnRequest returns the prefab clicked in the palette since the last call, if any
+func (ui *EditorUI) TakeSpawnRequest() (string, bool) {
+	name := ui.hierarchy.spawnRequest
+	ui.hierarchy.spawnRequest = ""
+	return name, name != ""
+}
+
+// prefabListTop returns the top edge of the prefab palette
+func (ui *EditorUI) prefabListTop(screenHeight int) int {
+	if len(ui.hierarchy.prefabNames) == 0 {
+		return ui.hierarchyBottom(screenHeight)
+	}
+	return ui.hierarchyBottom(screenHeight) - 25 - len(ui.hierarchy.prefabNames)*hierarchyRowHeight
+}
+
+// hierarchyBottom returns the bottom edge of the panel, above the log panel and asset browser
+func (ui *EditorUI) hierarchyBottom(screenHeight int) int {
+	if ui.assets.open {
+		return assetPanelTop(screenHeight)
+	}
+	return screenHeight - logPanelHeight
+}
+
+func (ui *EditorUI) isInHierarchy(x, y, screenHeight int) bool {
+	return ui.hierarchy.open && x >= 0 && x < hierarchyWidth && y >= hierarchyTop && y < ui.hierarchyBottom(screenHeight)
+}
+
+// rebuildHierarchyRows flattens the visible (non-collapsed) part of the scene tree
+func (ui *EditorUI) rebuildHierarchyRows(em *entity.Manager) {
+	h := &ui.hierarchy
+	h.rows = h.rows[:0]
+	var walk func(e *entity.Entity, depth int)
+	walk = func(e *entity.Entity, depth int) {
+		h.rows = append(h.rows, hierarchyRow{entity: e, depth: depth})
+		if h.collapsed[e.ID] {
+			return
+		}
+		for _, child := range e.Children {
+			walk(child, depth+1)
+		}
+	}
+	for _, root := range em.GetRoots() {
+		walk(root, 0)
+	}
+}
+
+// hierarchyMaxRows returns how many tree rows fit above the prefab palette
+func (ui *EditorUI) hierarchyMaxRows(screenHeight int) int {
+	return (ui.prefabListTop(screenHeight) - hierarchyTop - 30) / hierarchyRowHeight
+}
+
+// clampHierarchyScroll keeps the scroll within the tree, which may have shrunk
+func (ui *EditorUI) clampHierarchyScroll(screenHeight int) {
+	h := &ui.hierarchy
+	h.scroll = max(min(h.scroll, len(h.rows)-max(ui.hierarchyMaxRows(screenHeight), 0)), 0)
+}
+
+// rowAt returns the index of the drawn tree row under the given screen position, or -1
+func (ui *EditorUI) rowAt(y, screenHeight int) int {
+	visible := (y - hierarchyTop - 25) / hierarchyRowHeight
+	if y < hierarchyTop+25 || visible >= ui.hierarchyMaxRows(screenHeight) {
+		return -1
+	}
+	index := ui.hierarchy.scroll + visible
+	if index >= len(ui.hierarchy.rows) {
+		return -1
+	}
+	return index
+}
+
+// prefabAt returns the index of the palette entry under the given screen position, or -1
+func (ui *EditorUI) prefabAt(y, screenHeight int) int {
+	top := ui.prefabListTop(screenHeight) + 20
+	if y < top {
+		return -1
+	}
+	index := (y - top) / hierarchyRowHeight
+	if index >= len(ui.hierarchy.prefabNames) {
+		return -1
+	}
+	return index
+}
+
+// UpdateHierarchy handles clicks, scrolling, collapsing and drag-to-reparent in the scene tree
+// panel. It returns true when the mouse is owned by the panel this frame.
+func (ui *EditorUI) UpdateHierarchy(em *entity.Manager, mouseX, mouseY int, mouseDown bool, wheelY float64, screenHeight, frame int) bool {
+	h := &ui.hierarchy
+	justPressed := mouseDown && !h.mouseDown
+	justReleased := !mouseDown && h.mouseDown
+	h.mouseDown = mouseDown
+
+	if !h.open {
+		h.dragging = nil
+		return false
+	}
+
+	ui.rebuildHierarchyRows(em)
+	inPanel := ui.isInHierarchy(mouseX, mouseY, screenHeight)
+	if inPanel && wheelY != 0 {
+		h.scroll -= int(wheelY * 3)
+	}
+	ui.clampHierarchyScroll(screenHeight)
+
+	if justPressed && inPanel {
+		if index := ui.prefabAt(mouseY, screenHeight); index >= 0 {
+			h.spawnRequest = h.prefabNames[index]
+			return true
+		}
+		row := ui.rowAt(mouseY, screenHeight)
+		if row < 0 {
+			return true
+		}
+		r := h.rows[row]
+		toggleX := 10 + r.depth*hierarchyIndent
+		if len(r.entity.Children) > 0 && mouseX >= toggleX && mouseX < toggleX+hierarchyIndent {
+			h.collapsed[r.entity.ID] = !h.collapsed[r.entity.ID]
+			return true
+		}
+		ui.SetSelectedEntity(r.entity)
+		h.dragging = r.entity
+		return true
+	}
+
+	if h.dragging != nil {
+		h.dropRow = -1
+		if inPanel {
+			h.dropRow = ui.rowAt(mouseY, screenHeight)
+		}
+		if justReleased {
+			ui.dropInHierarchy(em, inPanel, frame)
+		}
+		return true
+	}
+
+	return inPanel
+}
+
+// dropInHierarchy finishes a drag in the scene tree, reparenting the dragged entity
+func (ui *EditorUI) dropInHierarchy(em *entity.Manager, inPanel bool, frame int) {
+	h := &ui.hierarchy
+	dragged := h.dragging
+	dropRow := h.dropRow
+	h.dragging = nil
+	h.dropRow = -1
+
+	if !inPanel {
+		return
+	}
+
+	var parent *entity.Entity
+	if dropRow >= 0 {
+		parent = h.rows[dropRow].entity
+		if parent == dragged {
+			return
+		}
+	}
+	if parent == dragged.Parent {
+		return
+	}
+
+	if err := em.SetParent(dragged, parent, true); err != nil {
+		ui.AddLogError(fmt.Sprintf("Reparent failed: %v", err), frame)
+		return
+	}
+	if parent != nil {
+		h.collapsed[parent.ID] = false
+		ui.AddLogMessage(fmt.Sprintf("Parented %s to %s", dragged.Name, parent.Name), frame)
+	} else {
+		ui.AddLogMessage(fmt.Sprintf("Unparented %s", dragged.Name), frame)
+	}
+}
+
+// DrawHierarchy draws the scene tree panel on the left side
+func (ui *EditorUI) DrawHierarchy(screen *ebiten.Image, em *entity.Manager, screenHeight int) {
+	h := &ui.hierarchy
+	if !h.open {
+		return
+	}
+
+	panelHeight := ui.hierarchyBottom(screenHeight) - hierarchyTop
+	if panelHeight <= 0 {
+		return
+	}
+
+	// Background
+	bg := ebiten.NewImage(hierarchyWidth, panelHeight)
+	bg.Fill(color.RGBA{40, 40, 40, 200})
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Translate(0, float64(hierarchyTop))
+	screen.DrawImage(bg, opts)
+
+	ui.rebuildHierarchyRows(em)
+	ui.clampHierarchyScroll(screenHeight)
+	listTop := ui.prefabListTop(screenHeight)
+	maxRows := ui.hierarchyMaxRows(screenHeight)
+	visible := h.rows[h.scroll:min(h.scroll+max(maxRows, 0), len(h.rows))]
+
+	// Title, with the rows shown when the tree does not fit
+	title := "HIERARCHY"
+	if len(visible) < len(h.rows) {
+		title = fmt.Sprintf("HIERARCHY %d-%d/%d", h.scroll+1, h.scroll+len(visible), len(h.rows))
+	}
+	if h.dragging != nil {
+		title = "DROP TO REPARENT"
+	}
+	text.Draw(screen, title, basicfont.Face7x13, 10, hierarchyTop+17, color.White)
+
+	for n, r := range visible {
+		i := h.scroll + n
+		y := hierarchyTop + 25 + n*hierarchyRowHeight
+
+		// Row highlight for selection and drop target
+		var highlight color.Color
+		switch {
+		case i == h.dropRow && h.dragging != nil && r.entity != h.dragging:
+			highlight = color.RGBA{60, 90, 160, 220}
+		case r.entity == ui.selectedEntity:
+			highlight = color.RGBA{90, 80, 30, 220}
+		}
+		if highlight != nil {
+			rowBg := ebiten.NewImage(hierarchyWidth, hierarchyRowHeight)
+			rowBg.Fill(highlight)
+			rowOpts := &ebiten.DrawImageOptions{}
+			rowOpts.GeoM.Translate(0, float64(y))
+			screen.DrawImage(rowBg, rowOpts)
+		}
+
+		x := 10 + r.depth*hierarchyIndent
+		marker := " "
+		if len(r.entity.Children) > 0 {
+			marker = "-"
+			if h.collapsed[r.entity.ID] {
+				marker = "+"
+			}
+		}
+		label := fmt.Sprintf("%s %s", marker, r.entity.Name)
+		label = truncate(label, (hierarchyWidth-x-4)/7)
+		text.Draw(screen, label, basicfont.Face7x13, x, y+12, color.RGBA{220, 220, 220, 255})
+	}
+
+	// Prefab palette
+	if len(h.prefabNames) > 0 {
+		text.Draw(screen, "-- Prefabs (click) --", basicfont.Face7x13, 10, listTop+14, color.RGBA{150, 150, 150, 255})
+		for i, name := range h.prefabNames {
+			y := listTop + 20 + i*hierarchyRowHeight
+			text.Draw(screen, "* "+name, basicfont.Face7x13, 10, y+12, color.RGBA{180, 200, 255, 255})
+		}
+	}
+}
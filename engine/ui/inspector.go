@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font/basicfont"
+
+	"deepthinking.do/luengo/engine/entity"
+)
+
+const inspectorRowHeight = 20
+
+// inspectorField is a property of the selected entity that the inspector's - and +
+// buttons step; toggles flip either way
+type inspectorField struct {
+	label string
+	value func(e *entity.Entity) string
+	step  func(e *entity.Entity, dir int)
+}
+
+var inspectorFields = []inspectorField{
+	{"Rot", func(e *entity.Entity) string { return fmt.Sprintf("%.1f deg", e.Rotation*180/math.Pi) },
+		func(e *entity.Entity, dir int) {
+			e.Rotation = math.Remainder(e.Rotation+float64(dir)*math.Pi/12, 2*math.Pi)
+		}},
+	{"Scale X", func(e *entity.Entity) string { return fmt.Sprintf("%.2f", e.Scale.X) },
+		func(e *entity.Entity, dir int) { e.Scale.X = stepFloat(e.Scale.X, 0.1, dir) }},
+	{"Scale Y", func(e *entity.Entity) string { return fmt.Sprintf("%.2f", e.Scale.Y) },
+		func(e *entity.Entity, dir int) { e.Scale.Y = stepFloat(e.Scale.Y, 0.1, dir) }},
+	{"Flip X", func(e *entity.Entity) string { return onOff(e.FlipX) },
+		func(e *entity.Entity, dir int) { e.FlipX = !e.FlipX }},
+	{"Flip Y", func(e *entity.Entity) string { return onOff(e.FlipY) },
+		func(e *entity.Entity, dir int) { e.FlipY = !e.FlipY }},
+	{"Tint R", func(e *entity.Entity) string { return fmt.Sprint(e.Tint.R) },
+		func(e *entity.Entity, dir int) { e.Tint.R = stepByte(e.Tint.R, dir) }},
+	{"Tint G", func(e *entity.Entity) string { return fmt.Sprint(e.Tint.G) },
+		func(e *entity.Entity, dir int) { e.Tint.G = stepByte(e.Tint.G, dir) }},
+	{"Tint B", func(e *entity.Entity) string { return fmt.Sprint(e.Tint.B) },
+		func(e *entity.Entity, dir int) { e.Tint.B = stepByte(e.Tint.B, dir) }},
+	{"Alpha", func(e *entity.Entity) string { return fmt.Sprintf("%.2f", e.Alpha) },
+		func(e *entity.Entity, dir int) { e.Alpha = math.Max(0, math.Min(1, stepFloat(e.Alpha, 0.1, dir))) }},
+	{"Blend", func(e *entity.Entity) string { return e.Blend.String() },
+		func(e *entity.Entity, dir int) {
+			count := len(entity.BlendModeNames())
+			e.Blend = entity.BlendMode((int(e.Blend) + dir + count) % count)
+		}},
+}
+
+// stepFloat adds step in a direction, rounded to hundredths so repeated steps stay exact
+func stepFloat(value, step float64, dir int) float64 {
+	return math.Round((value+step*float64(dir))*100) / 100
+}
+
+// stepByte steps a colour channel by 16, stopping at 0 and 255
+func stepByte(value uint8, dir int) uint8 {
+	return uint8(math.Max(0, math.Min(255, float64(int(value)+16*dir))))
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// inspectorButtons returns the left edges of a row's - and + buttons
+func inspectorButtons(screenWidth int) (minus, plus int) {
+	right := screenWidth - 10
+	return right - 44, right - 20
+}
+
+// UpdateInspector steps the selected entity's properties when the inspector's - and +
+// buttons are clicked. It returns true when the mouse is over the inspector.
+func (ui *EditorUI) UpdateInspector(mouseX, mouseY int, mouseDown bool, screenWidth int) bool {
+	justPressed := mouseDown && !ui.inspectorMouseDown
+	ui.inspectorMouseDown = mouseDown
+
+	if !ui.inspectorOpen || mouseX < screenWidth-inspectorWidth {
+		return false
+	}
+	// The rows are where the last frame drew them, if it drew them at all
+	top := ui.inspectorFieldsTop
+	if !justPressed || ui.selectedEntity == nil || top == 0 {
+		return true
+	}
+
+	// Rows are drawn on text baselines, so each row's area reaches 14 pixels above it
+	index := (mouseY - top + 14) / inspectorRowHeight
+	if mouseY < top-14 || index >= len(inspectorFields) {
+		return true
+	}
+	minus, plus := inspectorButtons(screenWidth)
+	switch {
+	case mouseX >= minus && mouseX < minus+20:
+		inspectorFields[index].step(ui.selectedEntity, -1)
+	case mouseX >= plus && mouseX < plus+20:
+		inspectorFields[index].step(ui.selectedEntity, 1)
+	}
+	return true
+}
+
+// drawInspectorFields draws the editable rows from the baseline top, remembering it
+// for clicks, and returns the baseline of the last one
+func (ui *EditorUI) drawInspectorFields(screen *ebiten.Image, screenWidth, top int) int {
+	ui.inspectorFieldsTop = top
+	x := screenWidth - inspectorWidth + 10
+	minus, plus := inspectorButtons(screenWidth)
+	buttonColor := color.RGBA{120, 180, 255, 255}
+	y := top
+	for i, field := range inspectorFields {
+		if i > 0 {
+			y += inspectorRowHeight
+		}
+		text.Draw(screen, fmt.Sprintf("%s: %s", field.label, field.value(ui.selectedEntity)), basicfont.Face7x13, x, y, color.White)
+		text.Draw(screen, "[-]", basicfont.Face7x13, minus, y, buttonColor)
+		text.Draw(screen, "[+]", basicfont.Face7x13, plus, y, buttonColor)
+	}
+	return y
+}
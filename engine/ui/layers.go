@@ -0,0 +1,120 @@
+package ui
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font/basicfont"
+
+	"deepthinking.do/luengo/engine/render"
+)
+
+const (
+	layersPanelWidth     = 180
+	layersPanelTop       = 30
+	layersPanelRowHeight = 16
+)
+
+// layersPanelState holds the render layers panel state
+type layersPanelState struct {
+	open      bool
+	list      *render.Layers
+	mouseDown bool
+}
+
+// SetLayers sets the render layers the panel shows
+func (ui *EditorUI) SetLayers(layers *render.Layers) {
+	ui.layers.list = layers
+}
+
+func (ui *EditorUI) ToggleLayersPanel() {
+	ui.layers.open = !ui.layers.open
+}
+
+func (ui *EditorUI) IsLayersPanelOpen() bool {
+	return ui.layers.open
+}
+
+// layersPanelBounds returns the panel's left edge and height, at the right of the
+// viewport or left of the memory view when that is open
+func (ui *EditorUI) layersPanelBounds(screenWidth int) (x, height int) {
+	x = ui.viewportWidth(screenWidth) - layersPanelWidth
+	if ui.memory.open {
+		x -= memoryPanelWidth
+	}
+	return x, 25 + len(ui.layers.list.All())*layersPanelRowHeight
+}
+
+func (ui *EditorUI) isInLayersPanel(x, y, screenWidth int) bool {
+	if !ui.layers.open || ui.layers.list == nil {
+		return false
+	}
+	left, height := ui.layersPanelBounds(screenWidth)
+	return x >= left && x < left+layersPanelWidth && y >= layersPanelTop && y < layersPanelTop+height
+}
+
+// UpdateLayersPanel toggles a layer's visibility when its row is clicked, or its
+// y-sorting when the Y column is. It returns true when the mouse is over the panel.
+func (ui *EditorUI) UpdateLayersPanel(mouseX, mouseY int, mouseDown bool, screenWidth int) bool {
+	justPressed := mouseDown && !ui.layers.mouseDown
+	ui.layers.mouseDown = mouseDown
+
+	if !ui.isInLayersPanel(mouseX, mouseY, screenWidth) {
+		return false
+	}
+	if !justPressed {
+		return true
+	}
+
+	layers := ui.layers.list.All()
+	index := (mouseY - layersPanelTop - 25) / layersPanelRowHeight
+	if mouseY < layersPanelTop+25 || index >= len(layers) {
+		return true
+	}
+	// The list runs top to bottom, so the topmost layer is the first row
+	layer := layers[len(layers)-1-index]
+	left, _ := ui.layersPanelBounds(screenWidth)
+	if mouseX >= left+layersPanelWidth-30 {
+		layer.YSort = !layer.YSort
+	} else {
+		layer.Visible = !layer.Visible
+	}
+	return true
+}
+
+// DrawLayersPanel draws the render layers, topmost first, with their visibility and y-sorting
+func (ui *EditorUI) DrawLayersPanel(screen *ebiten.Image, screenWidth int) {
+	if !ui.layers.open || ui.layers.list == nil {
+		return
+	}
+
+	left, height := ui.layersPanelBounds(screenWidth)
+	bg := ebiten.NewImage(layersPanelWidth, height)
+	bg.Fill(color.RGBA{30, 30, 40, 230})
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Translate(float64(left), layersPanelTop)
+	screen.DrawImage(bg, opts)
+
+	text.Draw(screen, "LAYERS", basicfont.Face7x13, left+10, layersPanelTop+15, color.White)
+	text.Draw(screen, "Y", basicfont.Face7x13, left+layersPanelWidth-20, layersPanelTop+15, color.RGBA{150, 150, 150, 255})
+
+	layers := ui.layers.list.All()
+	y := layersPanelTop + 25 + 12
+	for i := len(layers) - 1; i >= 0; i-- {
+		layer := layers[i]
+		check, rowColor := "[x]", color.RGBA{220, 220, 220, 255}
+		if !layer.Visible {
+			check, rowColor = "[ ]", color.RGBA{120, 120, 120, 255}
+		}
+		name := layer.DisplayName()
+		if len(name) > 16 {
+			name = name[:15] + "~"
+		}
+		text.Draw(screen, check+" "+name, basicfont.Face7x13, left+10, y, rowColor)
+		if layer.YSort {
+			text.Draw(screen, "Y", basicfont.Face7x13, left+layersPanelWidth-20, y, color.RGBA{100, 200, 255, 255})
+		}
+		y += layersPanelRowHeight
+	}
+}
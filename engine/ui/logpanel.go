@@ -0,0 +1,330 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font/basicfont"
+
+	"deepthinking.do/luengo/engine/logging"
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+const (
+	logLineHeight   = 12
+	logLinesTop     = 30
+	logLevelButtonX = 60
+	logLevelButtonW = 28
+	logSourceX      = 180
+	logSourceW      = 170
+	logSearchX      = 360
+	logSearchW      = 190
+)
+
+var logLevels = []logging.Level{logging.LevelDebug, logging.LevelInfo, logging.LevelWarn, logging.LevelError}
+
+// logPanelState holds the log panel's filters, scroll position and search box
+type logPanelState struct {
+	hiddenLevels  map[logging.Level]bool
+	source        string // Empty shows every source
+	search        []rune
+	searchFocused bool
+	scroll        int // Lines scrolled up from the newest entry
+	mouseDown     bool
+	status        string // Feedback shown in the header, e.g. after copying a line
+	statusFrames  int
+	copying       <-chan error // Outcome of the clipboard copy in progress, nil when none
+	copyQuiet     bool         // Only a failed copy is reported, as the status shows something else
+}
+
+func newLogPanelState() logPanelState {
+	return logPanelState{hiddenLevels: make(map[logging.Level]bool)}
+}
+
+// IsTyping reports whether a text field in the editor has keyboard focus
+func (ui *EditorUI) IsTyping() bool {
+	return ui.logPanel.searchFocused
+}
+
+// StopTyping takes focus from the editor's text fields, e.g. when the console opens
+func (ui *EditorUI) StopTyping() {
+	ui.logPanel.searchFocused = false
+}
+
+// filteredLogEntries returns the entries that pass the level, source and search filters
+func (ui *EditorUI) filteredLogEntries() []logging.Entry {
+	lp := &ui.logPanel
+	search := strings.ToLower(string(lp.search))
+	filtered := make([]logging.Entry, 0)
+	for _, e := range ui.logger.Entries() {
+		if lp.hiddenLevels[e.Level] {
+			continue
+		}
+		if lp.source != "" && e.Source != lp.source {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(e.Message), search) && !strings.Contains(strings.ToLower(e.Source), search) {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+	return filtered
+}
+
+func logVisibleLines() int {
+	return (logPanelHeight - logLinesTop - 5) / logLineHeight
+}
+
+// cycleLogSource switches the source filter to the next known source, then back to all
+func (ui *EditorUI) cycleLogSource() {
+	sources := ui.logger.Sources()
+	lp := &ui.logPanel
+	if lp.source == "" {
+		if len(sources) > 0 {
+			lp.source = sources[0]
+		}
+		return
+	}
+	for i, source := range sources {
+		if source == lp.source {
+			if i+1 < len(sources) {
+				lp.source = sources[i+1]
+			} else {
+				lp.source = ""
+			}
+			return
+		}
+	}
+	lp.source = ""
+}
+
+func (ui *EditorUI) setLogStatus(msg string) {
+	ui.logPanel.status = msg
+	ui.logPanel.statusFrames = 120
+}
+
+// UpdateLogPanel handles filter buttons, the search box, scrolling and click-to-copy.
+// It returns true when the mouse is owned by the panel this frame.
+func (ui *EditorUI) UpdateLogPanel(mouseX, mouseY int, mouseDown bool, wheelY float64, screenWidth, screenHeight int) bool {
+	lp := &ui.logPanel
+	justPressed := mouseDown && !lp.mouseDown
+	lp.mouseDown = mouseDown
+	if lp.statusFrames > 0 {
+		lp.statusFrames--
+	}
+	select {
+	case err := <-lp.copying:
+		lp.copying = nil
+		if err != nil {
+			ui.setLogStatus(fmt.Sprintf("Copy failed: %v", err))
+		} else if !lp.copyQuiet {
+			ui.setLogStatus("Copied line to clipboard")
+		}
+	default:
+	}
+
+	if lp.searchFocused {
+		ui.updateLogSearch()
+	}
+
+	top := screenHeight - logPanelHeight
+	inPanel := mouseX >= 0 && mouseX < ui.viewportWidth(screenWidth) && mouseY >= top && mouseY < screenHeight
+
+	entries := ui.filteredLogEntries()
+	maxScroll := len(entries) - logVisibleLines()
+	if maxScroll < 0 {
+		maxScroll = 0
+	}
+	if inPanel && wheelY != 0 {
+		lp.scroll += int(wheelY * 3)
+	}
+	if lp.scroll > maxScroll {
+		lp.scroll = maxScroll
+	}
+	if lp.scroll < 0 {
+		lp.scroll = 0
+	}
+
+	if !justPressed {
+		return inPanel
+	}
+	if !inPanel {
+		lp.searchFocused = false
+		return false
+	}
+
+	x, y := mouseX, mouseY-top
+	lp.searchFocused = false
+	switch {
+	case y < logLinesTop-8:
+		// Header: level toggles, source filter and search box
+		switch {
+		case x >= logLevelButtonX && x < logLevelButtonX+len(logLevels)*logLevelButtonW:
+			level := logLevels[(x-logLevelButtonX)/logLevelButtonW]
+			lp.hiddenLevels[level] = !lp.hiddenLevels[level]
+			lp.scroll = 0
+		case x >= logSourceX && x < logSourceX+logSourceW:
+			ui.cycleLogSource()
+			lp.scroll = 0
+		case x >= logSearchX && x < logSearchX+logSearchW:
+			lp.searchFocused = true
+		}
+	default:
+		// Log line: copy it, with any traceback, and open its source location
+		if index := ui.logLineAt(y, entries); index >= 0 {
+			ui.activateLogEntry(entries[index])
+		}
+	}
+	return true
+}
+
+// SetFiles sets the file system that log locations are resolved against, to open them in an editor
+func (ui *EditorUI) SetFiles(files *vfs.FS) {
+	ui.files = files
+}
+
+// activateLogEntry copies an entry to the clipboard and, when it points at a source file, opens it.
+// The copy finishes in the background; UpdateLogPanel reports it.
+func (ui *EditorUI) activateLogEntry(e logging.Entry) {
+	lp := &ui.logPanel
+	lp.copying = copyToClipboardAsync(e.String())
+	lp.copyQuiet = e.Location() != ""
+	if location := e.Location(); location != "" {
+		file := e.File
+		if ui.files != nil {
+			if origin, ok := ui.files.Origin(file); ok {
+				file = origin
+			}
+		}
+		if err := openInEditor(file, e.Line); err != nil {
+			ui.setLogStatus(fmt.Sprintf("Open failed: %v", err))
+		} else {
+			ui.setLogStatus("Opened " + location)
+		}
+		return
+	}
+	ui.setLogStatus("Copying line...")
+}
+
+// updateLogSearch edits the search box while it has focus
+func (ui *EditorUI) updateLogSearch() {
+	lp := &ui.logPanel
+	for _, r := range ebiten.AppendInputChars(nil) {
+		lp.search = append(lp.search, r)
+		lp.scroll = 0
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && len(lp.search) > 0 {
+		lp.search = lp.search[:len(lp.search)-1]
+		lp.scroll = 0
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		lp.searchFocused = false
+	}
+}
+
+// logLineAt returns the index into entries of the line at a panel-relative y, or -1
+func (ui *EditorUI) logLineAt(y int, entries []logging.Entry) int {
+	row := (y - logLinesTop + 2) / logLineHeight
+	start, end := ui.logWindow(entries)
+	if y < logLinesTop-2 || start+row >= end {
+		return -1
+	}
+	return start + row
+}
+
+// logWindow returns the range of entries visible at the current scroll position
+func (ui *EditorUI) logWindow(entries []logging.Entry) (int, int) {
+	// The scroll may be stale when a filter has just shortened the list
+	end := min(max(len(entries)-ui.logPanel.scroll, 0), len(entries))
+	start := max(end-logVisibleLines(), 0)
+	return start, end
+}
+
+func logLevelColor(level logging.Level) color.Color {
+	switch level {
+	case logging.LevelDebug:
+		return color.RGBA{140, 140, 150, 255}
+	case logging.LevelWarn:
+		return color.RGBA{255, 200, 80, 255}
+	case logging.LevelError:
+		return color.RGBA{255, 100, 100, 255}
+	default:
+		return color.RGBA{200, 200, 200, 255}
+	}
+}
+
+// DrawLogPanel draws the log panel at the bottom
+func (ui *EditorUI) DrawLogPanel(screen *ebiten.Image, screenWidth, screenHeight int) {
+	lp := &ui.logPanel
+	logY := screenHeight - logPanelHeight
+	logWidth := ui.viewportWidth(screenWidth)
+
+	// Background
+	logBg := ebiten.NewImage(logWidth, logPanelHeight)
+	logBg.Fill(color.RGBA{20, 20, 20, 200})
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Translate(0, float64(logY))
+	screen.DrawImage(logBg, opts)
+
+	// Header
+	text.Draw(screen, "LOG", basicfont.Face7x13, 10, logY+15, color.White)
+	for i, level := range logLevels {
+		clr := logLevelColor(level)
+		if lp.hiddenLevels[level] {
+			clr = color.RGBA{70, 70, 70, 255}
+		}
+		text.Draw(screen, "["+level.Short()+"]", basicfont.Face7x13, logLevelButtonX+i*logLevelButtonW, logY+15, clr)
+	}
+
+	source := lp.source
+	if source == "" {
+		source = "all"
+	}
+	text.Draw(screen, truncate("src: "+source, logSourceW/7), basicfont.Face7x13, logSourceX, logY+15, color.RGBA{180, 200, 255, 255})
+
+	search := "find: " + string(lp.search)
+	searchColor := color.Color(color.RGBA{150, 150, 150, 255})
+	if lp.searchFocused {
+		search += "_"
+		searchColor = color.White
+	}
+	text.Draw(screen, truncate(search, logSearchW/7), basicfont.Face7x13, logSearchX, logY+15, searchColor)
+
+	entries := ui.filteredLogEntries()
+	header := fmt.Sprintf("%d entries", len(entries))
+	if lp.statusFrames > 0 {
+		header = lp.status
+	} else if lp.scroll > 0 {
+		header = fmt.Sprintf("%d entries, %d up", len(entries), lp.scroll)
+	}
+	text.Draw(screen, header, basicfont.Face7x13, logSearchX+logSearchW+10, logY+15, color.RGBA{128, 128, 128, 255})
+
+	// Entries, newest at the bottom
+	start, end := ui.logWindow(entries)
+	maxChars := (logWidth - 20) / 7
+	for i, e := range entries[start:end] {
+		// Entries with a source location are marked; clicking them opens the file
+		marker := "  "
+		if e.Location() != "" {
+			marker = "> "
+		}
+		line := fmt.Sprintf("%s%s %s %s: %s", marker, e.Time.Format("15:04:05"), e.Level.Short(), e.Source, e.Message)
+		text.Draw(screen, truncate(line, maxChars), basicfont.Face7x13, 4, logY+logLinesTop+i*logLineHeight+8, logLevelColor(e.Level))
+	}
+}
+
+// truncate shortens s to at most n characters
+func truncate(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text"
 	"golang.org/x/image/font/basicfont"
+
+	"deepthinking.do/luengo/engine/render"
 )
 
 type Manager struct {
@@ -26,6 +28,7 @@ func (m *Manager) DrawGrid(screen *ebiten.Image, camera interface{}, viewportWid
 
 	gridSize := 50.0 // Grid cell size in world units
 	gridColor := color.RGBA{60, 60, 70, 255}
+	canvas := render.NewCanvas(screen)
 
 	// Calculate grid lines to draw based on camera position and zoom
 	startX := int((cam.X / gridSize)) - 1
@@ -39,11 +42,7 @@ func (m *Manager) DrawGrid(screen *ebiten.Image, camera interface{}, viewportWid
 		screenX := (worldX - cam.X) * cam.Zoom
 
 		if screenX >= 0 && screenX <= float64(viewportWidth) {
-			line := ebiten.NewImage(1, viewportHeight)
-			line.Fill(gridColor)
-			opts := &ebiten.DrawImageOptions{}
-			opts.GeoM.Translate(screenX, 0)
-			screen.DrawImage(line, opts)
+			canvas.Line(screenX, 0, screenX, float64(viewportHeight), 1, gridColor)
 		}
 	}
 
@@ -53,11 +52,7 @@ func (m *Manager) DrawGrid(screen *ebiten.Image, camera interface{}, viewportWid
 		screenY := (worldY - cam.Y) * cam.Zoom
 
 		if screenY >= 0 && screenY <= float64(viewportHeight) {
-			line := ebiten.NewImage(viewportWidth, 1)
-			line.Fill(gridColor)
-			opts := &ebiten.DrawImageOptions{}
-			opts.GeoM.Translate(0, screenY)
-			screen.DrawImage(line, opts)
+			canvas.Line(0, screenY, float64(viewportWidth), screenY, 1, gridColor)
 		}
 	}
 }
@@ -0,0 +1,134 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	"path"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font/basicfont"
+
+	"deepthinking.do/luengo/engine/resources"
+)
+
+const (
+	memoryPanelWidth     = 380
+	memoryPanelTop       = 30
+	memoryPanelRowHeight = 15
+)
+
+// memoryPanelState holds the memory view state
+type memoryPanelState struct {
+	open bool
+}
+
+func (ui *EditorUI) ToggleMemoryPanel() {
+	ui.memory.open = !ui.memory.open
+}
+
+func (ui *EditorUI) IsMemoryPanelOpen() bool {
+	return ui.memory.open
+}
+
+// memoryPanelBounds returns the panel's left edge and bottom, at the right of the
+// viewport and above the asset browser or log panel
+func (ui *EditorUI) memoryPanelBounds(screenWidth, screenHeight int) (x, bottom int) {
+	bottom = screenHeight - logPanelHeight
+	if ui.assets.open {
+		bottom = assetPanelTop(screenHeight)
+	}
+	return ui.viewportWidth(screenWidth) - memoryPanelWidth, bottom
+}
+
+func (ui *EditorUI) isInMemoryPanel(x, y, screenWidth, screenHeight int) bool {
+	if !ui.memory.open {
+		return false
+	}
+	left, bottom := ui.memoryPanelBounds(screenWidth, screenHeight)
+	return x >= left && x < left+memoryPanelWidth && y >= memoryPanelTop && y < bottom
+}
+
+// DrawMemoryPanel draws the memory used by each cached asset, largest first
+func (ui *EditorUI) DrawMemoryPanel(screen *ebiten.Image, screenWidth, screenHeight int) {
+	if !ui.memory.open || ui.resources == nil {
+		return
+	}
+
+	left, bottom := ui.memoryPanelBounds(screenWidth, screenHeight)
+	height := bottom - memoryPanelTop
+	if height <= 40 {
+		return
+	}
+
+	// Background
+	bg := ebiten.NewImage(memoryPanelWidth, height)
+	bg.Fill(color.RGBA{30, 30, 40, 230})
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Translate(float64(left), memoryPanelTop)
+	screen.DrawImage(bg, opts)
+
+	used, budget := ui.resources.Memory()
+	usage := ui.resources.Usage()
+	title := fmt.Sprintf("MEMORY  %s / %s  %d assets", resources.FormatBytes(used), resources.FormatBytes(budget), len(usage))
+	text.Draw(screen, title, basicfont.Face7x13, left+10, memoryPanelTop+15, color.White)
+
+	// Budget bar, red once the referenced assets alone exceed it
+	barWidth := memoryPanelWidth - 20
+	bar := ebiten.NewImage(barWidth, 6)
+	bar.Fill(color.RGBA{60, 60, 70, 255})
+	opts = &ebiten.DrawImageOptions{}
+	opts.GeoM.Translate(float64(left+10), memoryPanelTop+22)
+	screen.DrawImage(bar, opts)
+	if budget > 0 && used > 0 {
+		fill := int(float64(barWidth) * float64(used) / float64(budget))
+		fillColor := color.RGBA{80, 180, 100, 255}
+		if used > budget {
+			fill = barWidth
+			fillColor = color.RGBA{220, 80, 80, 255}
+		}
+		if fill > 0 {
+			bar = ebiten.NewImage(fill, 6)
+			bar.Fill(fillColor)
+			screen.DrawImage(bar, opts)
+		}
+	}
+
+	y := memoryPanelTop + 45
+	header := color.RGBA{150, 150, 150, 255}
+	text.Draw(screen, "KIND", basicfont.Face7x13, left+10, y, header)
+	text.Draw(screen, "ASSET", basicfont.Face7x13, left+62, y, header)
+	text.Draw(screen, "SIZE", basicfont.Face7x13, left+222, y, header)
+	text.Draw(screen, "REFS", basicfont.Face7x13, left+290, y, header)
+	text.Draw(screen, "IDLE", basicfont.Face7x13, left+330, y, header)
+
+	now := time.Now()
+	for i, a := range usage {
+		y += memoryPanelRowHeight
+		if y > bottom-memoryPanelRowHeight {
+			remaining := len(usage) - i
+			text.Draw(screen, fmt.Sprintf("... %d more", remaining), basicfont.Face7x13, left+10, y, header)
+			break
+		}
+
+		// Referenced assets are white, cached ones that may be evicted are grey
+		rowColor := color.RGBA{120, 120, 120, 255}
+		if a.Refs > 0 {
+			rowColor = color.RGBA{220, 220, 220, 255}
+		}
+		name := path.Base(a.Path)
+		if len(name) > 22 {
+			name = name[:21] + "~"
+		}
+		idle := "-"
+		if a.Refs == 0 {
+			idle = fmt.Sprintf("%.0fs", now.Sub(a.LastUsed).Seconds())
+		}
+		text.Draw(screen, a.Kind.String(), basicfont.Face7x13, left+10, y, rowColor)
+		text.Draw(screen, name, basicfont.Face7x13, left+62, y, rowColor)
+		text.Draw(screen, resources.FormatBytes(a.Size), basicfont.Face7x13, left+222, y, rowColor)
+		text.Draw(screen, fmt.Sprintf("%d", a.Refs), basicfont.Face7x13, left+290, y, rowColor)
+		text.Draw(screen, idle, basicfont.Face7x13, left+330, y, rowColor)
+	}
+}
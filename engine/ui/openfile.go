@@ -0,0 +1,37 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// openInEditor opens a source file at a line. $LUENGO_EDITOR is used when set, with
+// {file} and {line} replaced, e.g. "code -g {file}:{line}"; otherwise VS Code is tried
+// and then the platform's default handler for the file.
+func openInEditor(file string, line int) error {
+	if _, err := os.Stat(file); err != nil {
+		return err
+	}
+
+	if custom := os.Getenv("LUENGO_EDITOR"); custom != "" {
+		replacer := strings.NewReplacer("{file}", file, "{line}", fmt.Sprint(line))
+		args := strings.Fields(replacer.Replace(custom))
+		return exec.Command(args[0], args[1:]...).Start()
+	}
+
+	if _, err := exec.LookPath("code"); err == nil {
+		return exec.Command("code", "-g", fmt.Sprintf("%s:%d", file, line)).Start()
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		return exec.Command("cmd", "/c", "start", "", file).Start()
+	case "darwin":
+		return exec.Command("open", file).Start()
+	default:
+		return exec.Command("xdg-open", file).Start()
+	}
+}
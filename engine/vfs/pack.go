@@ -0,0 +1,79 @@
+package vfs
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// Pack writes the files under dirs of fsys into a zip archive that MountArchive can
+// serve. Dot files, and files for which skip returns true, are left out. It returns
+// the paths it packed.
+func Pack(file string, fsys fs.FS, dirs []string, skip func(name string) bool) ([]string, error) {
+	out, err := os.Create(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive %s: %w", file, err)
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	var packed []string
+	for _, dir := range dirs {
+		err := fs.WalkDir(fsys, Clean(dir), func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(d.Name(), ".") && name != Clean(dir) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() || (skip != nil && skip(name)) {
+				return nil
+			}
+			if err := addFile(zw, fsys, name); err != nil {
+				return err
+			}
+			packed = append(packed, name)
+			return nil
+		})
+		if err != nil {
+			zw.Close()
+			return packed, fmt.Errorf("failed to pack %s: %w", dir, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return packed, fmt.Errorf("failed to write archive %s: %w", file, err)
+	}
+	return packed, nil
+}
+
+func addFile(zw *zip.Writer, fsys fs.FS, name string) error {
+	src, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = path.Clean(name)
+	header.Method = zip.Deflate
+	dst, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
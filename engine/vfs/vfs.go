@@ -0,0 +1,284 @@
+package vfs
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS layers several file systems into one. Each mount serves the paths under its
+// prefix, and later mounts override earlier ones, so a mod can replace a base asset
+// by mounting its own folder over the asset root.
+type FS struct {
+	mounts []mount
+}
+
+type mount struct {
+	prefix string // Slash-separated, "" for the root
+	fsys   fs.FS
+	dir    string // OS folder behind fsys, empty for archives and embedded files
+	name   string // Shown in logs
+	closer io.Closer
+}
+
+func New() *FS {
+	return &FS{}
+}
+
+// Clean converts an OS or slash path into the form fs.FS expects: slash-separated,
+// unrooted and without "." or ".." elements
+func Clean(p string) string {
+	p = path.Clean(filepath.ToSlash(p))
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+// Mount serves fsys under prefix, e.g. an embed.FS
+func (v *FS) Mount(prefix string, fsys fs.FS, name string) {
+	v.mounts = append(v.mounts, mount{prefix: cleanPrefix(prefix), fsys: fsys, name: name})
+}
+
+// MountDir serves an OS folder under prefix
+func (v *FS) MountDir(prefix, dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to mount %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("failed to mount %s: not a folder", dir)
+	}
+	v.mounts = append(v.mounts, mount{prefix: cleanPrefix(prefix), fsys: os.DirFS(dir), dir: dir, name: dir})
+	return nil
+}
+
+// MountArchive serves a zip archive (.zip or .pak) under prefix
+func (v *FS) MountArchive(prefix, file string) error {
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to mount %s: %w", file, err)
+	}
+	v.mounts = append(v.mounts, mount{prefix: cleanPrefix(prefix), fsys: r, name: file, closer: r})
+	return nil
+}
+
+// Close closes the mounted archives
+func (v *FS) Close() {
+	for _, m := range v.mounts {
+		if m.closer != nil {
+			m.closer.Close()
+		}
+	}
+}
+
+// Mounts describes the mounts from lowest to highest priority
+func (v *FS) Mounts() []string {
+	names := make([]string, len(v.mounts))
+	for i, m := range v.mounts {
+		names[i] = "/" + m.prefix + " <- " + m.name
+	}
+	return names
+}
+
+// Snapshot returns an FS with the current mounts, unaffected by later ones
+func (v *FS) Snapshot() *FS {
+	return &FS{mounts: append([]mount(nil), v.mounts...)}
+}
+
+func cleanPrefix(prefix string) string {
+	prefix = Clean(prefix)
+	if prefix == "." {
+		return ""
+	}
+	return prefix
+}
+
+// relative returns name relative to the mount, or false if the mount does not serve it
+func (m mount) relative(name string) (string, bool) {
+	if m.prefix == "" {
+		return name, true
+	}
+	if name == m.prefix {
+		return ".", true
+	}
+	if strings.HasPrefix(name, m.prefix+"/") {
+		return name[len(m.prefix)+1:], true
+	}
+	return "", false
+}
+
+// Open opens a file from the highest mount that has it
+func (v *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	for i := len(v.mounts) - 1; i >= 0; i-- {
+		rel, ok := v.mounts[i].relative(name)
+		if !ok {
+			continue
+		}
+		f, err := v.mounts[i].fsys.Open(rel)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if v.isMountParent(name) {
+		return &virtualDir{name: name, fsys: v}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadFile reads a whole file from the highest mount that has it
+func (v *FS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(onlyOpen{v}, name)
+}
+
+// Stat describes a file from the highest mount that has it
+func (v *FS) Stat(name string) (fs.FileInfo, error) {
+	f, err := v.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// ReadDir merges a folder's entries across every mount, sorted by name
+func (v *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries := make(map[string]fs.DirEntry)
+	found := false
+	for i := len(v.mounts) - 1; i >= 0; i-- {
+		m := v.mounts[i]
+		if rel, ok := m.relative(name); ok {
+			list, err := fs.ReadDir(m.fsys, rel)
+			if err == nil {
+				found = true
+				for _, e := range list {
+					if _, exists := entries[e.Name()]; !exists {
+						entries[e.Name()] = e
+					}
+				}
+			}
+		}
+		// A mount below this folder shows up as a subfolder even if no other mount has it
+		if child, ok := childOf(name, m.prefix); ok {
+			found = true
+			if _, exists := entries[child]; !exists {
+				entries[child] = fs.FileInfoToDirEntry(dirInfo{name: child})
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	list := make([]fs.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+// Origin returns the OS path of a file served from a mounted folder, e.g. to open it
+// in an editor. Files from archives and embedded files have none.
+func (v *FS) Origin(name string) (string, bool) {
+	name = Clean(name)
+	for i := len(v.mounts) - 1; i >= 0; i-- {
+		m := v.mounts[i]
+		rel, ok := m.relative(name)
+		if !ok {
+			continue
+		}
+		if _, err := fs.Stat(m.fsys, rel); err != nil {
+			continue
+		}
+		if m.dir == "" {
+			return "", false
+		}
+		return filepath.Join(m.dir, filepath.FromSlash(rel)), true
+	}
+	return "", false
+}
+
+// isMountParent reports whether name is a folder that only exists as the parent of a mount prefix
+func (v *FS) isMountParent(name string) bool {
+	for _, m := range v.mounts {
+		if _, ok := childOf(name, m.prefix); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// childOf returns the first element of prefix below dir, e.g. ("mod", "mod/a/assets") -> "a"
+func childOf(dir, prefix string) (string, bool) {
+	if prefix == "" {
+		return "", false
+	}
+	rest := prefix
+	if dir != "." {
+		if !strings.HasPrefix(prefix, dir+"/") {
+			return "", false
+		}
+		rest = prefix[len(dir)+1:]
+	}
+	if i := strings.Index(rest, "/"); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest, true
+}
+
+// onlyOpen hides the FS's own ReadFile so fs.ReadFile goes through Open
+type onlyOpen struct{ v *FS }
+
+func (o onlyOpen) Open(name string) (fs.File, error) { return o.v.Open(name) }
+
+// virtualDir is a folder implied by a mount prefix
+type virtualDir struct {
+	name string
+	fsys *FS
+	read bool
+}
+
+func (d *virtualDir) Stat() (fs.FileInfo, error) { return dirInfo{name: path.Base(d.name)}, nil }
+func (d *virtualDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+func (d *virtualDir) Close() error { return nil }
+
+func (d *virtualDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.read {
+		if n > 0 {
+			return nil, io.EOF
+		}
+		return nil, nil
+	}
+	d.read = true
+	return d.fsys.ReadDir(d.name)
+}
+
+type dirInfo struct{ name string }
+
+func (i dirInfo) Name() string       { return i.name }
+func (i dirInfo) Size() int64        { return 0 }
+func (i dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0o555 }
+func (i dirInfo) ModTime() time.Time { return time.Time{} }
+func (i dirInfo) IsDir() bool        { return true }
+func (i dirInfo) Sys() interface{}   { return nil }
@@ -1,26 +1,90 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
 
 	"deepthinking.do/luengo/engine"
 )
 
+const usage = `usage: luengo <command> [flags]
+
+Commands:
+  edit   open the editor (default)
+  run    play the game without the editor
+  test   run the Lua tests of the mods
+  pack   package the mods and assets into one archive
+  new    create a new project from a starter template
+
+Run "luengo <command> -h" for the flags of a command.
+Settings are read from luengo.json when it exists; flags override them.
+`
+
 func main() {
+	command, args := "edit", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "edit":
+		os.Exit(runGame("edit", true, args))
+	case "run":
+		os.Exit(runGame("run", false, args))
+	case "test":
+		os.Exit(runTests(args))
+	case "new":
+		os.Exit(runNew(args))
+	case "pack":
+		os.Exit(runPack(args))
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n%s", command, usage)
+		os.Exit(2)
+	}
+}
+
+// runGame implements `luengo edit` and `luengo run` and returns the process exit code
+func runGame(command string, editor bool, args []string) int {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	settings := addConfigFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: luengo %s [flags]\n", command)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	cfg, err := settings.load(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", command, err)
+		return 2
+	}
+
 	fmt.Println("🚀 [Engine] Starting Luengo Engine - Modular Architecture")
 
 	// Set window properties
-	ebiten.SetWindowSize(1200, 800)
-	ebiten.SetWindowTitle("Luengo Engine - Modular Editor")
+	ebiten.SetWindowSize(cfg.Width, cfg.Height)
+	ebiten.SetWindowTitle(cfg.Title)
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	ebiten.SetFullscreen(cfg.Fullscreen)
+	ebiten.SetTPS(cfg.TPS)
 
 	// Create and initialize game
-	game := engine.NewGame()
+	game := engine.NewGame(cfg)
+	game.SetEditorMode(editor)
+	if cfg.Debug != "" {
+		if err := game.EnableDebugger(cfg.Debug); err != nil {
+			fmt.Printf("Failed to start debugger: %v\n", err)
+		}
+	}
 	if err := game.Initialize(); err != nil {
 		fmt.Printf("Failed to initialize game: %v\n", err)
-		return
+		return 1
 	}
 	defer game.Close()
 
@@ -28,6 +92,7 @@ func main() {
 	fmt.Println("   F1: Toggle Editor/Play Mode")
 	fmt.Println("   F2: Toggle Inspector (Editor mode only)")
 	fmt.Println("   F3: Toggle Debug Info")
+	fmt.Println("   F4: Toggle Hierarchy (Editor mode only)")
 	fmt.Println("   F11: Toggle Fullscreen")
 	fmt.Println("   WASD/Arrows: Pan Camera (Editor mode) / Move Player (Play mode)")
 	fmt.Println("   Mouse Wheel/+/-: Zoom")
@@ -38,5 +103,7 @@ func main() {
 	// Run game
 	if err := ebiten.RunGame(game); err != nil {
 		fmt.Printf("[Engine Error]: %v\n", err)
+		return 1
 	}
+	return 0
 }
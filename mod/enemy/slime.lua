@@ -18,6 +18,7 @@ slime.next_id = 1
 function slime.create_instance(x, y)
     local instance = {
         id = slime.next_id,
+        entity_id = spawn_prefab("slime", x, y),
         position = {x = x or 0, y = y or 0},
         health = slime.stats.health,
         state = "idle", -- idle, patrol, chase, attack
@@ -156,6 +157,9 @@ function slime.kill_slime(instance_id)
     local instance = slime.instances[instance_id]
     if instance then
         log("💀 Slime #" .. instance_id .. " died!")
+        if particles then
+            particles.burst("slime_burst", instance.position.x, instance.position.y)
+        end
         emit("slime_died", instance_id)
         slime.instances[instance_id] = nil
     end
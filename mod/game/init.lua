@@ -190,5 +190,34 @@ function game.load()
     emit("game_loaded", "save_file")
 end
 
+-- Draw the score, level and lives in the top left corner
+function game.draw_hud()
+    local x, y = 10, 10
+    draw.set_color(0, 0, 0, 140)
+    draw.rect("fill", x - 6, y - 6, 150, 52)
+
+    draw.set_color(255, 255, 255)
+    draw.text("Score: " .. game.state.score, x, y)
+    draw.text("Level: " .. game.state.level, x, y + 14)
+
+    -- One heart-coloured dot per life
+    draw.set_color(230, 60, 80)
+    for i = 1, game.state.lives do
+        draw.circle("fill", x + 4 + (i - 1) * 14, y + 34, 5)
+    end
+
+    if game.state.mode == "paused" or game.state.mode == "game_over" then
+        local label = game.state.mode == "paused" and "PAUSED" or "GAME OVER"
+        local width, height = draw.get_size()
+        local text_width, text_height = draw.text_size(label, 3)
+        draw.set_color(255, 255, 255)
+        draw.text(label, (width - text_width) / 2, (height - text_height) / 2, 3)
+    end
+end
+
+function on_draw_ui()
+    game.draw_hud()
+end
+
 -- Export the module
 return game
@@ -104,6 +104,12 @@ end
 -- Apply health effect
 function potions.apply_health_effect(amount, target)
     log("💚 Healing for " .. amount .. " HP")
+    if particles then
+        local x, y = get_player_position()
+        if x then
+            particles.burst("heal", x, y)
+        end
+    end
     -- In a real game, this would call target.heal(amount)
     -- For now, just log the effect
     emit("player_healed", amount)
@@ -180,5 +186,47 @@ function potions.get_active_effects()
     return potions.active_effects
 end
 
+-- Potion colours and the order they are shown in on the HUD
+potions.hud_order = {"health", "energy", "strength", "speed"}
+potions.hud_colors = {
+    health = {230, 60, 80},
+    energy = {250, 210, 60},
+    strength = {240, 130, 40},
+    speed = {80, 180, 250}
+}
+
+-- Draw the potion inventory along the bottom left corner, dimming empty slots
+function potions.draw_hud()
+    local _, height = draw.get_size()
+    local x, y = 10, height - 40
+    for i, potion_type in ipairs(potions.hud_order) do
+        local count = potions.get_count(potion_type)
+        local color = potions.hud_colors[potion_type]
+        local alpha = count > 0 and 255 or 90
+        local slot_x = x + (i - 1) * 44
+
+        draw.set_color(0, 0, 0, 140)
+        draw.rect("fill", slot_x, y, 38, 30)
+        draw.set_color(color[1], color[2], color[3], alpha)
+        draw.polygon("fill", {slot_x + 9, y + 6, slot_x + 17, y + 6, slot_x + 19, y + 24, slot_x + 7, y + 24})
+        draw.set_color(255, 255, 255, alpha)
+        draw.text("x" .. count, slot_x + 21, y + 9)
+    end
+
+    -- A bar under the slots for each effect still running
+    local bar_y = y - 8
+    for _, effect in pairs(potions.active_effects) do
+        local color = potions.hud_colors[effect.type] or {255, 255, 255}
+        local duration = potions.types[effect.type].duration
+        draw.set_color(color[1], color[2], color[3])
+        draw.rect("fill", x, bar_y, 170 * effect.remaining_time / duration, 4)
+        bar_y = bar_y - 6
+    end
+end
+
+function on_draw_ui()
+    potions.draw_hud()
+end
+
 -- Export the module
 return potions
@@ -0,0 +1,37 @@
+-- Player tests, run headless with `luengo test`
+
+local player = require("player")
+
+describe("player module", function()
+  it("starts with full health and energy", function()
+    expect(player.stats.health):to_be(100)
+    expect(player.stats.energy):to_be(100)
+  end)
+end)
+
+describe("player movement", function()
+  local start_x
+
+  before_each(function()
+    start_x = get_player_position()
+  end)
+
+  it("moves right while the arrow key is held", function()
+    press("ArrowRight")
+    tick(10)
+    expect(get_player_position()):to_be_greater_than(start_x)
+  end)
+
+  it("stays put without input", function()
+    tick(10)
+    expect(get_player_position()):to_be(start_x)
+  end)
+end)
+
+describe("game time", function()
+  it("advances with simulated time", function()
+    local start = game_time()
+    advance(1)
+    expect(game_time() - start):to_be_close_to(1, 0.05)
+  end)
+end)
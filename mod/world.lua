@@ -47,6 +47,26 @@ function world.init()
     log("🌍 World module initialized!")
     log("🗺️ World size: " .. world.config.width .. "x" .. world.config.height)
     log("🏠 Spawn point: (" .. world.config.spawn_point.x .. ", " .. world.config.spawn_point.y .. ")")
+
+    -- Keep the camera inside the world while playing
+    if camera then
+        camera.set_bounds(0, 0, world.config.width, world.config.height)
+    end
+
+    -- Light the world by the time of day: dark blue at night, warm at dawn and dusk
+    if lighting then
+        lighting.set_ambient_curve({
+            {0, 40, 45, 80},
+            {300, 50, 55, 90},
+            {420, 255, 180, 140},
+            {600, 255, 255, 255},
+            {1080, 255, 240, 220},
+            {1200, 230, 130, 110},
+            {1320, 40, 45, 80},
+        }, 1440)
+        lighting.enable(true)
+        world.update_lighting()
+    end
     log("🌤️ Weather: " .. world.state.weather)
     log("🌡️ Temperature: " .. world.state.temperature .. "°C")
     
@@ -70,6 +90,8 @@ function world.update()
     if world.state.time_of_day % 300 == 0 then -- Every 5 minutes
         world.update_weather()
     end
+    world.update_lighting()
+    world.update_rain()
     
     -- Spawn entities based on zones
     if world.state.entities_spawned < world.state.max_entities then
@@ -79,6 +101,44 @@ function world.update()
     end
 end
 
+-- How much each kind of weather dims the ambient light
+world.weather_light = {
+    clear = 1.0,
+    rain = 0.75,
+    fog = 0.85,
+    storm = 0.55
+}
+
+-- Follow the time of day and weather with the ambient light
+function world.update_lighting()
+    if not lighting then
+        return
+    end
+    lighting.set_time(world.state.time_of_day)
+    lighting.set_ambient_scale(world.weather_light[world.state.weather] or 1.0)
+end
+
+-- Rain falls from just above the view while it rains or storms
+function world.update_rain()
+    if not particles or not camera then
+        return
+    end
+    local raining = world.state.weather == "rain" or world.state.weather == "storm"
+    if raining and not world.rain_emitter then
+        world.rain_emitter = particles.spawn("rain", 0, 0)
+    elseif not raining and world.rain_emitter then
+        particles.stop(world.rain_emitter)
+        world.rain_emitter = nil
+    end
+    if world.rain_emitter then
+        local x, y = camera.get_position()
+        -- A scene change removes emitters; start again on the next update
+        if not particles.set_position(world.rain_emitter, x, y - 400) then
+            world.rain_emitter = nil
+        end
+    end
+end
+
 -- Update weather system
 function world.update_weather()
     local weather_options = {"clear", "rain", "storm", "fog"}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"deepthinking.do/luengo/engine/scaffold"
+)
+
+// runNew implements `luengo new`: it creates a project folder from a starter template
+func runNew(args []string) int {
+	flags := flag.NewFlagSet("new", flag.ExitOnError)
+	templateName := flags.String("template", scaffold.DefaultTemplate, "starter: "+strings.Join(scaffold.Templates, " or "))
+	dir := flags.String("dir", ".", "folder to create the project in")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: luengo new [flags] <name>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	name := flags.Arg(0)
+
+	files, err := scaffold.Create(*dir, name, *templateName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "new: %v\n", err)
+		return 1
+	}
+	root := filepath.Join(*dir, name)
+	for _, file := range files {
+		if rel, err := filepath.Rel(root, file); err == nil {
+			file = rel
+		}
+		fmt.Printf("  created %s\n", filepath.ToSlash(file))
+	}
+	fmt.Printf("Created %s project in %s\n\n", *templateName, root)
+	fmt.Printf("  cd %s\n  luengo edit\n  luengo test\n", root)
+	return 0
+}
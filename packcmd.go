@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"deepthinking.do/luengo/engine/luatest"
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+// runPack implements `luengo pack`: it zips the mod folder, the asset root and the
+// starting scene into an archive the game can mount with "pack" or -pack
+func runPack(args []string) int {
+	flags := flag.NewFlagSet("pack", flag.ExitOnError)
+	out := flags.String("o", "game.pak", "archive to write")
+	withTests := flags.Bool("tests", false, "include *_test.lua files")
+	settings := addConfigFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: luengo pack [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	cfg, err := settings.load(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pack: %v\n", err)
+		return 2
+	}
+
+	dirs := []string{vfs.Clean(cfg.ModPath), vfs.Clean(cfg.AssetRoot)}
+	if cfg.Scene != "" && !underAny(vfs.Clean(cfg.Scene), dirs) {
+		dirs = append(dirs, vfs.Clean(cfg.Scene))
+	}
+	skip := func(name string) bool {
+		return !*withTests && strings.HasSuffix(name, luatest.FileSuffix)
+	}
+
+	files, err := vfs.Pack(*out, os.DirFS(cfg.Root), dirs, skip)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pack: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Packed %d files from %s into %s\n", len(files), strings.Join(dirs, ", "), *out)
+	fmt.Printf("Ship it next to the executable with a luengo.json containing \"pack\": %q\n", path.Base(vfs.Clean(*out)))
+	return 0
+}
+
+// underAny reports whether name is inside one of dirs
+func underAny(name string, dirs []string) bool {
+	for _, dir := range dirs {
+		if name == dir || strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"deepthinking.do/luengo/engine"
+	"deepthinking.do/luengo/engine/logging"
+	"deepthinking.do/luengo/engine/luatest"
+	"deepthinking.do/luengo/engine/vfs"
+)
+
+// runTests implements `luengo test`: it runs <mods>/**/*_test.lua against a headless
+// game and returns the process exit code. Tests are found and read through the
+// game's files, so packed and overlaid tests run too.
+func runTests(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	format := flags.String("format", "text", "report format: text, tap or junit")
+	out := flags.String("out", "", "write the report to a file instead of stdout")
+	verbose := flags.Bool("v", false, "show passing tests, tracebacks and engine logs")
+	settings := addConfigFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: luengo test [flags] [dir or file ...]  (paths in the game's files; default: the mod folder)")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	cfg, err := settings.load(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "test: %v\n", err)
+		return 2
+	}
+	cfg.Debug = ""
+	logging.Default().SetStdout(*verbose)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{cfg.ModPath}
+	}
+	gameFiles := engine.OpenFiles(cfg)
+	defer gameFiles.Close()
+	var files []string
+	for _, path := range paths {
+		found, err := luatest.FindTests(gameFiles, vfs.Clean(path))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "test: %v\n", err)
+			return 2
+		}
+		files = append(files, found...)
+	}
+
+	runner := &luatest.Runner{NewHost: func() (luatest.Host, error) {
+		game := engine.NewHeadlessGame(cfg)
+		if err := game.Initialize(); err != nil {
+			return nil, err
+		}
+		return game, nil
+	}}
+	results := runner.Run(files)
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "test: %v\n", err)
+			return 2
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *format {
+	case "tap":
+		luatest.WriteTAP(w, results)
+	case "junit":
+		if err := luatest.WriteJUnit(w, results); err != nil {
+			fmt.Fprintf(os.Stderr, "test: %v\n", err)
+			return 2
+		}
+	default:
+		luatest.WriteText(w, results, *verbose)
+	}
+
+	total, failed := luatest.Summary(results)
+	if *out != "" {
+		fmt.Printf("%d tests, %d failed (report: %s)\n", total, failed, *out)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}